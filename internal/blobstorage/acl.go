@@ -0,0 +1,21 @@
+package blobstorage
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// validateACL checks acl against the canned ACLs S3 knows about. An empty ACL
+// is valid and sends no ACL header, which S3 treats as private.
+func validateACL(acl string) error {
+	if acl == "" {
+		return nil
+	}
+	for _, known := range types.ObjectCannedACL("").Values() {
+		if acl == string(known) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid ACL %q", acl)
+}
@@ -0,0 +1,105 @@
+package blobstorage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestNewS3BlobStorageInvalidACL(t *testing.T) {
+	_, err := NewS3BlobStorage(Config{
+		Enabled:   true,
+		AccessKey: "access",
+		SecretKey: "secret",
+		ACL:       "world-writable",
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid ACL") {
+		t.Errorf("expected invalid ACL error, got %v", err)
+	}
+}
+
+func TestNewS3BlobStorageBucketOwnerFullControl(t *testing.T) {
+	tests := []struct {
+		name        string
+		acl         string
+		expectError bool
+	}{
+		{name: "alone"},
+		{name: "with the same ACL", acl: "bucket-owner-full-control"},
+		{name: "with public-read", acl: "public-read", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, err := NewS3BlobStorage(Config{
+				Enabled:                true,
+				AccessKey:              "access",
+				SecretKey:              "secret",
+				ACL:                    tt.acl,
+				BucketOwnerFullControl: true,
+				SkipBucketCreation:     true,
+			})
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if storage.acl != string(types.ObjectCannedACLBucketOwnerFullControl) {
+				t.Errorf("expected ACL %q, got %q", types.ObjectCannedACLBucketOwnerFullControl, storage.acl)
+			}
+		})
+	}
+}
+
+func TestStoreACL(t *testing.T) {
+	tests := []struct {
+		name        string
+		acl         string
+		expectedACL types.ObjectCannedACL
+	}{
+		{name: "default sends no ACL", expectedACL: ""},
+		{name: "public read", acl: "public-read", expectedACL: types.ObjectCannedACLPublicRead},
+		{name: "private", acl: "private", expectedACL: types.ObjectCannedACLPrivate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, _ := newBucketMock()
+			putObject := mock.putObjectFunc
+			var putACL types.ObjectCannedACL
+			mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				putACL = params.ACL
+				return putObject(ctx, params, optFns...)
+			}
+			createMultipart := mock.createMultipartUploadFunc
+			var multipartACL types.ObjectCannedACL
+			mock.createMultipartUploadFunc = func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+				multipartACL = params.ACL
+				return createMultipart(ctx, params, optFns...)
+			}
+			storage := newMultipartTestStorage(mock)
+			storage.acl = tt.acl
+
+			if _, err := storage.Store("small"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if putACL != tt.expectedACL {
+				t.Errorf("expected PutObject ACL=%q, got %q", tt.expectedACL, putACL)
+			}
+
+			if _, err := storage.Store("content above the multipart threshold"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if multipartACL != tt.expectedACL {
+				t.Errorf("expected CreateMultipartUpload ACL=%q, got %q", tt.expectedACL, multipartACL)
+			}
+		})
+	}
+}
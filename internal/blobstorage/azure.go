@@ -0,0 +1,267 @@
+//go:build azure
+
+// Azure support is opt-in so the default build doesn't compile the Azure
+// SDK. Build and test with -tags azure; the tests run against Azurite when
+// AZURITE_BLOB_ENDPOINT is set.
+
+package blobstorage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+)
+
+// AzureConfig holds Azure Blob Storage configuration
+type AzureConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	AccountName string `yaml:"account_name"`
+	// #nosec G117 -- Configuration field name, not a hardcoded secret
+	AccountKey string `yaml:"account_key"`
+	Container  string `yaml:"container"`
+	// Endpoint overrides the service URL, e.g. for Azurite; it defaults to
+	// https://<account>.blob.core.windows.net/
+	Endpoint string `yaml:"endpoint"`
+	Timeout  int    `yaml:"timeout"` // seconds
+	// UseDefaultCredentials authenticates with the Azure default credential
+	// chain (environment, workload identity, managed identity, Azure CLI)
+	// instead of the account key
+	UseDefaultCredentials bool `yaml:"use_default_credentials"`
+}
+
+// AzureBlobStorage implements BlobStorage on Azure Blob Storage using the same
+// blobs/<sha256> naming and deduplication as S3BlobStorage
+type AzureBlobStorage struct {
+	client    *azblob.Client
+	container string
+	enabled   bool
+	ctx       context.Context
+	cancel    context.CancelFunc
+	timeout   time.Duration
+	closed    atomic.Bool
+}
+
+var _ BlobStorage = (*AzureBlobStorage)(nil)
+
+// NewAzureBlobStorage creates a new Azure blob storage instance, creating the
+// container if it doesn't exist
+func NewAzureBlobStorage(cfg AzureConfig) (*AzureBlobStorage, error) {
+	if !cfg.Enabled {
+		return &AzureBlobStorage{enabled: false}, nil
+	}
+
+	if cfg.AccountName == "" {
+		return nil, fmt.Errorf("Azure account name is required when blob storage is enabled")
+	}
+	if !cfg.UseDefaultCredentials && cfg.AccountKey == "" {
+		return nil, fmt.Errorf("Azure account key is required when blob storage is enabled")
+	}
+
+	if cfg.Container == "" {
+		cfg.Container = "email-attachments"
+	}
+
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = fmt.Sprintf("https://%s.blob.core.windows.net/", cfg.AccountName)
+	}
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 30
+	}
+
+	var client *azblob.Client
+	if cfg.UseDefaultCredentials {
+		cred, err := azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Azure credentials: %w", err)
+		}
+		if client, err = azblob.NewClient(cfg.Endpoint, cred, nil); err != nil {
+			return nil, fmt.Errorf("failed to create Azure client: %w", err)
+		}
+	} else {
+		cred, err := azblob.NewSharedKeyCredential(cfg.AccountName, cfg.AccountKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Azure credentials: %w", err)
+		}
+		if client, err = azblob.NewClientWithSharedKeyCredential(cfg.Endpoint, cred, nil); err != nil {
+			return nil, fmt.Errorf("failed to create Azure client: %w", err)
+		}
+	}
+
+	// Close cancels ctx to stop in-flight operations
+	ctx, cancel := context.WithCancel(context.Background())
+
+	storage := &AzureBlobStorage{
+		client:    client,
+		container: cfg.Container,
+		enabled:   true,
+		ctx:       ctx,
+		cancel:    cancel,
+		timeout:   time.Duration(cfg.Timeout) * time.Second,
+	}
+
+	if err := storage.ensureContainer(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to ensure container exists: %w", err)
+	}
+
+	return storage, nil
+}
+
+// IsEnabled returns whether blob storage is enabled
+func (a *AzureBlobStorage) IsEnabled() bool {
+	return a.enabled
+}
+
+// ensureContainer creates the container if it doesn't exist
+func (a *AzureBlobStorage) ensureContainer() error {
+	ctx, cancel := context.WithTimeout(a.ctx, a.timeout)
+	defer cancel()
+
+	_, err := a.client.CreateContainer(ctx, a.container, nil)
+	if err != nil && !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+		return err
+	}
+	return nil
+}
+
+// Store stores content in Azure and returns the blob ID (SHA256 hash)
+func (a *AzureBlobStorage) Store(content string) (string, error) {
+	if err := a.checkUsable(); err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256([]byte(content))
+	blobID := hex.EncodeToString(hash[:])
+	name := blobKeyPrefix + blobID
+
+	ctx, cancel := context.WithTimeout(a.ctx, a.timeout)
+	defer cancel()
+
+	// Check if blob already exists (deduplication)
+	exists, err := a.blobExists(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if blob exists: %w", err)
+	}
+	if exists {
+		return blobID, nil
+	}
+
+	contentType := defaultContentType
+	_, err = a.client.UploadBuffer(ctx, a.container, name, []byte(content), &azblob.UploadBufferOptions{
+		HTTPHeaders: &blob.HTTPHeaders{BlobContentType: &contentType},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload blob: %w", err)
+	}
+
+	return blobID, nil
+}
+
+// Retrieve retrieves content from Azure by blob ID
+func (a *AzureBlobStorage) Retrieve(blobID string) (string, error) {
+	if err := a.checkUsable(); err != nil {
+		return "", err
+	}
+	if err := validateSHA256BlobID(blobID); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(a.ctx, a.timeout)
+	defer cancel()
+
+	resp, err := a.client.DownloadStream(ctx, a.container, blobKeyPrefix+blobID, nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return "", fmt.Errorf("failed to retrieve blob: %w: %w", ErrBlobNotFound, err)
+		}
+		return "", fmt.Errorf("failed to retrieve blob: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read blob content: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// Delete deletes a blob from Azure. Deleting a missing blob is not an error,
+// matching S3.
+func (a *AzureBlobStorage) Delete(blobID string) error {
+	if err := a.checkUsable(); err != nil {
+		return err
+	}
+	if err := validateSHA256BlobID(blobID); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(a.ctx, a.timeout)
+	defer cancel()
+
+	_, err := a.client.DeleteBlob(ctx, a.container, blobKeyPrefix+blobID, nil)
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+
+	return nil
+}
+
+// Exists checks if a blob exists in Azure
+func (a *AzureBlobStorage) Exists(blobID string) (bool, error) {
+	if err := a.checkUsable(); err != nil {
+		return false, err
+	}
+	if err := validateSHA256BlobID(blobID); err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(a.ctx, a.timeout)
+	defer cancel()
+
+	return a.blobExists(ctx, blobKeyPrefix+blobID)
+}
+
+// Close cancels in-flight operations. Later operations fail with ErrClosed.
+// Closing more than once is a no-op.
+func (a *AzureBlobStorage) Close() error {
+	if !a.enabled || a.closed.Swap(true) {
+		return nil
+	}
+
+	a.cancel()
+	return nil
+}
+
+// checkUsable returns ErrStorageDisabled or ErrClosed when operations can't run
+func (a *AzureBlobStorage) checkUsable() error {
+	if !a.enabled {
+		return ErrStorageDisabled
+	}
+	if a.closed.Load() {
+		return ErrClosed
+	}
+	return nil
+}
+
+// blobExists checks whether the named blob exists by fetching its properties
+func (a *AzureBlobStorage) blobExists(ctx context.Context, name string) (bool, error) {
+	_, err := a.client.ServiceClient().NewContainerClient(a.container).NewBlobClient(name).GetProperties(ctx, nil)
+	if err == nil {
+		return true, nil
+	}
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return false, nil
+	}
+	return false, err
+}
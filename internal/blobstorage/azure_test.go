@@ -0,0 +1,129 @@
+//go:build azure
+
+package blobstorage
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+// azuriteAccountKey is Azurite's well-known development account key
+const azuriteAccountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFTBCK/TZxBzEDOzWIFQ=="
+
+// newAzuriteStorage returns an AzureBlobStorage backed by the Azurite
+// emulator at AZURITE_BLOB_ENDPOINT, e.g. http://127.0.0.1:10000/devstoreaccount1,
+// skipping the test when it isn't set
+func newAzuriteStorage(t *testing.T) *AzureBlobStorage {
+	t.Helper()
+
+	endpoint := os.Getenv("AZURITE_BLOB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("AZURITE_BLOB_ENDPOINT not set")
+	}
+
+	storage, err := NewAzureBlobStorage(AzureConfig{
+		Enabled:     true,
+		AccountName: "devstoreaccount1",
+		AccountKey:  azuriteAccountKey,
+		Container:   "raven-test",
+		Endpoint:    endpoint,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = storage.Close() })
+	return storage
+}
+
+func TestAzureBlobStorage(t *testing.T) {
+	storage := newAzuriteStorage(t)
+	content := "azure blob content " + t.Name()
+
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if blobID != testBlobID(content) {
+		t.Errorf("expected blobID=%q, got %q", testBlobID(content), blobID)
+	}
+
+	dupID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dupID != blobID {
+		t.Errorf("expected duplicate content to return %q, got %q", blobID, dupID)
+	}
+
+	retrieved, err := storage.Retrieve(blobID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if retrieved != content {
+		t.Errorf("expected content=%q, got %q", content, retrieved)
+	}
+
+	exists, err := storage.Exists(blobID)
+	if err != nil || !exists {
+		t.Errorf("expected blob to exist, got exists=%v err=%v", exists, err)
+	}
+
+	if err := storage.Delete(blobID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := storage.Delete(blobID); err != nil {
+		t.Errorf("expected deleting a missing blob to succeed, got %v", err)
+	}
+
+	if _, err := storage.Retrieve(blobID); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+	exists, err = storage.Exists(blobID)
+	if err != nil || exists {
+		t.Errorf("expected blob to be gone, got exists=%v err=%v", exists, err)
+	}
+}
+
+func TestAzureBlobStorageInvalidBlobID(t *testing.T) {
+	storage := newAzuriteStorage(t)
+
+	tests := []struct {
+		name string
+		op   func(blobID string) error
+	}{
+		{"Retrieve", func(blobID string) error { _, err := storage.Retrieve(blobID); return err }},
+		{"Delete", storage.Delete},
+		{"Exists", func(blobID string) error { _, err := storage.Exists(blobID); return err }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op("../secret"); !errors.Is(err, ErrInvalidBlobID) {
+				t.Errorf("expected ErrInvalidBlobID, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAzureBlobStorageDisabledAndClosed(t *testing.T) {
+	disabled, err := NewAzureBlobStorage(AzureConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := disabled.Store("content"); !errors.Is(err, ErrStorageDisabled) {
+		t.Errorf("expected ErrStorageDisabled, got %v", err)
+	}
+
+	if _, err := NewAzureBlobStorage(AzureConfig{Enabled: true}); err == nil {
+		t.Error("expected an error for a missing account name")
+	}
+
+	storage := newAzuriteStorage(t)
+	if err := storage.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := storage.Retrieve(testBlobID("content")); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// maxDeleteObjects is the most keys S3 accepts in one DeleteObjects request
+const maxDeleteObjects = 1000
+
+// DeleteBatch deletes many blobs, e.g. in retention cleanups. Blobs are
+// removed with DeleteObjects in batches of up to 1000 that are sent
+// concurrently; with ReferenceCounting, DryRun or ObjectLockMode each blob
+// goes through Delete instead. Missing blobs are not an error. Every failure
+// is returned, joined, rather than stopping at the first.
+func (s *S3BlobStorage) DeleteBatch(ctx context.Context, blobIDs []string) (err error) {
+	defer s.wrapError("DeleteBatch", "", &err)
+	if !s.enabled {
+		return ErrStorageDisabled
+	}
+
+	for _, blobID := range blobIDs {
+		if err := s.validateBlobID(blobID); err != nil {
+			return err
+		}
+	}
+
+	if s.referenceCounting || s.dryRun || s.objectLockMode != "" {
+		return s.runParallel(ctx, len(blobIDs), func(i int) error {
+			return s.delete(ctx, blobIDs[i])
+		})
+	}
+
+	batches := (len(blobIDs) + maxDeleteObjects - 1) / maxDeleteObjects
+	return s.runParallel(ctx, batches, func(i int) error {
+		batch := blobIDs[i*maxDeleteObjects : min((i+1)*maxDeleteObjects, len(blobIDs))]
+		return s.deleteObjects(ctx, batch)
+	})
+}
+
+// deleteObjects deletes one batch of blobs with a single DeleteObjects request
+func (s *S3BlobStorage) deleteObjects(ctx context.Context, blobIDs []string) error {
+	keys := make([]string, len(blobIDs))
+	for i, blobID := range blobIDs {
+		s.dedupCache.remove(blobID)
+		keys[i] = s.blobKey(blobID)
+	}
+
+	_, err := s.deleteKeys(ctx, keys)
+	return err
+}
+
+// deleteKeys deletes up to maxDeleteObjects keys with a single DeleteObjects
+// request, returning how many were deleted
+func (s *S3BlobStorage) deleteKeys(ctx context.Context, keys []string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "Delete"); err != nil {
+		return 0, err
+	}
+	defer s.releaseOp()
+
+	objects := make([]types.ObjectIdentifier, len(keys))
+	for i, key := range keys {
+		objects[i] = types.ObjectIdentifier{Key: aws.String(key)}
+	}
+
+	out, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(s.bucket),
+		Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete blobs: %w", err)
+	}
+
+	var errs []error
+	for _, e := range out.Errors {
+		errs = append(errs, fmt.Errorf("failed to delete %s: %s: %s", aws.ToString(e.Key), aws.ToString(e.Code), aws.ToString(e.Message)))
+	}
+	return len(keys) - len(out.Errors), errors.Join(errs...)
+}
+
+// StoreMany stores many blobs, e.g. inline images during bulk ingest, and
+// returns their IDs in input order. Instead of a HeadObject and PutObject
+// round trip per blob, it checks which blobs are already stored with one
+// ExistsBatch pass and uploads only the missing ones, concurrently with up to
+// MaxConcurrentOps (or 8) at a time. Duplicates within contents are uploaded
+// once. A throttled check follows DedupThrottlePolicy for that blob, as in
+// Store. With SkipDedupCheck every distinct blob is uploaded without a check.
+// With ReferenceCounting or DryRun each blob goes through Store instead.
+// Every failure is returned, joined, rather than stopping at the first.
+func (s *S3BlobStorage) StoreMany(ctx context.Context, contents [][]byte) (_ []string, err error) {
+	defer s.wrapError("StoreMany", "", &err)
+	if !s.enabled {
+		return nil, ErrStorageDisabled
+	}
+
+	for _, content := range contents {
+		if err := s.checkSize(int64(len(content))); err != nil {
+			return nil, err
+		}
+		if err := s.checkEmpty(int64(len(content))); err != nil {
+			return nil, err
+		}
+		if err := s.checkContentType(content); err != nil {
+			return nil, err
+		}
+	}
+
+	blobIDs := make([]string, len(contents))
+	if s.referenceCounting || s.dryRun {
+		err := s.runParallel(ctx, len(contents), func(i int) error {
+			blobID, err := s.store(ctx, contents[i], putOptions{})
+			blobIDs[i] = blobID
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return blobIDs, nil
+	}
+
+	// Index of the first occurrence of each blob not known to be stored
+	pending := make(map[string]int)
+	var unchecked []string
+	for i, content := range contents {
+		blobIDs[i] = s.computeBlobID(content)
+		if _, ok := pending[blobIDs[i]]; ok {
+			continue
+		}
+		if !s.skipDedupCheck && s.dedupCache.contains(blobIDs[i]) {
+			s.recordStore(true)
+			continue
+		}
+		pending[blobIDs[i]] = i
+		unchecked = append(unchecked, blobIDs[i])
+	}
+
+	exists := make(map[string]bool, len(unchecked))
+	if !s.skipDedupCheck {
+		exists, err = s.existsBatch(ctx, unchecked, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check blob existence: %w", err)
+		}
+	}
+
+	var missing []int
+	for _, blobID := range unchecked {
+		s.recordStore(exists[blobID])
+		if exists[blobID] {
+			s.dedupCache.add(blobID)
+			continue
+		}
+		missing = append(missing, pending[blobID])
+	}
+
+	err = s.runParallel(ctx, len(missing), func(i int) error {
+		ctx, cancel := context.WithTimeout(ctx, s.opTimeout(s.uploadTimeout))
+		defer cancel()
+
+		if err := s.acquireOp(ctx, "StoreMany"); err != nil {
+			return fmt.Errorf("failed to store blob %s: %w", blobIDs[missing[i]], err)
+		}
+		defer s.releaseOp()
+
+		if _, err := s.uploadBlob(ctx, blobIDs[missing[i]], contents[missing[i]], putOptions{}); err != nil {
+			return fmt.Errorf("failed to store blob %s: %w", blobIDs[missing[i]], err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blobIDs, nil
+}
+
+// ExistsBatch reports which of blobIDs are stored, e.g. for a deduplication
+// pre-pass, checking them concurrently with up to MaxConcurrentOps (or 8)
+// HeadObject requests at a time. Missing blobs map to false; any other
+// failure fails the call, naming the blobs that couldn't be checked.
+func (s *S3BlobStorage) ExistsBatch(ctx context.Context, blobIDs []string) (_ map[string]bool, err error) {
+	defer s.wrapError("ExistsBatch", "", &err)
+	if !s.enabled {
+		return nil, ErrStorageDisabled
+	}
+
+	return s.existsBatch(ctx, blobIDs, false)
+}
+
+// existsBatch is ExistsBatch without the Event, for StoreMany. With dedup
+// set each check follows DedupThrottlePolicy like Store's.
+func (s *S3BlobStorage) existsBatch(ctx context.Context, blobIDs []string, dedup bool) (map[string]bool, error) {
+	for _, blobID := range blobIDs {
+		if err := s.validateBlobID(blobID); err != nil {
+			return nil, err
+		}
+	}
+
+	exists := make([]bool, len(blobIDs))
+	err := s.runParallel(ctx, len(blobIDs), func(i int) error {
+		ctx, cancel := context.WithTimeout(ctx, s.opTimeout(s.metadataTimeout))
+		defer cancel()
+
+		if err := s.acquireOp(ctx, "ExistsBatch"); err != nil {
+			return fmt.Errorf("failed to check if blob %s exists: %w", blobIDs[i], err)
+		}
+		defer s.releaseOp()
+
+		found, err := s.objectExists(ctx, s.blobKey(blobIDs[i]))
+		if dedup && s.uploadsThrottled(err) {
+			found, err = false, nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check if blob %s exists: %w", blobIDs[i], err)
+		}
+		exists[i] = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]bool, len(blobIDs))
+	for i, blobID := range blobIDs {
+		result[blobID] = exists[i]
+	}
+	return result, nil
+}
@@ -0,0 +1,454 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// testBlobIDs returns n distinct blob IDs
+func testBlobIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = testBlobID(fmt.Sprintf("blob %d", i))
+	}
+	return ids
+}
+
+func TestDeleteBatch(t *testing.T) {
+	var mu sync.Mutex
+	var batchSizes []int
+	deleted := make(map[string]bool)
+	mock := &mockS3Client{
+		deleteObjectsFunc: func(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			batchSizes = append(batchSizes, len(params.Delete.Objects))
+			for _, obj := range params.Delete.Objects {
+				deleted[aws.ToString(obj.Key)] = true
+			}
+			return &s3.DeleteObjectsOutput{}, nil
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	ids := testBlobIDs(2500)
+	if err := storage.DeleteBatch(context.Background(), ids); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sort.Ints(batchSizes)
+	if fmt.Sprint(batchSizes) != "[500 1000 1000]" {
+		t.Errorf("expected batches of 1000, 1000 and 500, got %v", batchSizes)
+	}
+	for _, id := range ids {
+		if !deleted["blobs/"+id] {
+			t.Fatalf("expected %s to be deleted", id)
+		}
+	}
+}
+
+func TestDeleteBatchAggregatesErrors(t *testing.T) {
+	ids := testBlobIDs(2001)
+	mock := &mockS3Client{
+		deleteObjectsFunc: func(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+			keys := params.Delete.Objects
+			switch aws.ToString(keys[0].Key) {
+			case "blobs/" + ids[0]:
+				// First batch: one key fails
+				return &s3.DeleteObjectsOutput{Errors: []types.Error{{
+					Key:     keys[1].Key,
+					Code:    aws.String("AccessDenied"),
+					Message: aws.String("Access Denied"),
+				}}}, nil
+			case "blobs/" + ids[1000]:
+				// Second batch: the whole request fails
+				return nil, errors.New("connection reset")
+			}
+			return &s3.DeleteObjectsOutput{}, nil
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	err := storage.DeleteBatch(context.Background(), ids)
+	if err == nil {
+		t.Fatal("expected error but got none")
+	}
+	for _, want := range []string{"failed to delete blobs/" + ids[1] + ": AccessDenied", "connection reset"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %q", want, err.Error())
+		}
+	}
+}
+
+func TestDeleteBatchInvalidBlobID(t *testing.T) {
+	mock := &mockS3Client{
+		deleteObjectsFunc: func(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+			t.Error("unexpected DeleteObjects call")
+			return &s3.DeleteObjectsOutput{}, nil
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	ids := append(testBlobIDs(3), "not-a-blob-id")
+	if err := storage.DeleteBatch(context.Background(), ids); !errors.Is(err, ErrInvalidBlobID) {
+		t.Errorf("expected ErrInvalidBlobID, got %v", err)
+	}
+}
+
+func TestExistsBatch(t *testing.T) {
+	ids := testBlobIDs(50)
+	stored := make(map[string]bool)
+	for i, id := range ids {
+		if i%3 == 0 {
+			stored["blobs/"+id] = true
+		}
+	}
+
+	var mu sync.Mutex
+	heads := 0
+	mock := &mockS3Client{
+		headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			mu.Lock()
+			heads++
+			mu.Unlock()
+			if stored[aws.ToString(params.Key)] {
+				return &s3.HeadObjectOutput{}, nil
+			}
+			return nil, &smithy.GenericAPIError{Code: "NotFound"}
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	result, err := storage.ExistsBatch(context.Background(), ids)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(result) != len(ids) {
+		t.Errorf("expected %d results, got %d", len(ids), len(result))
+	}
+	for i, id := range ids {
+		if expected := i%3 == 0; result[id] != expected {
+			t.Errorf("blob %d: expected exists=%v, got %v", i, expected, result[id])
+		}
+	}
+	if heads != len(ids) {
+		t.Errorf("expected %d HeadObject calls, got %d", len(ids), heads)
+	}
+}
+
+func TestExistsBatchFailsOnError(t *testing.T) {
+	ids := testBlobIDs(10)
+	mock := &mockS3Client{
+		headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			if aws.ToString(params.Key) == "blobs/"+ids[4] {
+				return nil, &smithy.GenericAPIError{Code: "AccessDenied"}
+			}
+			return nil, &smithy.GenericAPIError{Code: "NotFound"}
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	result, err := storage.ExistsBatch(context.Background(), ids)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if result != nil {
+		t.Errorf("expected no results, got %v", result)
+	}
+	if !strings.Contains(err.Error(), ids[4]) || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("expected the error to name the failing blob, got %v", err)
+	}
+
+	if _, err := storage.ExistsBatch(context.Background(), []string{"not-a-blob-id"}); !errors.Is(err, ErrInvalidBlobID) {
+		t.Errorf("expected ErrInvalidBlobID, got %v", err)
+	}
+}
+
+func TestListQueriesEachPrefix(t *testing.T) {
+	var mu sync.Mutex
+	var prefixes []string
+	mock := &mockS3Client{
+		listObjectsFunc: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			mu.Lock()
+			prefixes = append(prefixes, aws.ToString(params.Prefix))
+			mu.Unlock()
+			return &s3.ListObjectsV2Output{}, nil
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	if _, err := storage.List(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sort.Strings(prefixes)
+	var expected []string
+	for _, c := range hexDigits {
+		expected = append(expected, blobKeyPrefix+string(c))
+	}
+	if fmt.Sprint(prefixes) != fmt.Sprint(expected) {
+		t.Errorf("expected one listing per prefix %v, got %v", expected, prefixes)
+	}
+}
+
+// latencyMock returns a client whose DeleteObjects and ListObjectsV2 calls
+// each take latency. Every prefix lists as pages pages of one key each.
+func latencyMock(latency time.Duration, pages int) *mockS3Client {
+	return &mockS3Client{
+		deleteObjectsFunc: func(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+			time.Sleep(latency)
+			return &s3.DeleteObjectsOutput{}, nil
+		},
+		listObjectsFunc: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			time.Sleep(latency)
+			page := 0
+			if params.ContinuationToken != nil {
+				_, _ = fmt.Sscan(*params.ContinuationToken, &page)
+			}
+			out := &s3.ListObjectsV2Output{
+				Contents: []types.Object{{Key: aws.String(fmt.Sprintf("%s%d", aws.ToString(params.Prefix), page))}},
+			}
+			if page+1 < pages {
+				out.IsTruncated = aws.Bool(true)
+				out.NextContinuationToken = aws.String(fmt.Sprint(page + 1))
+			}
+			return out, nil
+		},
+	}
+}
+
+// BenchmarkDeleteBatch compares deleting 8000 blobs (8 DeleteObjects calls)
+// one batch at a time against the default worker pool
+func BenchmarkDeleteBatch(b *testing.B) {
+	ids := testBlobIDs(8 * maxDeleteObjects)
+
+	for _, workers := range []int{1, defaultWorkers} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			storage := newMockS3BlobStorage(latencyMock(5*time.Millisecond, 1), "test-bucket", true)
+			storage.opSlots = make(chan struct{}, workers)
+
+			for i := 0; i < b.N; i++ {
+				if err := storage.DeleteBatch(context.Background(), ids); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkList compares listing 16 prefixes of 4 pages each sequentially
+// against the default worker pool
+func BenchmarkList(b *testing.B) {
+	for _, workers := range []int{1, defaultWorkers} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			storage := newMockS3BlobStorage(latencyMock(time.Millisecond, 4), "test-bucket", true)
+			storage.opSlots = make(chan struct{}, workers)
+
+			for i := 0; i < b.N; i++ {
+				if _, err := storage.List(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreMany(t *testing.T) {
+	mock, objects := newBucketMock()
+	objects["blobs/"+testBlobID("already stored")] = &storedObject{body: []byte("already stored")}
+
+	// The bucket mock isn't safe for concurrent use
+	var mu sync.Mutex
+	var heads, puts int
+	headObject, putObject := mock.headObjectFunc, mock.putObjectFunc
+	mock.headObjectFunc = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		heads++
+		return headObject(ctx, params, optFns...)
+	}
+	mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		puts++
+		return putObject(ctx, params, optFns...)
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	contents := [][]byte{[]byte("new 1"), []byte("already stored"), []byte("new 2"), []byte("new 1")}
+	blobIDs, err := storage.StoreMany(context.Background(), contents)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, content := range contents {
+		if blobIDs[i] != testBlobID(string(content)) {
+			t.Errorf("expected blob ID %d to be %s, got %s", i, testBlobID(string(content)), blobIDs[i])
+		}
+		if _, ok := objects["blobs/"+blobIDs[i]]; !ok {
+			t.Errorf("expected %q to be stored", content)
+		}
+	}
+	if heads != 3 {
+		t.Errorf("expected one existence check per distinct blob (3), got %d", heads)
+	}
+	if puts != 2 {
+		t.Errorf("expected only the 2 new blobs to be uploaded, got %d", puts)
+	}
+
+	// Everything is now in the dedup cache or stored
+	heads, puts = 0, 0
+	if _, err := storage.StoreMany(context.Background(), contents); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if puts != 0 {
+		t.Errorf("expected no uploads for stored content, got %d", puts)
+	}
+}
+
+func TestStoreManyErrors(t *testing.T) {
+	mock, _ := newBucketMock()
+	var mu sync.Mutex
+	putObject := mock.putObjectFunc
+	mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if *params.Key == "blobs/"+testBlobID("fails") {
+			return nil, errors.New("connection reset")
+		}
+		return putObject(ctx, params, optFns...)
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	_, err := storage.StoreMany(context.Background(), [][]byte{[]byte("ok"), []byte("fails")})
+	if err == nil || !strings.Contains(err.Error(), testBlobID("fails")) {
+		t.Errorf("expected an error naming the failed blob, got %v", err)
+	}
+
+	storage.maxBlobSize = 4
+	_, err = storage.StoreMany(context.Background(), [][]byte{[]byte("ok"), []byte("too large")})
+	var tooLarge *ErrBlobTooLarge
+	if !errors.As(err, &tooLarge) {
+		t.Errorf("expected ErrBlobTooLarge, got %v", err)
+	}
+}
+
+func TestBatchFallbacksUseCallerContext(t *testing.T) {
+	type ctxKey struct{}
+	mock, _ := newBucketMock()
+	var mu sync.Mutex
+	var missing int
+	putObject, deleteObject := mock.putObjectFunc, mock.deleteObjectFunc
+	mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if ctx.Value(ctxKey{}) == nil {
+			missing++
+		}
+		return putObject(ctx, params, optFns...)
+	}
+	mock.deleteObjectFunc = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if ctx.Value(ctxKey{}) == nil {
+			missing++
+		}
+		return deleteObject(ctx, params, optFns...)
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.referenceCounting = true
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, true)
+	blobIDs, err := storage.StoreMany(ctx, [][]byte{[]byte("one"), []byte("two")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := storage.DeleteBatch(ctx, blobIDs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if missing != 0 {
+		t.Errorf("expected every request to use the caller's context, %d didn't", missing)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := storage.StoreMany(cancelled, [][]byte{[]byte("three")}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from StoreMany, got %v", err)
+	}
+	if err := storage.DeleteBatch(cancelled, blobIDs); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from DeleteBatch, got %v", err)
+	}
+}
+
+func TestRunParallelStopsWhenCancelled(t *testing.T) {
+	storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+	storage.opSlots = make(chan struct{}, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var calls int
+	err := storage.runParallel(ctx, 10, func(i int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		cancel()
+		return nil
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	// The task queued while the first ran may still run
+	if calls > 2 {
+		t.Errorf("expected dispatch to stop once cancelled, got %d calls", calls)
+	}
+}
+
+func TestStoreManyDedupThrottlePolicy(t *testing.T) {
+	for _, tt := range []struct {
+		policy       string
+		expectError  bool
+		expectUpload bool
+	}{
+		{policy: ThrottlePolicyFail, expectError: true},
+		{policy: ThrottlePolicyUpload, expectUpload: true},
+	} {
+		t.Run(tt.policy, func(t *testing.T) {
+			mock, objects := newBucketMock()
+			var mu sync.Mutex
+			putObject := mock.putObjectFunc
+			mock.headObjectFunc = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				return nil, &smithy.GenericAPIError{Code: "SlowDown"}
+			}
+			mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				return putObject(ctx, params, optFns...)
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			storage.dedupThrottlePolicy = tt.policy
+
+			_, err := storage.StoreMany(context.Background(), [][]byte{[]byte("one"), []byte("two")})
+			if tt.expectError != (err != nil) {
+				t.Errorf("expected error=%v, got %v", tt.expectError, err)
+			}
+			if uploaded := len(objects) == 2; uploaded != tt.expectUpload {
+				t.Errorf("expected upload=%v, got %d objects", tt.expectUpload, len(objects))
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// defaultCircuitBreakerCooldown is the circuit breaker cooldown in seconds
+// when CircuitBreakerCooldown isn't set
+const defaultCircuitBreakerCooldown = 30
+
+// maxCooldownFactor caps how far the circuit breaker's cooldown grows while
+// probes keep failing, as a multiple of the configured cooldown
+const maxCooldownFactor = 16
+
+// circuitBreaker fails operations fast while the backend looks down. After
+// threshold consecutive failed requests it opens for a cooldown, then lets a
+// single probe through: success closes it, failure reopens it with the
+// cooldown doubled. A nil *circuitBreaker is disabled.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+	wait     time.Duration
+	retryAt  time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, wait: cooldown, now: time.Now}
+}
+
+// allow returns ErrCircuitOpen while the breaker is open. Once the cooldown
+// has passed one caller is let through as a probe; if the probe makes no
+// request, another is let through after a further cooldown.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return nil
+	}
+	now := b.now()
+	if now.Before(b.retryAt) {
+		return ErrCircuitOpen
+	}
+	b.retryAt = now.Add(b.wait)
+	return nil
+}
+
+// record updates the breaker with the outcome of a request
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isBackendFailure(err) {
+		b.failures = 0
+		b.open = false
+		b.wait = b.cooldown
+		return
+	}
+
+	b.failures++
+	if b.failures < b.threshold {
+		return
+	}
+	if b.open {
+		// A probe failed, so back off further
+		b.wait = min(b.wait*2, b.cooldown*maxCooldownFactor)
+	}
+	b.open = true
+	b.retryAt = b.now().Add(b.wait)
+}
+
+// isBackendFailure reports whether err suggests the backend is unavailable,
+// as opposed to a response such as NotFound from a healthy backend or the
+// caller giving up
+func isBackendFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode() >= 500 || isThrottleError(err)
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorFault() == smithy.FaultServer || isThrottleError(err)
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// newBreakerStorage returns storage whose requests go through a circuit
+// breaker with a controllable clock, and a func advancing that clock
+func newBreakerStorage(mock S3Api, threshold int, cooldown time.Duration) (*S3BlobStorage, func(time.Duration)) {
+	now := time.Now()
+	breaker := newCircuitBreaker(threshold, cooldown)
+	breaker.now = func() time.Time { return now }
+
+	storage := newMockS3BlobStorage(&instrumentedClient{next: mock, metrics: NoopMetrics{}, breaker: breaker}, "test-bucket", true)
+	storage.breaker = breaker
+	return storage, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	down := true
+	calls := 0
+	mock := &mockS3Client{
+		headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			calls++
+			if down {
+				return nil, errors.New("dial tcp: connection refused")
+			}
+			return nil, &smithy.GenericAPIError{Code: "NotFound"}
+		},
+	}
+	storage, advance := newBreakerStorage(mock, 3, 10*time.Second)
+	blobID := testBlobID("content")
+
+	// Failures below the threshold reach the backend
+	for i := 0; i < 3; i++ {
+		if _, err := storage.Exists(blobID); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("request %d: expected a backend error, got %v", i, err)
+		}
+	}
+
+	// The breaker is now open and fails fast
+	if _, err := storage.Exists(blobID); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 backend calls, got %d", calls)
+	}
+
+	// After the cooldown a failing probe reopens it with a doubled cooldown
+	advance(10 * time.Second)
+	if _, err := storage.Exists(blobID); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the probe to reach the backend, got %v", err)
+	}
+	advance(10 * time.Second)
+	if _, err := storage.Exists(blobID); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen during the doubled cooldown, got %v", err)
+	}
+
+	// A successful probe closes it
+	down = false
+	advance(10 * time.Second)
+	if _, err := storage.Exists(blobID); err != nil {
+		t.Fatalf("expected the probe to succeed, got %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := storage.Exists(blobID); err != nil {
+			t.Fatalf("expected the breaker to be closed, got %v", err)
+		}
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	mock := &mockS3Client{
+		headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return nil, &smithyhttp.ResponseError{Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusForbidden}}}
+		},
+	}
+	storage, _ := newBreakerStorage(mock, 2, time.Minute)
+
+	for i := 0; i < 5; i++ {
+		if _, err := storage.Exists(testBlobID("content")); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("request %d: expected 4xx responses not to open the breaker", i)
+		}
+	}
+}
+
+func TestIsBackendFailure(t *testing.T) {
+	status := func(code int) error {
+		return &smithyhttp.ResponseError{Response: &smithyhttp.Response{Response: &http.Response{StatusCode: code}}}
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "success", err: nil, expected: false},
+		{name: "network error", err: errors.New("connection refused"), expected: true},
+		{name: "timeout", err: context.DeadlineExceeded, expected: true},
+		{name: "canceled by caller", err: context.Canceled, expected: false},
+		{name: "server error", err: status(http.StatusBadGateway), expected: true},
+		{name: "not found", err: status(http.StatusNotFound), expected: false},
+		{name: "API error", err: &smithy.GenericAPIError{Code: "NoSuchKey"}, expected: false},
+		{name: "API server fault", err: &smithy.GenericAPIError{Code: "InternalError", Fault: smithy.FaultServer}, expected: true},
+		{name: "throttled", err: status(http.StatusTooManyRequests), expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBackendFailure(tt.err); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
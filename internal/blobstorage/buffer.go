@@ -0,0 +1,43 @@
+package blobstorage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// RetrieveInto resets buf and reads a blob into it, so callers can reuse
+// pooled buffers across requests instead of allocating per retrieve. With
+// MaxBlobSize set, reading stops one byte past the limit and fails with
+// ErrBlobTooLarge, so an unexpectedly large object can't grow a pooled
+// buffer without bound. On error buf is left empty.
+func (s *S3BlobStorage) RetrieveInto(blobID string, buf *bytes.Buffer) (err error) {
+	defer s.wrapError("RetrieveInto", blobID, &err)
+	if !s.enabled {
+		return ErrStorageDisabled
+	}
+
+	buf.Reset()
+	defer func() {
+		if err != nil {
+			buf.Reset()
+		}
+	}()
+
+	r, err := s.retrieveReader(s.ctx, blobID)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+
+	src := io.Reader(r)
+	if s.maxBlobSize > 0 {
+		src = io.LimitReader(r, s.maxBlobSize+1)
+	}
+
+	size, err := buf.ReadFrom(src)
+	if err != nil {
+		return fmt.Errorf("failed to read blob data: %w", err)
+	}
+	return s.checkSize(size)
+}
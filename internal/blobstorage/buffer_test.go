@@ -0,0 +1,61 @@
+package blobstorage
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRetrieveInto(t *testing.T) {
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	content := "pooled buffer content"
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		blobID         string
+		maxBlobSize    int64
+		expected       string
+		expectTooLarge bool
+		expectError    error
+	}{
+		{name: "retrieves into the buffer", blobID: blobID, expected: content},
+		{name: "within the size limit", blobID: blobID, maxBlobSize: int64(len(content)), expected: content},
+		{name: "over the size limit", blobID: blobID, maxBlobSize: 8, expectTooLarge: true},
+		{name: "missing blob", blobID: testBlobID("missing"), expectError: ErrBlobNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage.maxBlobSize = tt.maxBlobSize
+
+			// Stale content from a previous use of a pooled buffer
+			var buf bytes.Buffer
+			buf.WriteString("stale")
+
+			err := storage.RetrieveInto(tt.blobID, &buf)
+			switch {
+			case tt.expectTooLarge:
+				var tooLarge *ErrBlobTooLarge
+				if !errors.As(err, &tooLarge) {
+					t.Errorf("expected ErrBlobTooLarge, got %v", err)
+				}
+			case tt.expectError != nil:
+				if !errors.Is(err, tt.expectError) {
+					t.Errorf("expected %v, got %v", tt.expectError, err)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if buf.String() != tt.expected {
+				t.Errorf("expected buffer %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
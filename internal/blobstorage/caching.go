@@ -0,0 +1,299 @@
+package blobstorage
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// CachingBlobStorage wraps a BlobStorage with a read-through cache on local
+// disk, so hot blobs are served without a round trip to the backend. Blobs
+// are immutable by hash, so cached copies never go stale; the cache only
+// evicts the least recently retrieved blobs once it exceeds its size bound.
+// Writes and existence checks go straight to the wrapped storage.
+//
+// While the wrapped storage's circuit breaker is open, cached blobs are
+// still served, and every call that needs the backend fails with
+// ErrBackendDegraded, so hot content keeps serving during an outage.
+type CachingBlobStorage struct {
+	backend  BlobStorage
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	order   *list.List // most recently used at the front
+	entries map[string]*list.Element
+	used    int64
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+var _ BlobStorage = (*CachingBlobStorage)(nil)
+
+// cacheEntry is a blob held in the disk cache
+type cacheEntry struct {
+	blobID string
+	size   int64
+}
+
+// NewCachingBlobStorage creates a cache of up to maxBytes in dir in front of
+// backend. Blobs already in dir, e.g. from before a restart, are kept and
+// ordered by modification time; dir should not be shared with anything else.
+func NewCachingBlobStorage(backend BlobStorage, dir string, maxBytes int64) (*CachingBlobStorage, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("invalid cache size %d: must be positive", maxBytes)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	c := &CachingBlobStorage{
+		backend:  backend,
+		dir:      dir,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// IsEnabled reports whether the wrapped storage is enabled
+func (c *CachingBlobStorage) IsEnabled() bool {
+	return c.backend.IsEnabled()
+}
+
+// Store stores content in the wrapped storage
+func (c *CachingBlobStorage) Store(content string) (string, error) {
+	blobID, err := c.backend.Store(content)
+	return blobID, degraded(err)
+}
+
+// Retrieve returns a blob from the disk cache, or on a miss from the wrapped
+// storage, caching it for next time
+func (c *CachingBlobStorage) Retrieve(blobID string) (string, error) {
+	if !isCacheableBlobID(blobID) {
+		content, err := c.backend.Retrieve(blobID)
+		return content, degraded(err)
+	}
+
+	if data, ok := c.get(blobID); ok {
+		c.hits.Add(1)
+		return string(data), nil
+	}
+	c.misses.Add(1)
+
+	content, err := c.backend.Retrieve(blobID)
+	if err != nil {
+		return "", degraded(err)
+	}
+
+	// A failed cache write only costs a later miss
+	_ = c.put(blobID, []byte(content))
+	return content, nil
+}
+
+// Delete deletes a blob from the wrapped storage and drops any cached copy
+func (c *CachingBlobStorage) Delete(blobID string) error {
+	if isCacheableBlobID(blobID) {
+		c.remove(blobID)
+	}
+	return degraded(c.backend.Delete(blobID))
+}
+
+// Exists checks the wrapped storage for a blob
+func (c *CachingBlobStorage) Exists(blobID string) (bool, error) {
+	exists, err := c.backend.Exists(blobID)
+	return exists, degraded(err)
+}
+
+// CacheHits returns how many retrieves were served from the disk cache
+func (c *CachingBlobStorage) CacheHits() int64 {
+	return c.hits.Load()
+}
+
+// CacheMisses returns how many retrieves fell through to the wrapped storage
+func (c *CachingBlobStorage) CacheMisses() int64 {
+	return c.misses.Load()
+}
+
+// CachedBytes returns the total size of the blobs currently cached
+func (c *CachingBlobStorage) CachedBytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.used
+}
+
+// get reads a cached blob, marking it as recently used
+func (c *CachingBlobStorage) get(blobID string) ([]byte, bool) {
+	f, ok := c.open(blobID)
+	if !ok {
+		return nil, false
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// open opens a cached blob, marking it as recently used. The file is opened
+// under c.mu so a concurrent eviction or remove can't delete it first; once
+// open it stays readable even if it is removed. A cached file that has gone
+// missing is dropped and reported as a miss.
+func (c *CachingBlobStorage) open(blobID string) (*os.File, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[blobID]
+	if !ok {
+		return nil, false
+	}
+	f, err := os.Open(c.path(blobID))
+	if err != nil {
+		c.drop(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return f, true
+}
+
+// put writes a blob to the cache, evicting the least recently used blobs to
+// stay within maxBytes. Blobs larger than the whole cache are not cached.
+func (c *CachingBlobStorage) put(blobID string, data []byte) error {
+	size := int64(len(data))
+	if size > c.maxBytes {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[blobID]; ok {
+		// Cached concurrently; identical content by hash
+		c.order.MoveToFront(elem)
+		_ = os.Remove(tmp.Name())
+		return nil
+	}
+
+	if err := os.Rename(tmp.Name(), c.path(blobID)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to move cache file into place: %w", err)
+	}
+	c.add(blobID, size)
+	return nil
+}
+
+// add records a cached blob and evicts until the cache fits. The caller must
+// hold c.mu.
+func (c *CachingBlobStorage) add(blobID string, size int64) {
+	c.entries[blobID] = c.order.PushFront(&cacheEntry{blobID: blobID, size: size})
+	c.used += size
+
+	for c.used > c.maxBytes {
+		c.drop(c.order.Back())
+	}
+}
+
+// remove drops a blob from the cache
+func (c *CachingBlobStorage) remove(blobID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[blobID]; ok {
+		c.drop(elem)
+	}
+}
+
+// drop removes a cached blob and its file. The caller must hold c.mu.
+func (c *CachingBlobStorage) drop(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.order.Remove(elem)
+	delete(c.entries, entry.blobID)
+	c.used -= entry.size
+	_ = os.Remove(c.path(entry.blobID))
+}
+
+// load indexes blobs already in the cache directory, oldest first so the
+// most recently written end up most recently used
+func (c *CachingBlobStorage) load() error {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	type cachedFile struct {
+		blobID string
+		info   os.FileInfo
+	}
+	var files []cachedFile
+	for _, de := range dirEntries {
+		if !de.Type().IsRegular() || !isCacheableBlobID(de.Name()) {
+			continue
+		}
+		info, err := de.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read cache directory: %w", err)
+		}
+		files = append(files, cachedFile{blobID: de.Name(), info: info})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].info.ModTime().Before(files[j].info.ModTime())
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range files {
+		c.add(f.blobID, f.info.Size())
+	}
+	return nil
+}
+
+// path returns the cache file holding blobID
+func (c *CachingBlobStorage) path(blobID string) string {
+	return filepath.Join(c.dir, blobID)
+}
+
+// degraded marks err with ErrBackendDegraded if the backend failed fast
+// because its circuit breaker is open
+func degraded(err error) error {
+	if errors.Is(err, ErrCircuitOpen) {
+		return fmt.Errorf("%w: %w", ErrBackendDegraded, err)
+	}
+	return err
+}
+
+// isCacheableBlobID reports whether blobID is a hex digest, and so safe to
+// use as a file name
+func isCacheableBlobID(blobID string) bool {
+	return blobID != "" && isHexDigest(blobID, len(blobID)/2)
+}
@@ -0,0 +1,283 @@
+package blobstorage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingBlobStorage counts the retrieves that reach the wrapped storage
+type countingBlobStorage struct {
+	BlobStorage
+	retrieves int
+}
+
+func (c *countingBlobStorage) Retrieve(blobID string) (string, error) {
+	c.retrieves++
+	return c.BlobStorage.Retrieve(blobID)
+}
+
+func newTestCache(t *testing.T, maxBytes int64) (*CachingBlobStorage, *countingBlobStorage) {
+	t.Helper()
+	backend := &countingBlobStorage{BlobStorage: NewMemoryBlobStorage()}
+	cache, err := NewCachingBlobStorage(backend, t.TempDir(), maxBytes)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	return cache, backend
+}
+
+func TestCachingBlobStorageReadThrough(t *testing.T) {
+	cache, backend := newTestCache(t, 1024)
+	content := "hot attachment"
+
+	blobID, err := cache.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected store error: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		retrieved, err := cache.Retrieve(blobID)
+		if err != nil {
+			t.Fatalf("unexpected retrieve error: %v", err)
+		}
+		if retrieved != content {
+			t.Errorf("expected content=%q, got %q", content, retrieved)
+		}
+	}
+
+	if backend.retrieves != 1 {
+		t.Errorf("expected 1 backend retrieve, got %d", backend.retrieves)
+	}
+	if cache.CacheHits() != 2 || cache.CacheMisses() != 1 {
+		t.Errorf("expected 2 hits and 1 miss, got %d and %d", cache.CacheHits(), cache.CacheMisses())
+	}
+	if cache.CachedBytes() != int64(len(content)) {
+		t.Errorf("expected %d cached bytes, got %d", len(content), cache.CachedBytes())
+	}
+
+	if err := cache.Delete(blobID); err != nil {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+	if _, err := cache.Retrieve(blobID); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound after delete, got %v", err)
+	}
+	if cache.CachedBytes() != 0 {
+		t.Errorf("expected an empty cache after delete, got %d bytes", cache.CachedBytes())
+	}
+}
+
+func TestCachingBlobStorageEvictsLeastRecentlyUsed(t *testing.T) {
+	cache, backend := newTestCache(t, 30)
+
+	ids := make([]string, 3)
+	for i, content := range []string{strings.Repeat("a", 10), strings.Repeat("b", 10), strings.Repeat("c", 10)} {
+		var err error
+		if ids[i], err = cache.Store(content); err != nil {
+			t.Fatalf("unexpected store error: %v", err)
+		}
+		if _, err := cache.Retrieve(ids[i]); err != nil {
+			t.Fatalf("unexpected retrieve error: %v", err)
+		}
+	}
+
+	// Touch the oldest so the second becomes least recently used
+	if _, err := cache.Retrieve(ids[0]); err != nil {
+		t.Fatalf("unexpected retrieve error: %v", err)
+	}
+
+	overflow, err := cache.Store(strings.Repeat("d", 10))
+	if err != nil {
+		t.Fatalf("unexpected store error: %v", err)
+	}
+	if _, err := cache.Retrieve(overflow); err != nil {
+		t.Fatalf("unexpected retrieve error: %v", err)
+	}
+
+	if cache.CachedBytes() != 30 {
+		t.Errorf("expected 30 cached bytes, got %d", cache.CachedBytes())
+	}
+	if _, err := os.Stat(cache.path(ids[1])); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the least recently used blob's file to be removed, got %v", err)
+	}
+
+	before := backend.retrieves
+	for _, id := range []string{ids[0], ids[2], overflow} {
+		if _, err := cache.Retrieve(id); err != nil {
+			t.Fatalf("unexpected retrieve error: %v", err)
+		}
+	}
+	if backend.retrieves != before {
+		t.Errorf("expected the remaining blobs to be served from cache")
+	}
+	if _, err := cache.Retrieve(ids[1]); err != nil {
+		t.Fatalf("unexpected retrieve error: %v", err)
+	}
+	if backend.retrieves != before+1 {
+		t.Errorf("expected the evicted blob to be fetched from the backend")
+	}
+}
+
+func TestCachingBlobStorageSkipsOversizedBlobs(t *testing.T) {
+	cache, backend := newTestCache(t, 4)
+
+	blobID, err := cache.Store("larger than the cache")
+	if err != nil {
+		t.Fatalf("unexpected store error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Retrieve(blobID); err != nil {
+			t.Fatalf("unexpected retrieve error: %v", err)
+		}
+	}
+
+	if backend.retrieves != 2 || cache.CachedBytes() != 0 {
+		t.Errorf("expected oversized blobs to bypass the cache, got %d backend retrieves and %d cached bytes", backend.retrieves, cache.CachedBytes())
+	}
+}
+
+func TestCachingBlobStorageReloadsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	backend := NewMemoryBlobStorage()
+	content := "survives a restart"
+
+	first, err := NewCachingBlobStorage(backend, dir, 1024)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	blobID, _ := first.Store(content)
+	if _, err := first.Retrieve(blobID); err != nil {
+		t.Fatalf("unexpected retrieve error: %v", err)
+	}
+
+	// Stray files that aren't blobs are ignored
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a blob"), 0o600); err != nil {
+		t.Fatalf("failed to write stray file: %v", err)
+	}
+
+	second, err := NewCachingBlobStorage(backend, dir, 1024)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	if second.CachedBytes() != int64(len(content)) {
+		t.Errorf("expected %d cached bytes, got %d", len(content), second.CachedBytes())
+	}
+	if retrieved, err := second.Retrieve(blobID); err != nil || retrieved != content {
+		t.Errorf("expected content=%q, got %q (err=%v)", content, retrieved, err)
+	}
+	if second.CacheHits() != 1 {
+		t.Errorf("expected a cache hit, got %d", second.CacheHits())
+	}
+}
+
+func TestCachingBlobStorageInvalidBlobID(t *testing.T) {
+	cache, backend := newTestCache(t, 1024)
+
+	if _, err := cache.Retrieve("../../etc/passwd"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected the backend's ErrBlobNotFound, got %v", err)
+	}
+	if backend.retrieves != 1 || cache.CacheMisses() != 0 {
+		t.Errorf("expected invalid IDs to bypass the cache")
+	}
+}
+
+func TestCachingBlobStorageDegraded(t *testing.T) {
+	mock, _ := newBucketMock()
+	storage, _ := newBreakerStorage(mock, 1, time.Minute)
+	cache, err := NewCachingBlobStorage(storage, t.TempDir(), 1024)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+
+	cached, err := cache.Store("hot content")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := cache.Retrieve(cached); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	uncached, err := cache.Store("cold content")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The backend goes down, opening the circuit
+	storage.breaker.record(errors.New("dial tcp: connection refused"))
+
+	content, err := cache.Retrieve(cached)
+	if err != nil {
+		t.Fatalf("expected the cached blob to be served, got %v", err)
+	}
+	if content != "hot content" {
+		t.Errorf("expected %q, got %q", "hot content", content)
+	}
+
+	calls := map[string]func() error{
+		"Retrieve": func() error {
+			_, err := cache.Retrieve(uncached)
+			return err
+		},
+		"Store": func() error {
+			_, err := cache.Store("new content")
+			return err
+		},
+		"Exists": func() error {
+			_, err := cache.Exists(cached)
+			return err
+		},
+		"Delete": func() error {
+			return cache.Delete(uncached)
+		},
+	}
+	for name, call := range calls {
+		err := call()
+		if !errors.Is(err, ErrBackendDegraded) || !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("%s: expected ErrBackendDegraded, got %v", name, err)
+		}
+	}
+}
+
+func TestCachingBlobStorageServesBlobEvictedWhileReading(t *testing.T) {
+	cache, backend := newTestCache(t, 1024)
+	content := "hot attachment"
+
+	blobID, err := cache.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected store error: %v", err)
+	}
+	if _, err := cache.Retrieve(blobID); err != nil {
+		t.Fatalf("unexpected retrieve error: %v", err)
+	}
+
+	// An eviction between the lookup and the read must not fail the hit
+	f, ok := cache.open(blobID)
+	if !ok {
+		t.Fatal("expected the blob to be cached")
+	}
+	defer f.Close()
+	cache.remove(blobID)
+
+	data, err := io.ReadAll(f)
+	if err != nil || string(data) != content {
+		t.Errorf("expected the open cached file to stay readable, got %q, %v", data, err)
+	}
+
+	// A cached file that has gone missing is a miss served by the backend
+	if _, err := cache.Retrieve(blobID); err != nil {
+		t.Fatalf("unexpected retrieve error: %v", err)
+	}
+	if err := os.Remove(cache.path(blobID)); err != nil {
+		t.Fatalf("failed to remove cache file: %v", err)
+	}
+	retrieved, err := cache.Retrieve(blobID)
+	if err != nil || retrieved != content {
+		t.Errorf("expected a fallthrough to the backend, got %q, %v", retrieved, err)
+	}
+	if backend.retrieves != 3 {
+		t.Errorf("expected 3 backend retrieves, got %d", backend.retrieves)
+	}
+}
@@ -0,0 +1,160 @@
+package blobstorage
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestUploadChecksum(t *testing.T) {
+	content := "content verified by S3"
+	sha := sha256.Sum256([]byte(content))
+	crc := binary.BigEndian.AppendUint32(nil, crc32.Checksum([]byte(content), crc32.MakeTable(crc32.Castagnoli)))
+
+	tests := []struct {
+		name        string
+		checksum    string
+		stream      bool
+		expectedAlg types.ChecksumAlgorithm
+		expectedSHA string
+		expectedCRC string
+	}{
+		{name: "disabled", checksum: ""},
+		{name: "SHA256", checksum: ChecksumSHA256, expectedAlg: types.ChecksumAlgorithmSha256, expectedSHA: base64.StdEncoding.EncodeToString(sha[:])},
+		{name: "SHA256 streamed", checksum: ChecksumSHA256, stream: true, expectedAlg: types.ChecksumAlgorithmSha256, expectedSHA: base64.StdEncoding.EncodeToString(sha[:])},
+		{name: "CRC32C", checksum: ChecksumCRC32C, expectedAlg: types.ChecksumAlgorithmCrc32c, expectedCRC: base64.StdEncoding.EncodeToString(crc)},
+		{name: "CRC32C streamed keeps SDK default", checksum: ChecksumCRC32C, stream: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input *s3.PutObjectInput
+			mock, _ := newBucketMock()
+			putObject := mock.putObjectFunc
+			mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				input = params
+				return putObject(ctx, params, optFns...)
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			storage.uploadChecksum = tt.checksum
+
+			var err error
+			if tt.stream {
+				_, err = storage.StoreReader(strings.NewReader(content))
+			} else {
+				_, err = storage.Store(content)
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if input.ChecksumAlgorithm != tt.expectedAlg {
+				t.Errorf("expected ChecksumAlgorithm=%q, got %q", tt.expectedAlg, input.ChecksumAlgorithm)
+			}
+			if got := aws.ToString(input.ChecksumSHA256); got != tt.expectedSHA {
+				t.Errorf("expected ChecksumSHA256=%q, got %q", tt.expectedSHA, got)
+			}
+			if got := aws.ToString(input.ChecksumCRC32C); got != tt.expectedCRC {
+				t.Errorf("expected ChecksumCRC32C=%q, got %q", tt.expectedCRC, got)
+			}
+		})
+	}
+}
+
+func TestUploadChecksumCoversEncodedBytes(t *testing.T) {
+	var input *s3.PutObjectInput
+	var stored []byte
+	mock, _ := newBucketMock()
+	mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		input = params
+		stored, _ = io.ReadAll(params.Body)
+		return &s3.PutObjectOutput{}, nil
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.uploadChecksum = ChecksumSHA256
+	storage.compression = CompressionGzip
+
+	if _, err := storage.Store("compressible compressible compressible"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sum := sha256.Sum256(stored)
+	if expected := base64.StdEncoding.EncodeToString(sum[:]); aws.ToString(input.ChecksumSHA256) != expected {
+		t.Errorf("expected the checksum of the compressed bytes %q, got %q", expected, aws.ToString(input.ChecksumSHA256))
+	}
+}
+
+func TestSendContentMD5(t *testing.T) {
+	md5Of := func(data string) string {
+		sum := md5.Sum([]byte(data))
+		return base64.StdEncoding.EncodeToString(sum[:])
+	}
+
+	t.Run("sent on PutObject and each part", func(t *testing.T) {
+		mock, _ := newBucketMock()
+		var sent []string
+		putObject, uploadPart := mock.putObjectFunc, mock.uploadPartFunc
+		mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			sent = append(sent, aws.ToString(params.ContentMD5))
+			return putObject(ctx, params, optFns...)
+		}
+		mock.uploadPartFunc = func(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+			sent = append(sent, aws.ToString(params.ContentMD5))
+			return uploadPart(ctx, params, optFns...)
+		}
+		storage := newMultipartTestStorage(mock)
+		storage.sendContentMD5 = true
+
+		if _, err := storage.Store("small"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := storage.Store("0123456789abcdefXYZ"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := []string{md5Of("small"), md5Of("01234567"), md5Of("89abcdef"), md5Of("XYZ")}
+		if strings.Join(sent, ",") != strings.Join(expected, ",") {
+			t.Errorf("expected Content-MD5 %v, got %v", expected, sent)
+		}
+	})
+
+	t.Run("not sent by default", func(t *testing.T) {
+		mock, _ := newBucketMock()
+		putObject := mock.putObjectFunc
+		mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			if params.ContentMD5 != nil {
+				t.Errorf("expected no Content-MD5, got %q", *params.ContentMD5)
+			}
+			return putObject(ctx, params, optFns...)
+		}
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+		if _, err := storage.Store("small"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("BadDigest surfaces as ErrBadDigest", func(t *testing.T) {
+		mock, _ := newBucketMock()
+		mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			return nil, &smithy.GenericAPIError{Code: "BadDigest", Message: "The Content-MD5 you specified did not match what we received."}
+		}
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+		storage.sendContentMD5 = true
+
+		if _, err := storage.Store("corrupted by a proxy"); !errors.Is(err, ErrBadDigest) {
+			t.Errorf("expected ErrBadDigest, got %v", err)
+		}
+	})
+}
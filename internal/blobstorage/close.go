@@ -0,0 +1,47 @@
+package blobstorage
+
+import (
+	"net/http"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
+// Close cancels in-flight operations and closes idle HTTP connections, e.g.
+// when the storage is replaced on a config reload. Later operations fail
+// with ErrClosed. Closing more than once is a no-op.
+func (s *S3BlobStorage) Close() error {
+	if !s.enabled || s.closed.Swap(true) {
+		return nil
+	}
+
+	s.cancel()
+	if s.httpClient != nil {
+		s.httpClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// checkOpen returns ErrClosed once Close has been called
+func (s *S3BlobStorage) checkOpen() error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	return nil
+}
+
+// newDefaultHTTPClient builds an HTTP client equivalent to the SDK default
+// but whose transport we hold, since the SDK's own client only exposes
+// copies of its transport and so can't have its idle connections closed
+func newDefaultHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: awshttp.NewBuildableClient().GetTransport(),
+		// Like the SDK, only follow redirects that preserve the method and body
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			switch req.Response.StatusCode {
+			case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+				return nil
+			}
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,139 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestCloseRejectsFurtherOperations(t *testing.T) {
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	blobID, err := storage.Store("stored before close")
+	if err != nil {
+		t.Fatalf("failed to store: %v", err)
+	}
+
+	if err := storage.Close(); err != nil {
+		t.Fatalf("unexpected error from Close: %v", err)
+	}
+
+	ops := map[string]func() error{
+		"Store":    func() error { _, err := storage.Store("content"); return err },
+		"Retrieve": func() error { _, err := storage.Retrieve(blobID); return err },
+		"Exists":   func() error { _, err := storage.Exists(blobID); return err },
+		"Delete":   func() error { return storage.Delete(blobID) },
+		"List":     func() error { _, err := storage.List(context.Background()); return err },
+		"RetrieveReader": func() error {
+			_, err := storage.RetrieveReader(context.Background(), blobID)
+			return err
+		},
+		"PresignGetURL": func() error { _, err := storage.PresignGetURL(blobID, time.Minute); return err },
+	}
+	for name, op := range ops {
+		if err := op(); !errors.Is(err, ErrClosed) {
+			t.Errorf("%s: expected ErrClosed, got %v", name, err)
+		}
+	}
+}
+
+func TestCloseIsIdempotent(t *testing.T) {
+	storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+
+	for i := 0; i < 2; i++ {
+		if err := storage.Close(); err != nil {
+			t.Fatalf("unexpected error from Close #%d: %v", i+1, err)
+		}
+	}
+
+	disabled := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", false)
+	if err := disabled.Close(); err != nil {
+		t.Errorf("unexpected error closing disabled storage: %v", err)
+	}
+}
+
+func TestCloseCancelsInFlightOperations(t *testing.T) {
+	started := make(chan struct{})
+	mock := &mockS3Client{
+		getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := storage.Retrieve(testBlobID("attachment"))
+		errCh <- err
+	}()
+
+	<-started
+	storage.Close()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Retrieve did not return after Close")
+	}
+}
+
+func TestCloseStopsOpenReaders(t *testing.T) {
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	blobID, err := storage.Store("read after close")
+	if err != nil {
+		t.Fatalf("failed to store: %v", err)
+	}
+
+	r, err := storage.RetrieveReader(context.Background(), blobID)
+	if err != nil {
+		t.Fatalf("failed to open reader: %v", err)
+	}
+	defer r.Close()
+
+	storage.Close()
+
+	// Close cancels open readers asynchronously
+	select {
+	case <-r.(*blobReader).r.(*contextReader).ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("reader context was not canceled by Close")
+	}
+
+	if _, err := io.ReadAll(r); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled reading after Close, got %v", err)
+	}
+}
+
+func TestDefaultHTTPClientRedirects(t *testing.T) {
+	client := newDefaultHTTPClient()
+
+	tests := []struct {
+		status int
+		follow bool
+	}{
+		{http.StatusMovedPermanently, false},
+		{http.StatusFound, false},
+		{http.StatusTemporaryRedirect, true},
+		{http.StatusPermanentRedirect, true},
+	}
+	for _, tt := range tests {
+		req := &http.Request{Response: &http.Response{StatusCode: tt.status}}
+		err := client.CheckRedirect(req, nil)
+		if followed := err == nil; followed != tt.follow {
+			t.Errorf("status %d: expected follow=%v, got error %v", tt.status, tt.follow, err)
+		}
+	}
+}
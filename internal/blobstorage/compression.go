@@ -0,0 +1,50 @@
+package blobstorage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+const (
+	// CompressionNone stores blob contents as is
+	CompressionNone = "none"
+	// CompressionGzip gzip-compresses blob contents before upload
+	CompressionGzip = "gzip"
+)
+
+// metaEncoding is the object metadata key (x-amz-meta-encoding) recording
+// the compression applied to the stored bytes. Blobs without it are stored
+// uncompressed.
+const metaEncoding = "encoding"
+
+// gzipBlob compresses data with gzip
+func gzipBlob(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress blob: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress blob: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// gunzipBlob decompresses data produced by gzipBlob
+func gunzipBlob(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress blob: %w", err)
+	}
+	defer func() {
+		_ = zr.Close()
+	}()
+
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress blob: %w", err)
+	}
+	return out, nil
+}
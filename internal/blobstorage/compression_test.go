@@ -0,0 +1,109 @@
+package blobstorage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestNewS3BlobStorageInvalidCompression(t *testing.T) {
+	_, err := NewS3BlobStorage(Config{
+		Enabled:     true,
+		AccessKey:   "access",
+		SecretKey:   "secret",
+		Compression: "brotli",
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid compression") {
+		t.Errorf("expected invalid compression error, got %v", err)
+	}
+}
+
+func TestStoreRetrieveWithGzip(t *testing.T) {
+	content := strings.Repeat("<tr><td>name</td><td>value</td></tr>\n", 200)
+	hash := sha256.Sum256([]byte(content))
+	expectedBlobID := hex.EncodeToString(hash[:])
+
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.compression = CompressionGzip
+
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected store error: %v", err)
+	}
+
+	if blobID != expectedBlobID {
+		t.Errorf("expected blobID=%q, got %q", expectedBlobID, blobID)
+	}
+
+	stored := objects["blobs/"+blobID]
+	if stored.metadata[metaEncoding] != CompressionGzip {
+		t.Errorf("expected encoding metadata %q, got %q", CompressionGzip, stored.metadata[metaEncoding])
+	}
+	if len(stored.body) >= len(content) {
+		t.Errorf("expected compressed size < %d, got %d", len(content), len(stored.body))
+	}
+
+	retrieved, err := storage.Retrieve(blobID)
+	if err != nil {
+		t.Fatalf("unexpected retrieve error: %v", err)
+	}
+	if retrieved != content {
+		t.Error("retrieved content does not match original")
+	}
+}
+
+func TestRetrieveLegacyUncompressedBlob(t *testing.T) {
+	content := "legacy blob stored before compression was enabled"
+
+	mock, _ := newBucketMock()
+	legacy := newMockS3BlobStorage(mock, "test-bucket", true)
+	blobID, err := legacy.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected store error: %v", err)
+	}
+
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.compression = CompressionGzip
+
+	retrieved, err := storage.Retrieve(blobID)
+	if err != nil {
+		t.Fatalf("unexpected retrieve error: %v", err)
+	}
+	if retrieved != content {
+		t.Errorf("expected content=%q, got %q", content, retrieved)
+	}
+}
+
+func TestStoreRetrieveWithGzipAndEncryption(t *testing.T) {
+	content := strings.Repeat("name,email,phone\n", 100)
+
+	aead, err := newBlobCipher(testEncryptionKey(5))
+	if err != nil {
+		t.Fatalf("failed to create cipher: %v", err)
+	}
+
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.compression = CompressionGzip
+	storage.aead = aead
+
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected store error: %v", err)
+	}
+
+	// Compression runs before encryption, so the ciphertext stays small
+	if size := len(objects["blobs/"+blobID].body); size >= len(content) {
+		t.Errorf("expected stored size < %d, got %d", len(content), size)
+	}
+
+	retrieved, err := storage.Retrieve(blobID)
+	if err != nil {
+		t.Fatalf("unexpected retrieve error: %v", err)
+	}
+	if retrieved != content {
+		t.Error("retrieved content does not match original")
+	}
+}
@@ -0,0 +1,72 @@
+package blobstorage
+
+import (
+	"context"
+	"fmt"
+)
+
+// acquireOp is called by every operation before it makes S3 calls, with the
+// operation's name for the RateLimiter. It fails with ErrClosed after Close
+// and with ErrCircuitOpen while the circuit breaker is open, and otherwise
+// waits on the RateLimiter and then for an operation slot when
+// MaxConcurrentOps is set, giving up as soon as ctx is done. Every successful
+// call must be paired with releaseOp once the operation's S3 calls have
+// finished.
+func (s *S3BlobStorage) acquireOp(ctx context.Context, op string) error {
+	return s.acquire(ctx, op, s.breaker)
+}
+
+// acquireReadOp is acquireOp for downloads that go through getObject. With
+// read replicas configured it ignores the primary's circuit breaker, since
+// failover checks each location's breaker in turn and may read from a
+// replica while the primary's is open.
+func (s *S3BlobStorage) acquireReadOp(ctx context.Context, op string) error {
+	if len(s.replicas) > 0 {
+		return s.acquire(ctx, op, nil)
+	}
+	return s.acquireOp(ctx, op)
+}
+
+// acquire checks Close and breaker, then waits on the rate limiter and for
+// an operation slot
+func (s *S3BlobStorage) acquire(ctx context.Context, op string, breaker *circuitBreaker) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	if err := breaker.allow(); err != nil {
+		return err
+	}
+	if err := s.waitRateLimit(ctx, op); err != nil {
+		return err
+	}
+
+	if s.opSlots == nil {
+		return nil
+	}
+
+	select {
+	case s.opSlots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to acquire operation slot: %w", ctx.Err())
+	}
+}
+
+// waitRateLimit blocks on the rate limiter before op, attributing it to
+// TenantID unless ctx names another tenant
+func (s *S3BlobStorage) waitRateLimit(ctx context.Context, op string) error {
+	if s.limiter == nil {
+		return nil
+	}
+	if _, ok := ctx.Value(tenantIDKey{}).(string); !ok && s.tenantID != "" {
+		ctx = WithTenantID(ctx, s.tenantID)
+	}
+	return s.limiter.Wait(ctx, op)
+}
+
+// releaseOp frees a slot taken by acquireOp
+func (s *S3BlobStorage) releaseOp() {
+	if s.opSlots != nil {
+		<-s.opSlots
+	}
+}
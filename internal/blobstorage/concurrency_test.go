@@ -0,0 +1,100 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+func TestMaxConcurrentOpsLimitsInFlightCalls(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	mock := &mockS3Client{
+		headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return nil, &smithy.GenericAPIError{Code: "NotFound"}
+		},
+		putObjectFunc: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			mu.Lock()
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			return &s3.PutObjectOutput{}, nil
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.opSlots = make(chan struct{}, 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := storage.Store(fmt.Sprintf("content %d", i)); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxInFlight > 2 {
+		t.Errorf("expected at most 2 concurrent uploads, got %d", maxInFlight)
+	}
+	if len(storage.opSlots) != 0 {
+		t.Errorf("expected all slots to be released, %d still held", len(storage.opSlots))
+	}
+}
+
+func TestMaxConcurrentOpsWaitHonorsCancellation(t *testing.T) {
+	storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+	storage.opSlots = make(chan struct{}, 1)
+	storage.opSlots <- struct{}{} // every slot is busy
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := storage.RetrieveReader(ctx, testBlobID("content"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected waiting to stop promptly after cancellation, took %s", elapsed)
+	}
+}
+
+func TestRetrieveReaderHoldsSlotUntilClosed(t *testing.T) {
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.opSlots = make(chan struct{}, 1)
+
+	blobID, err := storage.Store("content")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reader, err := storage.RetrieveReader(context.Background(), blobID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(storage.opSlots) != 1 {
+		t.Errorf("expected the open reader to hold a slot")
+	}
+
+	_ = reader.Close()
+	_ = reader.Close()
+	if len(storage.opSlots) != 0 {
+		t.Errorf("expected closing the reader to release its slot once, %d held", len(storage.opSlots))
+	}
+}
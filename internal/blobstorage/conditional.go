@@ -0,0 +1,79 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// errConcurrentlyStored is returned by upload when a conditional put finds
+// the blob was stored by a concurrent writer after the existence check
+var errConcurrentlyStored = errors.New("blob was stored concurrently")
+
+// putIfAbsent uploads with If-None-Match: * so that of two concurrent stores
+// of the same new content only one writes, and the other gets
+// errConcurrentlyStored. Backends that don't support conditional puts are
+// remembered and get plain puts, relying on the existence check alone. With
+// SkipDedupCheck every put is plain, overwriting any existing blob.
+func (s *S3BlobStorage) putIfAbsent(ctx context.Context, input *s3.PutObjectInput) error {
+	if s.skipDedupCheck || s.conditionalPutUnsupported.Load() {
+		_, err := s.client.PutObject(ctx, input)
+		return err
+	}
+
+	conditional := *input
+	conditional.IfNoneMatch = aws.String("*")
+
+	_, err := s.client.PutObject(ctx, &conditional)
+	if err == nil {
+		return nil
+	}
+	if isPreconditionFailed(err) {
+		return errConcurrentlyStored
+	}
+	if !isNotImplemented(err) {
+		return err
+	}
+
+	s.conditionalPutUnsupported.Store(true)
+	s.logger.Warnf("blobstorage: backend does not support conditional puts, falling back to existence checks: %v", err)
+
+	// The failed attempt may have consumed the body, so it can only be
+	// retried when it can be rewound
+	seeker, ok := input.Body.(io.Seeker)
+	if !ok {
+		return err
+	}
+	if _, seekErr := seeker.Seek(0, io.SeekStart); seekErr != nil {
+		return err
+	}
+	_, err = s.client.PutObject(ctx, input)
+	return err
+}
+
+// isPreconditionFailed reports whether err is a 412 from a conditional request
+func isPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "PreconditionFailed" {
+		return true
+	}
+	var respErr *smithyhttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusPreconditionFailed
+}
+
+// isNotImplemented reports whether err means the backend doesn't support a
+// request feature such as a conditional header
+func isNotImplemented(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotImplemented" {
+		return true
+	}
+	var respErr *smithyhttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotImplemented
+}
@@ -0,0 +1,87 @@
+package blobstorage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// racingPutMock simulates a concurrent writer storing the blob between the
+// existence check and the upload
+func racingPutMock(t *testing.T) *mockS3Client {
+	return &mockS3Client{
+		headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return nil, &smithy.GenericAPIError{Code: "NotFound"}
+		},
+		putObjectFunc: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			if aws.ToString(params.IfNoneMatch) != "*" {
+				t.Errorf("expected IfNoneMatch=*, got %q", aws.ToString(params.IfNoneMatch))
+			}
+			return nil, &smithy.GenericAPIError{Code: "PreconditionFailed"}
+		},
+	}
+}
+
+func TestStoreConcurrentWriteIsDeduplicated(t *testing.T) {
+	storage := newMockS3BlobStorage(racingPutMock(t), "test-bucket", true)
+
+	result, err := storage.StoreV2("raced content")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.BlobID != testBlobID("raced content") {
+		t.Errorf("expected blobID=%q, got %q", testBlobID("raced content"), result.BlobID)
+	}
+	if !result.Deduplicated {
+		t.Error("expected a lost race to be reported as deduplicated")
+	}
+}
+
+func TestStoreReaderConcurrentWriteIsDeduplicated(t *testing.T) {
+	storage := newMockS3BlobStorage(racingPutMock(t), "test-bucket", true)
+
+	blobID, err := storage.StoreReader(strings.NewReader("raced content"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if blobID != testBlobID("raced content") {
+		t.Errorf("expected blobID=%q, got %q", testBlobID("raced content"), blobID)
+	}
+}
+
+func TestStoreConditionalPutUnsupported(t *testing.T) {
+	var puts []string
+	mock, objects := newBucketMock()
+	putObject := mock.putObjectFunc
+	mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		puts = append(puts, aws.ToString(params.IfNoneMatch))
+		if params.IfNoneMatch != nil {
+			// Consume the body like a real request would before failing
+			_, _ = io.ReadAll(params.Body)
+			return nil, &smithy.GenericAPIError{Code: "NotImplemented"}
+		}
+		return putObject(ctx, params, optFns...)
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	for _, content := range []string{"first", "second"} {
+		blobID, err := storage.Store(content)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := string(objects["blobs/"+blobID].body); got != content {
+			t.Errorf("expected stored content=%q, got %q", content, got)
+		}
+	}
+
+	// The first store tries a conditional put and retries without it; later
+	// stores skip the condition entirely
+	if expected := []string{"*", "", ""}; strings.Join(puts, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected IfNoneMatch sequence %q, got %q", expected, puts)
+	}
+}
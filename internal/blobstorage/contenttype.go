@@ -0,0 +1,112 @@
+package blobstorage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// defaultContentType is used for blobs stored without a content type
+const defaultContentType = "application/octet-stream"
+
+// sniffLen is how much content http.DetectContentType considers
+const sniffLen = 512
+
+// StoreWithContentType stores content like Store with the given content type,
+// e.g. so browsers render PDFs inline when downloading via presigned URLs. If
+// identical content is already stored its content type is left as is.
+func (s *S3BlobStorage) StoreWithContentType(content string, contentType string) (_ string, err error) {
+	var result StoreResult
+	defer s.wrapStoreError("StoreWithContentType", &result, &err)
+	result, err = s.storeResult(s.ctx, []byte(content), putOptions{contentType: contentType})
+	return result.BlobID, err
+}
+
+// GetContentType returns the content type stored on a blob
+func (s *S3BlobStorage) GetContentType(blobID string) (_ string, err error) {
+	defer s.wrapError("GetContentType", blobID, &err)
+	if !s.enabled {
+		return "", ErrStorageDisabled
+	}
+
+	if err := s.validateBlobID(blobID); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "GetContentType"); err != nil {
+		return "", err
+	}
+	defer s.releaseOp()
+
+	result, err := s.client.HeadObject(ctx, s.headObjectInput(s.blobKey(blobID)))
+	if err != nil {
+		if isNotFound(err) {
+			return "", fmt.Errorf("failed to get blob content type: %w: %w", ErrBlobNotFound, err)
+		}
+		return "", fmt.Errorf("failed to get blob content type: %w", err)
+	}
+
+	return aws.ToString(result.ContentType), nil
+}
+
+// contentTypeFor returns the content type to upload with. When none was given
+// and AutoDetectContentType is set it is sniffed from head, the first bytes
+// of the original content.
+func (s *S3BlobStorage) contentTypeFor(opts putOptions, head []byte) string {
+	if opts.contentType != "" {
+		return opts.contentType
+	}
+	if s.autoDetectContentType {
+		return detectContentType(head)
+	}
+	return defaultContentType
+}
+
+// executableSignatures are the magic numbers of executable formats, which
+// http.DetectContentType reports as application/octet-stream
+var executableSignatures = []struct {
+	prefix      string
+	contentType string
+}{
+	{prefix: "MZ", contentType: "application/x-dosexec"},
+	{prefix: "\x7fELF", contentType: "application/x-executable"},
+	{prefix: "\xfe\xed\xfa\xce", contentType: "application/x-mach-binary"},
+	{prefix: "\xfe\xed\xfa\xcf", contentType: "application/x-mach-binary"},
+	{prefix: "\xce\xfa\xed\xfe", contentType: "application/x-mach-binary"},
+	{prefix: "\xcf\xfa\xed\xfe", contentType: "application/x-mach-binary"},
+}
+
+// detectContentType sniffs the content type of head like
+// http.DetectContentType, also recognizing executables
+func detectContentType(head []byte) string {
+	for _, sig := range executableSignatures {
+		if bytes.HasPrefix(head, []byte(sig.prefix)) {
+			return sig.contentType
+		}
+	}
+	return http.DetectContentType(head)
+}
+
+// checkContentType fails with ErrBlockedContentType if content, of which
+// only the first sniffLen bytes are considered, is sniffed as one of the
+// BlockedContentTypes. Parameters such as charset are ignored.
+func (s *S3BlobStorage) checkContentType(content []byte) error {
+	if len(s.blockedContentTypes) == 0 {
+		return nil
+	}
+
+	detected, _, _ := strings.Cut(detectContentType(content[:min(len(content), sniffLen)]), ";")
+	for _, blocked := range s.blockedContentTypes {
+		if strings.EqualFold(strings.TrimSpace(blocked), detected) {
+			return fmt.Errorf("%w: %s", ErrBlockedContentType, detected)
+		}
+	}
+	return nil
+}
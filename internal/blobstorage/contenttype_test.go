@@ -0,0 +1,205 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestStoreContentType(t *testing.T) {
+	pdf := "%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n"
+
+	tests := []struct {
+		name         string
+		content      string
+		contentType  string
+		autoDetect   bool
+		streamed     bool
+		expectedType string
+	}{
+		{name: "default", content: pdf, expectedType: "application/octet-stream"},
+		{name: "explicit", content: pdf, contentType: "application/pdf", expectedType: "application/pdf"},
+		{name: "auto-detected", content: pdf, autoDetect: true, expectedType: "application/pdf"},
+		{name: "explicit overrides auto-detection", content: pdf, contentType: "application/x-custom", autoDetect: true, expectedType: "application/x-custom"},
+		{name: "auto-detected when streamed", content: pdf, autoDetect: true, streamed: true, expectedType: "application/pdf"},
+		{name: "auto-detected large streamed content", content: pdf + strings.Repeat("x", 2*sniffLen), autoDetect: true, streamed: true, expectedType: "application/pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, objects := newBucketMock()
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			storage.autoDetectContentType = tt.autoDetect
+
+			var blobID string
+			var err error
+			switch {
+			case tt.streamed:
+				blobID, err = storage.StoreReaderWithSizeAndHash(strings.NewReader(tt.content), int64(len(tt.content)), testBlobID(tt.content))
+			case tt.contentType != "":
+				blobID, err = storage.StoreWithContentType(tt.content, tt.contentType)
+			default:
+				blobID, err = storage.Store(tt.content)
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			contentType, err := storage.GetContentType(blobID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if contentType != tt.expectedType {
+				t.Errorf("expected content type %q, got %q", tt.expectedType, contentType)
+			}
+			if got := string(objects["blobs/"+blobID].body); got != tt.content {
+				t.Errorf("expected stored content to be unchanged, got %q", got)
+			}
+		})
+	}
+}
+
+func TestGetContentTypeErrors(t *testing.T) {
+	t.Run("missing blob", func(t *testing.T) {
+		mock, _ := newBucketMock()
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+		_, err := storage.GetContentType(testBlobID("missing"))
+		if !errors.Is(err, ErrBlobNotFound) || !strings.Contains(err.Error(), "failed to get blob content type") {
+			t.Errorf("expected ErrBlobNotFound, got %v", err)
+		}
+	})
+
+	t.Run("disabled storage", func(t *testing.T) {
+		storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", false)
+
+		if _, err := storage.GetContentType(testBlobID("missing")); !errors.Is(err, ErrStorageDisabled) {
+			t.Errorf("expected ErrStorageDisabled, got %v", err)
+		}
+	})
+}
+
+func TestBlockedContentTypes(t *testing.T) {
+	peHeader := "MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xff\xff\x00\x00" + strings.Repeat("\x00", 48) + "PE\x00\x00"
+
+	tests := []struct {
+		name          string
+		blocked       []string
+		content       string
+		expectBlocked bool
+	}{
+		{name: "executable blocked", blocked: []string{"application/x-dosexec"}, content: peHeader, expectBlocked: true},
+		{name: "text allowed", blocked: []string{"application/x-dosexec"}, content: "a harmless text file"},
+		{name: "parameters ignored", blocked: []string{"Text/Plain"}, content: "a harmless text file", expectBlocked: true},
+		{name: "nothing blocked", content: peHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, objects := newBucketMock()
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			storage.blockedContentTypes = tt.blocked
+
+			stores := map[string]func() error{
+				"Store": func() error {
+					_, err := storage.Store(tt.content)
+					return err
+				},
+				"StoreReader": func() error {
+					_, err := storage.StoreReader(strings.NewReader(tt.content))
+					return err
+				},
+			}
+			for name, store := range stores {
+				clear(objects)
+				err := store()
+				if tt.expectBlocked {
+					if !errors.Is(err, ErrBlockedContentType) {
+						t.Errorf("%s: expected ErrBlockedContentType, got %v", name, err)
+					}
+					if len(objects) != 0 {
+						t.Errorf("%s: expected nothing to be uploaded", name)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("%s: unexpected error: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamedUploadBodySeekable(t *testing.T) {
+	pdf := "%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n"
+
+	tests := []struct {
+		name       string
+		autoDetect bool
+		blocked    []string
+	}{
+		{name: "plain"},
+		{name: "auto-detected", autoDetect: true},
+		{name: "blocked content types checked", blocked: []string{"application/x-dosexec"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, objects := newBucketMock()
+			put := mock.putObjectFunc
+			mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				// The SDK seeks the body to sign it over plain HTTP
+				if _, ok := params.Body.(io.Seeker); !ok {
+					t.Errorf("expected a seekable body, got %T", params.Body)
+				}
+				return put(ctx, params, optFns...)
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			storage.autoDetectContentType = tt.autoDetect
+			storage.blockedContentTypes = tt.blocked
+
+			path := filepath.Join(t.TempDir(), "doc.pdf")
+			if err := os.WriteFile(path, []byte(pdf), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			stores := map[string]func() (string, error){
+				"StoreReader": func() (string, error) {
+					return storage.StoreReader(strings.NewReader(pdf))
+				},
+				"StoreFile": func() (string, error) {
+					return storage.StoreFile(path)
+				},
+				"BlobWriter": func() (string, error) {
+					w := storage.NewBlobWriter()
+					if _, err := io.WriteString(w, pdf); err != nil {
+						return "", err
+					}
+					err := w.Close()
+					return w.BlobID(), err
+				},
+			}
+			for name, store := range stores {
+				clear(objects)
+				blobID, err := store()
+				if err != nil {
+					t.Fatalf("%s: unexpected error: %v", name, err)
+				}
+
+				obj := objects["blobs/"+blobID]
+				if obj == nil || string(obj.body) != pdf {
+					t.Fatalf("%s: expected the content to be stored unchanged", name)
+				}
+				if tt.autoDetect && obj.contentType != "application/pdf" {
+					t.Errorf("%s: expected content type application/pdf, got %q", name, obj.contentType)
+				}
+			}
+		})
+	}
+}
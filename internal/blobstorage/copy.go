@@ -0,0 +1,111 @@
+package blobstorage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Copy copies a blob to dest, e.g. when migrating between buckets. If both
+// storages talk to the same endpoint with the same credentials and encode
+// content the same way the object is copied server-side with CopyObject,
+// taking on dest's encryption, storage class, ACL and object lock settings;
+// otherwise it is retrieved and stored in dest. Blobs already present in
+// dest are skipped, and nothing is written when dest is in dry-run mode.
+func (s *S3BlobStorage) Copy(ctx context.Context, blobID string, dest *S3BlobStorage) (err error) {
+	defer s.wrapError("Copy", blobID, &err)
+	if !s.enabled || !dest.enabled {
+		return ErrStorageDisabled
+	}
+
+	if err := s.validateBlobID(blobID); err != nil {
+		return err
+	}
+	if s.hashAlgorithm != dest.hashAlgorithm {
+		// The blob ID wouldn't match its content under dest's algorithm
+		return fmt.Errorf("cannot copy blobs between %q and %q hash algorithms", s.hashAlgorithm, dest.hashAlgorithm)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opTimeout(s.uploadTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "Copy"); err != nil {
+		return err
+	}
+	defer s.releaseOp()
+
+	destKey := dest.blobKey(blobID)
+	exists, err := dest.objectExists(ctx, destKey)
+	if err != nil {
+		return fmt.Errorf("failed to check blob existence: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	if dest.dryRun {
+		exists, err := s.objectExists(ctx, s.blobKey(blobID))
+		if err != nil {
+			return fmt.Errorf("failed to check blob existence: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("failed to copy blob %s: %w", blobID, ErrBlobNotFound)
+		}
+		s.logger.Debugf("blobstorage: dry run, would copy %s to %s/%s", blobID, dest.bucket, destKey)
+		return nil
+	}
+
+	if !s.canCopyServerSide(dest) {
+		data, err := s.retrieve(ctx, blobID)
+		if err != nil {
+			return err
+		}
+		_, err = dest.storeResult(ctx, data, putOptions{})
+		return err
+	}
+
+	if dest.referenceCounting {
+		if _, _, err := dest.updateReferenceCount(ctx, blobID, 1); err != nil {
+			return err
+		}
+	}
+
+	sse, kmsKeyID := dest.serverSideEncryption()
+	input := &s3.CopyObjectInput{
+		Bucket:                    aws.String(dest.bucket),
+		Key:                       aws.String(destKey),
+		CopySource:                copySource(s.bucket, s.blobKey(blobID)),
+		ServerSideEncryption:      sse,
+		SSEKMSKeyId:               kmsKeyID,
+		StorageClass:              dest.storageClassFor(putOptions{}),
+		ACL:                       types.ObjectCannedACL(dest.acl),
+		ObjectLockMode:            types.ObjectLockMode(dest.objectLockMode),
+		ObjectLockRetainUntilDate: dest.retainUntilFor(),
+	}
+	dest.setCopySSECustomer(input, s)
+	_, err = dest.client.CopyObject(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to copy blob: %w", err)
+	}
+
+	return nil
+}
+
+// canCopyServerSide reports whether dest can take the bytes s has stored as
+// is, which requires the same backend, the same client-side encryption key
+// and the same compression, so the copy is encoded as dest would store it
+func (s *S3BlobStorage) canCopyServerSide(dest *S3BlobStorage) bool {
+	if s.encryptionKeyID != dest.encryptionKeyID {
+		return false
+	}
+	if s.compression != dest.compression {
+		return false
+	}
+	if s.client == dest.client {
+		return true
+	}
+	return s.endpoint == dest.endpoint && s.region == dest.region && s.accessKey == dest.accessKey && s.profile == dest.profile
+}
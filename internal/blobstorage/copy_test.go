@@ -0,0 +1,212 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestCopyServerSide(t *testing.T) {
+	blobID := testBlobID("attachment")
+	var copyParams *s3.CopyObjectInput
+	mock := &mockS3Client{
+		headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return nil, &smithy.GenericAPIError{Code: "NotFound"}
+		},
+		getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			t.Error("expected no download for a server-side copy")
+			return nil, errors.New("unexpected download")
+		},
+		copyObjectFunc: func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+			copyParams = params
+			return &s3.CopyObjectOutput{}, nil
+		},
+	}
+	source := newMockS3BlobStorage(mock, "source-bucket", true)
+	dest := newMockS3BlobStorage(mock, "dest-bucket", true)
+
+	if err := source.Copy(context.Background(), blobID, dest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if copyParams == nil {
+		t.Fatal("expected CopyObject to be called")
+	}
+	if got := aws.ToString(copyParams.CopySource); got != "source-bucket/blobs%2F"+blobID {
+		t.Errorf("expected CopySource=%q, got %q", "source-bucket/blobs%2F"+blobID, got)
+	}
+	if aws.ToString(copyParams.Bucket) != "dest-bucket" || aws.ToString(copyParams.Key) != "blobs/"+blobID {
+		t.Errorf("expected copy to dest-bucket/blobs/%s, got %s/%s", blobID, aws.ToString(copyParams.Bucket), aws.ToString(copyParams.Key))
+	}
+}
+
+func TestCopyServerSideDestSettings(t *testing.T) {
+	blobID := testBlobID("attachment")
+	var copyParams *s3.CopyObjectInput
+	mock := &mockS3Client{
+		headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return nil, &smithy.GenericAPIError{Code: "NotFound"}
+		},
+		copyObjectFunc: func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+			copyParams = params
+			return &s3.CopyObjectOutput{}, nil
+		},
+	}
+
+	// Separately built storages sharing a key can still copy server-side
+	source := newMockS3BlobStorage(mock, "source-bucket", true)
+	dest := newMockS3BlobStorage(mock, "dest-bucket", true)
+	for _, storage := range []*S3BlobStorage{source, dest} {
+		storage.aead, _ = newBlobCipher(testEncryptionKey(1))
+		storage.encryptionKeyID = encryptionKeyID(testEncryptionKey(1))
+	}
+	dest.objectLockMode = ObjectLockCompliance
+	dest.objectLockDays = 30
+
+	if err := source.Copy(context.Background(), blobID, dest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if copyParams == nil {
+		t.Fatal("expected CopyObject to be called")
+	}
+	if copyParams.ObjectLockMode != types.ObjectLockModeCompliance || copyParams.ObjectLockRetainUntilDate == nil {
+		t.Errorf("expected dest's object lock on the copy, got mode=%q until=%v", copyParams.ObjectLockMode, copyParams.ObjectLockRetainUntilDate)
+	}
+}
+
+func TestCopyDryRun(t *testing.T) {
+	mock, objects := newBucketMock()
+	mock.copyObjectFunc = func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+		t.Error("expected no copy in dry-run mode")
+		return &s3.CopyObjectOutput{}, nil
+	}
+	source := newMockS3BlobStorage(mock, "source-bucket", true)
+	source.keyPrefix = "source/"
+	dest := newMockS3BlobStorage(mock, "dest-bucket", true)
+	dest.keyPrefix = "dest/"
+	dest.referenceCounting = true
+	dest.dryRun = true
+
+	blobID, err := source.Store("attachment")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stored := len(objects)
+
+	if err := source.Copy(context.Background(), blobID, dest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(objects) != stored {
+		t.Errorf("expected a dry-run copy to write nothing, got %d objects, want %d", len(objects), stored)
+	}
+
+	if err := source.Copy(context.Background(), testBlobID("missing"), dest); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound for a missing source blob, got %v", err)
+	}
+}
+
+func TestCopyFallsBackToRetrieveAndStore(t *testing.T) {
+	tests := []struct {
+		name      string
+		setupDest func(source, dest *S3BlobStorage)
+	}{
+		{
+			name: "different endpoints",
+			setupDest: func(source, dest *S3BlobStorage) {
+				source.endpoint = "http://minio-a:9000"
+				dest.endpoint = "http://minio-b:9000"
+			},
+		},
+		{
+			name: "different encryption keys",
+			setupDest: func(source, dest *S3BlobStorage) {
+				source.aead, _ = newBlobCipher(testEncryptionKey(1))
+				source.encryptionKeyID = encryptionKeyID(testEncryptionKey(1))
+				dest.aead, _ = newBlobCipher(testEncryptionKey(2))
+				dest.encryptionKeyID = encryptionKeyID(testEncryptionKey(2))
+			},
+		},
+		{
+			name: "different compression",
+			setupDest: func(source, dest *S3BlobStorage) {
+				dest.compression = CompressionGzip
+			},
+		},
+		{
+			name: "different profiles",
+			setupDest: func(source, dest *S3BlobStorage) {
+				source.profile = "account-a"
+				dest.profile = "account-b"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "attachment to migrate"
+			sourceMock, _ := newBucketMock()
+			destMock, destObjects := newBucketMock()
+			copyCalled := false
+			destMock.copyObjectFunc = func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				copyCalled = true
+				return &s3.CopyObjectOutput{}, nil
+			}
+			source := newMockS3BlobStorage(sourceMock, "source-bucket", true)
+			dest := newMockS3BlobStorage(destMock, "dest-bucket", true)
+			tt.setupDest(source, dest)
+
+			blobID, err := source.Store(content)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if err := source.Copy(context.Background(), blobID, dest); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if copyCalled {
+				t.Error("expected no server-side copy")
+			}
+			if _, ok := destObjects["blobs/"+blobID]; !ok {
+				t.Fatal("expected blob to be stored in dest")
+			}
+			retrieved, err := dest.Retrieve(blobID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if retrieved != content {
+				t.Errorf("expected content=%q, got %q", content, retrieved)
+			}
+		})
+	}
+}
+
+func TestCopySkipsExistingBlob(t *testing.T) {
+	mock := &mockS3Client{
+		copyObjectFunc: func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+			t.Error("expected no copy when the blob already exists in dest")
+			return &s3.CopyObjectOutput{}, nil
+		},
+	}
+	source := newMockS3BlobStorage(mock, "source-bucket", true)
+	dest := newMockS3BlobStorage(mock, "dest-bucket", true)
+
+	if err := source.Copy(context.Background(), testBlobID("attachment"), dest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCopyDisabledStorage(t *testing.T) {
+	source := newMockS3BlobStorage(&mockS3Client{}, "source-bucket", true)
+	dest := newMockS3BlobStorage(&mockS3Client{}, "dest-bucket", false)
+
+	if err := source.Copy(context.Background(), testBlobID("attachment"), dest); !errors.Is(err, ErrStorageDisabled) {
+		t.Errorf("expected ErrStorageDisabled, got %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package blobstorage
+
+import "io"
+
+// defaultCopyBufferSize is the buffer size for streaming copies when
+// CopyBufferSize is not set
+const defaultCopyBufferSize = 64 * 1024
+
+// copyBuffered copies src to dst like io.Copy, but through a pooled buffer of
+// CopyBufferSize bytes so large blobs move in fewer, larger reads and writes
+func (s *S3BlobStorage) copyBuffered(dst io.Writer, src io.Reader) (int64, error) {
+	size := s.copyBufferSize
+	if size <= 0 {
+		size = defaultCopyBufferSize
+	}
+
+	buf, ok := s.copyBuffers.Get().(*[]byte)
+	if !ok || len(*buf) != size {
+		b := make([]byte, size)
+		buf = &b
+	}
+	defer s.copyBuffers.Put(buf)
+
+	// Hide any ReadFrom/WriteTo so io.CopyBuffer actually uses buf; *os.File
+	// would otherwise fall back to io.Copy's own 32KB buffer
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *buf)
+}
@@ -0,0 +1,91 @@
+package blobstorage
+
+import (
+	"container/list"
+	"sync"
+)
+
+// dedupCache is a bounded LRU of blob IDs known to be stored, letting Store
+// skip the existence check and upload for recently seen content. It is only
+// invalidated by this process, so blobs deleted elsewhere may be reported as
+// stored until they age out. A nil cache is valid and always misses.
+type dedupCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // most recently used at the front
+	entries map[string]*list.Element
+}
+
+func newDedupCache(size int) *dedupCache {
+	return &dedupCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// contains reports whether blobID is cached, marking it as recently used
+func (c *dedupCache) contains(blobID string) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[blobID]
+	if ok {
+		c.order.MoveToFront(elem)
+	}
+	return ok
+}
+
+// add records blobID as stored, evicting the least recently used entry when full
+func (c *dedupCache) add(blobID string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[blobID]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[blobID] = c.order.PushFront(blobID)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(string))
+	}
+}
+
+// remove forgets blobID
+func (c *dedupCache) remove(blobID string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[blobID]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, blobID)
+	}
+}
+
+// clear forgets every cached blob ID
+func (c *dedupCache) clear() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	clear(c.entries)
+}
@@ -0,0 +1,111 @@
+package blobstorage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestDedupCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newDedupCache(2)
+	cache.add("a")
+	cache.add("b")
+	cache.contains("a") // a is now more recent than b
+	cache.add("c")
+
+	if !cache.contains("a") || !cache.contains("c") {
+		t.Error("expected a and c to be cached")
+	}
+	if cache.contains("b") {
+		t.Error("expected b to be evicted")
+	}
+	if len(cache.entries) != 2 || cache.order.Len() != 2 {
+		t.Errorf("expected cache bounded to 2 entries, got %d", len(cache.entries))
+	}
+
+	cache.remove("a")
+	if cache.contains("a") {
+		t.Error("expected a to be removed")
+	}
+
+	var disabled *dedupCache
+	disabled.add("a")
+	if disabled.contains("a") {
+		t.Error("expected a nil cache to always miss")
+	}
+}
+
+func TestStoreWithDedupCache(t *testing.T) {
+	mock, _ := newBucketMock()
+	calls := 0
+	headObject, putObject := mock.headObjectFunc, mock.putObjectFunc
+	mock.headObjectFunc = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		calls++
+		return headObject(ctx, params, optFns...)
+	}
+	mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		calls++
+		return putObject(ctx, params, optFns...)
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.dedupCache = newDedupCache(10)
+
+	if _, err := storage.Store("cached content"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected HeadObject and PutObject for new content, got %d calls", calls)
+	}
+
+	calls = 0
+	result, err := storage.StoreV2("cached content")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("expected a cached blob to skip S3, got %d calls", calls)
+	}
+	if !result.Deduplicated {
+		t.Error("expected a cached blob to be reported as deduplicated")
+	}
+
+	if err := storage.Delete(result.BlobID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	calls = 0
+	if _, err := storage.Store("cached content"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected Delete to invalidate the cache and re-upload, got %d calls", calls)
+	}
+}
+
+func TestDedupCacheConcurrentUse(t *testing.T) {
+	cache := newDedupCache(8)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				id := fmt.Sprintf("blob-%d", (i+j)%20)
+				cache.add(id)
+				cache.contains(id)
+				if j%10 == 0 {
+					cache.remove(id)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(cache.entries) > 8 || cache.order.Len() != len(cache.entries) {
+		t.Errorf("expected at most 8 consistent entries, got %d in map and %d in list", len(cache.entries), cache.order.Len())
+	}
+}
@@ -0,0 +1,76 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestDryRunStore(t *testing.T) {
+	mock, objects := newBucketMock()
+	putObject := mock.putObjectFunc
+	mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		t.Errorf("unexpected PutObject for %s in dry run", *params.Key)
+		return putObject(ctx, params, optFns...)
+	}
+	objects["blobs/"+testBlobID("already stored")] = &storedObject{body: []byte("already stored")}
+
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.dryRun = true
+	storage.referenceCounting = true
+	storage.maxBlobSize = 32
+
+	result, err := storage.StoreV2("new content")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.BlobID != testBlobID("new content") || result.Deduplicated {
+		t.Errorf("expected new content to be reported as an upload, got %+v", result)
+	}
+
+	result, err = storage.StoreV2("already stored")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Deduplicated {
+		t.Errorf("expected stored content to be reported as deduplicated, got %+v", result)
+	}
+
+	var tooLarge *ErrBlobTooLarge
+	if _, err := storage.StoreV2("content well over the thirty-two byte limit"); !errors.As(err, &tooLarge) {
+		t.Errorf("expected ErrBlobTooLarge, got %v", err)
+	}
+
+	if len(objects) != 1 {
+		t.Errorf("expected dry run to write nothing, bucket has %d objects", len(objects))
+	}
+}
+
+func TestDryRunDelete(t *testing.T) {
+	mock, objects := newBucketMock()
+	mock.deleteObjectFunc = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		t.Errorf("unexpected DeleteObject for %s in dry run", *params.Key)
+		return &s3.DeleteObjectOutput{}, nil
+	}
+	blobID := testBlobID("attachment")
+	objects["blobs/"+blobID] = &storedObject{body: []byte("attachment")}
+
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.dryRun = true
+
+	if err := storage.Delete(blobID); err != nil {
+		t.Errorf("unexpected error deleting existing blob: %v", err)
+	}
+	if _, ok := objects["blobs/"+blobID]; !ok {
+		t.Error("expected blob to remain after dry-run delete")
+	}
+
+	if err := storage.Delete(testBlobID("missing")); err != nil {
+		t.Errorf("unexpected error deleting missing blob: %v", err)
+	}
+	if err := storage.DeleteStrict(testBlobID("missing")); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound for missing blob, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// encodingConfigs are the combinations of compression and encryption a
+// client can be configured with
+var encodingConfigs = []struct {
+	name     string
+	compress bool
+	encrypt  bool
+}{
+	{name: "plain"},
+	{name: "gzip", compress: true},
+	{name: "aes-gcm", encrypt: true},
+	{name: "gzip+aes-gcm", compress: true, encrypt: true},
+}
+
+// newEncodingStorage returns a storage on mock configured with the given
+// compression and encryption, all sharing the same key
+func newEncodingStorage(t *testing.T, mock S3Api, compress, encrypt bool) *S3BlobStorage {
+	t.Helper()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	if compress {
+		storage.compression = CompressionGzip
+	}
+	if encrypt {
+		aead, err := newBlobCipher(testEncryptionKey(9))
+		if err != nil {
+			t.Fatalf("failed to create cipher: %v", err)
+		}
+		storage.aead = aead
+	}
+	return storage
+}
+
+func TestCrossEncodingRoundTrip(t *testing.T) {
+	content := strings.Repeat("cross-configuration content\n", 50)
+	blobID := testBlobID(content)
+
+	for _, writer := range encodingConfigs {
+		for _, reader := range encodingConfigs {
+			t.Run(writer.name+" to "+reader.name, func(t *testing.T) {
+				mock, objects := newBucketMock()
+				w := newEncodingStorage(t, mock, writer.compress, writer.encrypt)
+				r := newEncodingStorage(t, mock, reader.compress, reader.encrypt)
+
+				id, err := w.Store(content)
+				if err != nil {
+					t.Fatalf("unexpected store error: %v", err)
+				}
+				if id != blobID {
+					t.Errorf("expected blobID=%q, got %q", blobID, id)
+				}
+
+				// The reader's own config doesn't change the ID, so it dedups
+				stored := objects["blobs/"+blobID].etag
+				if id, err := r.Store(content); err != nil || id != blobID {
+					t.Fatalf("expected dedup to %q, got %q (err=%v)", blobID, id, err)
+				}
+				if objects["blobs/"+blobID].etag != stored {
+					t.Error("expected the stored blob to be kept, not rewritten")
+				}
+
+				if writer.encrypt && !reader.encrypt {
+					if _, err := r.Retrieve(blobID); !errors.Is(err, ErrDecryptionFailed) {
+						t.Errorf("expected ErrDecryptionFailed without a key, got %v", err)
+					}
+					return
+				}
+
+				retrieved, err := r.Retrieve(blobID)
+				if err != nil {
+					t.Fatalf("unexpected retrieve error: %v", err)
+				}
+				if retrieved != content {
+					t.Error("retrieved content does not match original")
+				}
+
+				rc, err := r.RetrieveReader(context.Background(), blobID)
+				if err != nil {
+					t.Fatalf("unexpected reader error: %v", err)
+				}
+				defer func() { _ = rc.Close() }()
+				data, err := io.ReadAll(rc)
+				if err != nil {
+					t.Fatalf("unexpected read error: %v", err)
+				}
+				if string(data) != content {
+					t.Error("streamed content does not match original")
+				}
+			})
+		}
+	}
+}
+
+func TestRetrieveUnmarkedEncryptedBlob(t *testing.T) {
+	content := "encrypted before the marker was recorded"
+
+	mock, objects := newBucketMock()
+	storage := newEncodingStorage(t, mock, true, true)
+
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected store error: %v", err)
+	}
+	delete(objects["blobs/"+blobID].metadata, metaEncryption)
+
+	retrieved, err := storage.Retrieve(blobID)
+	if err != nil {
+		t.Fatalf("unexpected retrieve error: %v", err)
+	}
+	if retrieved != content {
+		t.Errorf("expected content=%q, got %q", content, retrieved)
+	}
+
+	t.Run("wrong key is still reported", func(t *testing.T) {
+		other := newMockS3BlobStorage(mock, "test-bucket", true)
+		other.aead, _ = newBlobCipher(testEncryptionKey(10))
+
+		if _, err := other.Retrieve(blobID); !errors.Is(err, ErrDecryptionFailed) {
+			t.Errorf("expected ErrDecryptionFailed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package blobstorage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// encryptionKeySize is the required client-side encryption key length (AES-256)
+const encryptionKeySize = 32
+
+// metaEncryption is the object metadata key (x-amz-meta-encryption) recording
+// the client-side encryption applied to the stored bytes, so clients can tell
+// encrypted blobs apart whatever their own configuration
+const (
+	metaEncryption   = "encryption"
+	encryptionAESGCM = "aes-256-gcm"
+)
+
+// ErrDecryptionFailed is returned when stored ciphertext cannot be decrypted,
+// usually because it was written with a different key or has been tampered with
+var ErrDecryptionFailed = errors.New("failed to decrypt blob")
+
+// newBlobCipher creates the AES-256-GCM cipher used for client-side encryption
+func newBlobCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != encryptionKeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", encryptionKeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encryptionKeyID fingerprints an encryption key, so storages built
+// separately can tell whether they share one without keeping a copy of it.
+// It is empty without a key.
+func encryptionKeyID(key []byte) string {
+	if key == nil {
+		return ""
+	}
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
+
+// encryptBlob seals plaintext with a random nonce, which is prefixed to the
+// returned ciphertext
+func encryptBlob(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// decryptBlob opens ciphertext produced by encryptBlob
+func decryptBlob(aead cipher.AEAD, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrDecryptionFailed)
+	}
+
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+
+	return plaintext, nil
+}
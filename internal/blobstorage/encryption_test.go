@@ -0,0 +1,153 @@
+package blobstorage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testEncryptionKey(fill byte) []byte {
+	return bytes.Repeat([]byte{fill}, encryptionKeySize)
+}
+
+func TestNewS3BlobStorageEncryptionKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         []byte
+		expectError bool
+	}{
+		{name: "short key", key: make([]byte, 16), expectError: true},
+		{name: "long key", key: make([]byte, 48), expectError: true},
+		{name: "empty key", key: []byte{}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewS3BlobStorage(Config{
+				Enabled:       true,
+				AccessKey:     "access",
+				SecretKey:     "secret",
+				EncryptionKey: tt.key,
+			})
+			if tt.expectError {
+				if err == nil || !strings.Contains(err.Error(), "invalid encryption key") {
+					t.Errorf("expected invalid encryption key error, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreRetrieveWithEncryption(t *testing.T) {
+	content := "PII-laden attachment content"
+	hash := sha256.Sum256([]byte(content))
+	expectedBlobID := hex.EncodeToString(hash[:])
+
+	aead, err := newBlobCipher(testEncryptionKey(1))
+	if err != nil {
+		t.Fatalf("failed to create cipher: %v", err)
+	}
+
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.aead = aead
+
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected store error: %v", err)
+	}
+
+	// Blob ID must be the plaintext hash so deduplication works across clients
+	if blobID != expectedBlobID {
+		t.Errorf("expected blobID=%q, got %q", expectedBlobID, blobID)
+	}
+
+	stored := objects["blobs/"+blobID]
+	if stored == nil {
+		t.Fatal("expected object to be stored")
+	}
+	if bytes.Contains(stored.body, []byte(content)) {
+		t.Error("stored object contains plaintext")
+	}
+
+	retrieved, err := storage.Retrieve(blobID)
+	if err != nil {
+		t.Fatalf("unexpected retrieve error: %v", err)
+	}
+	if retrieved != content {
+		t.Errorf("expected content=%q, got %q", content, retrieved)
+	}
+
+	t.Run("wrong key fails to decrypt", func(t *testing.T) {
+		otherAead, err := newBlobCipher(testEncryptionKey(2))
+		if err != nil {
+			t.Fatalf("failed to create cipher: %v", err)
+		}
+		other := newMockS3BlobStorage(mock, "test-bucket", true)
+		other.aead = otherAead
+
+		if _, err := other.Retrieve(blobID); !errors.Is(err, ErrDecryptionFailed) {
+			t.Errorf("expected ErrDecryptionFailed, got %v", err)
+		}
+	})
+
+	t.Run("streamed store is encrypted", func(t *testing.T) {
+		streamed := "streamed PII content"
+		streamedHash := sha256.Sum256([]byte(streamed))
+
+		id, err := storage.StoreReaderWithSizeAndHash(strings.NewReader(streamed), int64(len(streamed)), hex.EncodeToString(streamedHash[:]))
+		if err != nil {
+			t.Fatalf("unexpected store error: %v", err)
+		}
+		if bytes.Contains(objects["blobs/"+id].body, []byte(streamed)) {
+			t.Error("stored object contains plaintext")
+		}
+
+		retrieved, err := storage.Retrieve(id)
+		if err != nil {
+			t.Fatalf("unexpected retrieve error: %v", err)
+		}
+		if retrieved != streamed {
+			t.Errorf("expected content=%q, got %q", streamed, retrieved)
+		}
+	})
+}
+
+func TestEncryptBlobUsesRandomNonce(t *testing.T) {
+	aead, err := newBlobCipher(testEncryptionKey(3))
+	if err != nil {
+		t.Fatalf("failed to create cipher: %v", err)
+	}
+
+	first, err := encryptBlob(aead, []byte("same plaintext"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := encryptBlob(aead, []byte("same plaintext"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if bytes.Equal(first, second) {
+		t.Error("expected different ciphertexts for repeated encryption")
+	}
+	if bytes.Equal(first[:aead.NonceSize()], second[:aead.NonceSize()]) {
+		t.Error("expected different nonce prefixes")
+	}
+}
+
+func TestDecryptBlobRejectsShortCiphertext(t *testing.T) {
+	aead, err := newBlobCipher(testEncryptionKey(4))
+	if err != nil {
+		t.Fatalf("failed to create cipher: %v", err)
+	}
+
+	if _, err := decryptBlob(aead, []byte("short")); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("expected ErrDecryptionFailed, got %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package blobstorage
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// ErrStorageDisabled is returned by operations on blob storage that is not enabled
+var ErrStorageDisabled = errors.New("blob storage is not enabled")
+
+// ErrClosed is returned by operations on blob storage after Close
+var ErrClosed = errors.New("blob storage is closed")
+
+// ErrCircuitOpen is returned without contacting the backend while the
+// circuit breaker considers it down
+var ErrCircuitOpen = errors.New("blob storage circuit breaker is open")
+
+// ErrBackendDegraded is returned by CachingBlobStorage while the wrapped
+// storage's circuit breaker is open, for anything not served from the cache
+var ErrBackendDegraded = errors.New("blob storage backend is degraded: only cached blobs are available")
+
+// ErrBlobNotFound is returned when retrieving a blob that does not exist
+var ErrBlobNotFound = errors.New("blob not found")
+
+// ErrInvalidBlobID is returned when a blob ID is not a lowercase hex digest
+// of the configured hash algorithm, before any request is made
+var ErrInvalidBlobID = errors.New("invalid blob ID")
+
+// ErrInvalidKey is returned when Rename's target key is outside the store's
+// key prefix or would overwrite a reference count
+var ErrInvalidKey = errors.New("invalid object key")
+
+// ErrIntegrityMismatch is returned when retrieved content does not hash to
+// its blob ID, indicating corruption or truncation in the backend
+var ErrIntegrityMismatch = errors.New("blob content does not match its ID")
+
+// ErrEmptyContent is returned when storing empty content with RejectEmpty set
+var ErrEmptyContent = errors.New("blob content is empty")
+
+// ErrSSECustomerKey is returned when S3 rejects a request on a blob written
+// with SSE-C because SSECustomerKey is missing or is not the key it was
+// written with
+var ErrSSECustomerKey = errors.New("SSE-C key missing or does not match the blob")
+
+// ErrBlockedContentType is returned when storing content sniffed as one of
+// the configured BlockedContentTypes
+var ErrBlockedContentType = errors.New("blob content type is blocked")
+
+// ErrBadDigest is returned when S3 rejects an upload because its body does
+// not match the Content-MD5 sent with SendContentMD5, meaning it was altered
+// in transit
+var ErrBadDigest = errors.New("upload was corrupted in transit")
+
+// ErrMultipartMismatch is returned when VerifyMultipart finds that the
+// object S3 assembled from a multipart upload doesn't match the parts sent
+var ErrMultipartMismatch = errors.New("multipart upload was assembled incorrectly")
+
+// BlobError is the error type returned by S3BlobStorage methods, recording
+// which operation failed, on which blob and in which bucket. It wraps the
+// underlying error, so errors.Is(err, ErrBlobNotFound) and similar checks
+// see through it.
+type BlobError struct {
+	Op string
+	// BlobID is empty for operations that aren't on an existing blob, such
+	// as stores and listings
+	BlobID string
+	Bucket string
+	Err    error
+}
+
+func (e *BlobError) Error() string {
+	target := e.Bucket
+	if e.BlobID != "" {
+		if target != "" {
+			target += "/"
+		}
+		target += e.BlobID
+	}
+	if target == "" {
+		return e.Op + ": " + e.Err.Error()
+	}
+	return e.Op + " " + target + ": " + e.Err.Error()
+}
+
+func (e *BlobError) Unwrap() error {
+	return e.Err
+}
+
+// wrapError wraps the error in *err in a BlobError for op and emits an Event.
+// It is deferred by public methods; errors that already carry a BlobError,
+// e.g. from a public method called by another, are left as they are.
+func (s *S3BlobStorage) wrapError(op, blobID string, err *error) {
+	s.finishOp(Event{Op: op, BlobID: blobID}, err)
+}
+
+// wrapStoreError is wrapError for stores, reporting the stored blob in the
+// operation's Event
+func (s *S3BlobStorage) wrapStoreError(op string, result *StoreResult, err *error) {
+	s.finishOp(Event{Op: op, BlobID: result.BlobID, Size: result.Size, Deduplicated: result.Deduplicated}, err)
+}
+
+// finishOp wraps *err in a BlobError for ev's operation and emits ev
+func (s *S3BlobStorage) finishOp(ev Event, err *error) {
+	var blobErr *BlobError
+	if *err != nil && !errors.As(*err, &blobErr) {
+		*err = &BlobError{Op: ev.Op, BlobID: ev.BlobID, Bucket: s.bucket, Err: *err}
+	}
+	ev.Err = *err
+	s.emit(ev)
+}
+
+// ErrBlobTooLarge is returned when content exceeds the configured MaxBlobSize
+type ErrBlobTooLarge struct {
+	// Size is the content size, or the number of bytes read before giving up
+	// when the content was streamed
+	Size int64
+	Max  int64
+}
+
+func (e *ErrBlobTooLarge) Error() string {
+	return fmt.Sprintf("blob size %d exceeds maximum of %d bytes", e.Size, e.Max)
+}
+
+// ErrRangeNotSatisfiable is returned by RetrieveRange when the range starts
+// past the end of the blob
+type ErrRangeNotSatisfiable struct {
+	Start int64
+	End   int64
+}
+
+func (e *ErrRangeNotSatisfiable) Error() string {
+	return fmt.Sprintf("range %d-%d not satisfiable", e.Start, e.End)
+}
+
+// ErrBlobArchived is returned when reading a blob held in an archive
+// storage class, such as GLACIER, that must be restored with Restore first
+type ErrBlobArchived struct {
+	State ArchiveState
+}
+
+func (e *ErrBlobArchived) Error() string {
+	if e.State.RestoreInProgress {
+		return fmt.Sprintf("blob is archived in %s: restore in progress", e.State.StorageClass)
+	}
+	return fmt.Sprintf("blob is archived in %s: must be restored before reading", e.State.StorageClass)
+}
+
+// isNotFound reports whether err means the object doesn't exist. Providers
+// disagree on the code: AWS uses NotFound for HeadObject and NoSuchKey for
+// GetObject, MinIO uses NoSuchKey for both, and a HEAD response has no body
+// to carry a code at all, leaving only the 404 status.
+func isNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NotFound", "NoSuchKey":
+			return true
+		}
+	}
+
+	var respErr *smithyhttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound
+}
+
+// isArchived reports whether err is S3 refusing to read an archived object
+// that has not been restored
+func isArchived(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidObjectState"
+}
+
+// isBadDigest reports whether err is S3 rejecting an upload whose body
+// didn't match its Content-MD5
+func isBadDigest(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "BadDigest"
+}
+
+// isThrottleError reports whether err indicates the backend is throttling
+// requests, either by error code (e.g. SlowDown) or by HTTP status
+func isThrottleError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary {
+		return true
+	}
+
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.HTTPStatusCode() {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return true
+		}
+	}
+
+	return false
+}
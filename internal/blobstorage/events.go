@@ -0,0 +1,34 @@
+package blobstorage
+
+import "time"
+
+// Event describes a completed operation, sent on Config.Events for auditing
+// or metrics
+type Event struct {
+	// Op is the name of the method called, e.g. "Store" or "Delete"
+	Op string
+	// BlobID is empty for operations on many blobs and for stores that
+	// failed before the content was hashed
+	BlobID string
+	// Size is the content size in bytes for stores, and zero otherwise
+	Size int64
+	// Deduplicated is true for stores that found the content already stored
+	Deduplicated bool
+	// Err is the error returned to the caller, if any
+	Err  error
+	Time time.Time
+}
+
+// emit sends ev on the events channel without blocking, dropping it if the
+// channel is full so a slow consumer never stalls storage operations
+func (s *S3BlobStorage) emit(ev Event) {
+	if s.events == nil {
+		return
+	}
+
+	ev.Time = s.clock()
+	select {
+	case s.events <- ev:
+	default:
+	}
+}
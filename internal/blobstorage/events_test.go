@@ -0,0 +1,172 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	storage.clock = func() time.Time { return now }
+
+	events := make(chan Event, 10)
+	storage.events = events
+
+	content := "audited content"
+	blobID := testBlobID(content)
+	missingID := testBlobID("missing")
+
+	if _, err := storage.Store(content); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := storage.StoreReader(strings.NewReader(content)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := storage.Retrieve(missingID); !errors.Is(err, ErrBlobNotFound) {
+		t.Fatalf("expected ErrBlobNotFound, got %v", err)
+	}
+	if err := storage.Delete(blobID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Event{
+		{Op: "Store", BlobID: blobID, Size: int64(len(content))},
+		{Op: "StoreReader", BlobID: blobID, Size: int64(len(content)), Deduplicated: true},
+		{Op: "Retrieve", BlobID: missingID, Err: ErrBlobNotFound},
+		{Op: "Delete", BlobID: blobID},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+	for _, want := range expected {
+		got := <-events
+		if got.Op != want.Op || got.BlobID != want.BlobID || got.Size != want.Size || got.Deduplicated != want.Deduplicated {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+		if want.Err == nil && got.Err != nil || want.Err != nil && !errors.Is(got.Err, want.Err) {
+			t.Errorf("%s: expected error %v, got %v", want.Op, want.Err, got.Err)
+		}
+		if !got.Time.Equal(now) {
+			t.Errorf("%s: expected time %v, got %v", want.Op, now, got.Time)
+		}
+	}
+}
+
+func TestEventsFullChannel(t *testing.T) {
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	events := make(chan Event, 1)
+	storage.events = events
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, content := range []string{"first", "second", "third"} {
+			if _, err := storage.Store(content); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stores blocked on a full events channel")
+	}
+
+	if ev := <-events; ev.BlobID != testBlobID("first") {
+		t.Errorf("expected the first event to be kept, got %+v", ev)
+	}
+	if len(events) != 0 {
+		t.Errorf("expected later events to be dropped, got %d queued", len(events))
+	}
+}
+
+func TestEventsNestedOperations(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(storage *S3BlobStorage)
+		op    func(storage *S3BlobStorage, blobID string) error
+		want  string
+	}{
+		{
+			name: "DeleteStrict",
+			op:   func(storage *S3BlobStorage, blobID string) error { return storage.DeleteStrict(blobID) },
+			want: "DeleteStrict",
+		},
+		{
+			name:  "Delete with reference counting",
+			setup: func(storage *S3BlobStorage) { storage.referenceCounting = true },
+			op:    func(storage *S3BlobStorage, blobID string) error { return storage.Delete(blobID) },
+			want:  "Delete",
+		},
+		{
+			name:  "Delete in dry-run mode",
+			setup: func(storage *S3BlobStorage) { storage.dryRun = true },
+			op:    func(storage *S3BlobStorage, blobID string) error { return storage.Delete(blobID) },
+			want:  "Delete",
+		},
+		{
+			name: "StoreMany",
+			op: func(storage *S3BlobStorage, blobID string) error {
+				_, err := storage.StoreMany(context.Background(), [][]byte{[]byte("other content")})
+				return err
+			},
+			want: "StoreMany",
+		},
+		{
+			name: "DeleteBatch with reference counting",
+			setup: func(storage *S3BlobStorage) {
+				storage.referenceCounting = true
+			},
+			op: func(storage *S3BlobStorage, blobID string) error {
+				return storage.DeleteBatch(context.Background(), []string{blobID})
+			},
+			want: "DeleteBatch",
+		},
+		{
+			name: "RetrieveToFile",
+			op: func(storage *S3BlobStorage, blobID string) error {
+				return storage.RetrieveToFile(blobID, filepath.Join(t.TempDir(), "blob"))
+			},
+			want: "RetrieveToFile",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, _ := newBucketMock()
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			if tt.setup != nil {
+				tt.setup(storage)
+			}
+			blobID, err := storage.Store("audited content")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			events := make(chan Event, 10)
+			storage.events = events
+
+			if err := tt.op(storage, blobID); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			close(events)
+			var ops []string
+			for ev := range events {
+				ops = append(ops, ev.Op)
+			}
+			if len(ops) != 1 || ops[0] != tt.want {
+				t.Errorf("expected a single %s event, got %v", tt.want, ops)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package blobstorage
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// metaExpiresAt is the object metadata key recording when a blob stored with
+// StoreWithExpiry expires, as an RFC 3339 timestamp
+const metaExpiresAt = "expires-at"
+
+// StoreWithExpiry stores content like Store, marking it to expire after ttl,
+// e.g. for one-time download links. The expiry is recorded in the object's
+// Expires header and x-amz-meta-expires-at metadata; S3 does not delete the
+// blob by itself, so deletion needs a bucket lifecycle rule or a job that
+// deletes blobs IsExpired reports. If identical content is already stored
+// the existing blob is kept with its expiry, or lack of one, as is.
+func (s *S3BlobStorage) StoreWithExpiry(content string, ttl time.Duration) (_ string, err error) {
+	var result StoreResult
+	defer s.wrapStoreError("StoreWithExpiry", &result, &err)
+	if ttl <= 0 {
+		return "", fmt.Errorf("invalid expiry %s: must be positive", ttl)
+	}
+	result, err = s.storeResult(s.ctx, []byte(content), putOptions{expiresAt: s.clock().Add(ttl)})
+	return result.BlobID, err
+}
+
+// IsExpired reports whether a blob stored with StoreWithExpiry has passed
+// its expiry. Blobs stored without one never expire.
+func (s *S3BlobStorage) IsExpired(blobID string) (_ bool, err error) {
+	defer s.wrapError("IsExpired", blobID, &err)
+	if !s.enabled {
+		return false, ErrStorageDisabled
+	}
+
+	if err := s.validateBlobID(blobID); err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "IsExpired"); err != nil {
+		return false, err
+	}
+	defer s.releaseOp()
+
+	result, err := s.client.HeadObject(ctx, s.headObjectInput(s.blobKey(blobID)))
+	if err != nil {
+		if isNotFound(err) {
+			return false, fmt.Errorf("failed to get blob expiry: %w: %w", ErrBlobNotFound, err)
+		}
+		return false, fmt.Errorf("failed to get blob expiry: %w", err)
+	}
+
+	value, ok := result.Metadata[metaExpiresAt]
+	if !ok {
+		return false, nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return false, fmt.Errorf("invalid blob expiry %q: %w", value, err)
+	}
+	return !s.clock().Before(expiresAt), nil
+}
+
+// objectMetadata returns the metadata to upload a blob with: the caller's
+// over any DefaultMetadata, plus internal keys for its encoding and expiry
+func (s *S3BlobStorage) objectMetadata(opts putOptions, encodingMetadata map[string]string) map[string]string {
+	internal := encodingMetadata
+	if !opts.expiresAt.IsZero() {
+		internal = maps.Clone(encodingMetadata)
+		if internal == nil {
+			internal = make(map[string]string, 1)
+		}
+		internal[metaExpiresAt] = opts.expiresAt.UTC().Format(time.RFC3339)
+	}
+	return mergeMetadata(mergeMetadata(s.defaultMetadata, opts.metadata), internal)
+}
+
+// expiresHeader returns the Expires header to upload a blob with, if any
+func expiresHeader(opts putOptions) *time.Time {
+	if opts.expiresAt.IsZero() {
+		return nil
+	}
+	return aws.Time(opts.expiresAt)
+}
@@ -0,0 +1,98 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestStoreWithExpiry(t *testing.T) {
+	mock, objects := newBucketMock()
+	putObject := mock.putObjectFunc
+	var expires *time.Time
+	mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		expires = params.Expires
+		return putObject(ctx, params, optFns...)
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	blobID, err := storage.StoreWithExpiry("one-time download", time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expires == nil || time.Until(*expires) < 59*time.Minute || time.Until(*expires) > time.Hour {
+		t.Errorf("expected the Expires header to be about an hour away, got %v", expires)
+	}
+
+	expired, err := storage.IsExpired(blobID)
+	if err != nil || expired {
+		t.Errorf("expected a fresh blob not to be expired, got %v (err=%v)", expired, err)
+	}
+
+	metadata, err := storage.GetMetadata(blobID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := metadata[metaExpiresAt]; ok {
+		t.Error("expected the expiry to be hidden from GetMetadata")
+	}
+
+	objects["blobs/"+blobID].metadata[metaExpiresAt] = time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	if expired, err := storage.IsExpired(blobID); err != nil || !expired {
+		t.Errorf("expected a blob past its expiry to be expired, got %v (err=%v)", expired, err)
+	}
+
+	permanentID, err := storage.Store("kept forever")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expired, err := storage.IsExpired(permanentID); err != nil || expired {
+		t.Errorf("expected a blob without expiry not to expire, got %v (err=%v)", expired, err)
+	}
+
+	if _, err := storage.StoreWithExpiry("content", 0); err == nil {
+		t.Error("expected an error for a non-positive ttl")
+	}
+	if _, err := storage.IsExpired(testBlobID("missing")); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+}
+
+func TestIsExpiredClock(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.clock = func() time.Time { return now }
+
+	blobID, err := storage.StoreWithExpiry("expiring content", time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected bool
+	}{
+		{name: "just stored", now: now, expected: false},
+		{name: "before expiry", now: now.Add(59 * time.Minute), expected: false},
+		{name: "at expiry", now: now.Add(time.Hour), expected: true},
+		{name: "after expiry", now: now.Add(2 * time.Hour), expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage.clock = func() time.Time { return tt.now }
+			expired, err := storage.IsExpired(blobID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if expired != tt.expected {
+				t.Errorf("expected expired=%v, got %v", tt.expected, expired)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package blobstorage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// expressBucketSuffix ends the name of every S3 Express One Zone directory
+// bucket, after the availability zone ID
+const expressBucketSuffix = "--x-s3"
+
+// expressBucketName returns bucket as a directory bucket name in zone, e.g.
+// "attachments" in "use1-az4" becomes "attachments--use1-az4--x-s3". Names
+// that already carry the suffix are returned as is.
+func expressBucketName(bucket, zone string) string {
+	if strings.HasSuffix(bucket, expressBucketSuffix) {
+		return bucket
+	}
+	return bucket + "--" + zone + expressBucketSuffix
+}
+
+// validateExpressOneZone checks the S3 Express One Zone settings in c
+// against the features directory buckets support
+func validateExpressOneZone(c Config) error {
+	if !c.ExpressOneZone {
+		if c.AvailabilityZone != "" {
+			return fmt.Errorf("availability zone requires express one zone")
+		}
+		return nil
+	}
+
+	if c.AvailabilityZone == "" {
+		return fmt.Errorf("express one zone requires an availability zone ID, e.g. use1-az4")
+	}
+	if strings.HasSuffix(c.Bucket, expressBucketSuffix) && !strings.HasSuffix(c.Bucket, "--"+c.AvailabilityZone+expressBucketSuffix) {
+		return fmt.Errorf("directory bucket %q is not in availability zone %q", c.Bucket, c.AvailabilityZone)
+	}
+
+	switch {
+	case c.ObjectLockMode != "":
+		return fmt.Errorf("express one zone does not support object lock")
+	case c.TenantID != "":
+		return fmt.Errorf("express one zone does not support object tags, needed for tenant ID")
+	case c.ACL != "" || c.BucketOwnerFullControl:
+		return fmt.Errorf("express one zone does not support ACLs")
+	case c.SSECustomerKey != nil:
+		return fmt.Errorf("express one zone does not support SSE-C")
+	case c.StorageClass != "" && c.StorageClass != string(types.StorageClassExpressOnezone):
+		return fmt.Errorf("express one zone requires storage class %q, got %q", types.StorageClassExpressOnezone, c.StorageClass)
+	}
+	return nil
+}
+
+// expressBucketConfiguration returns the CreateBucket configuration for a
+// single-zone directory bucket in zone
+func expressBucketConfiguration(zone string) *types.CreateBucketConfiguration {
+	return &types.CreateBucketConfiguration{
+		Location: &types.LocationInfo{
+			Type: types.LocationTypeAvailabilityZone,
+			Name: aws.String(zone),
+		},
+		Bucket: &types.BucketInfo{
+			Type:           types.BucketTypeDirectory,
+			DataRedundancy: types.DataRedundancySingleAvailabilityZone,
+		},
+	}
+}
@@ -0,0 +1,103 @@
+package blobstorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestEnsureBucketExpressOneZone(t *testing.T) {
+	var input *s3.CreateBucketInput
+	mock := &mockS3Client{
+		createBucketFunc: func(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+			input = params
+			return &s3.CreateBucketOutput{}, nil
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "attachments--use1-az4--x-s3", true)
+	storage.region = "us-east-1"
+	storage.availabilityZone = "use1-az4"
+
+	if err := storage.ensureBucket(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if aws.ToString(input.Bucket) != "attachments--use1-az4--x-s3" {
+		t.Errorf("expected directory bucket name, got %q", aws.ToString(input.Bucket))
+	}
+	if input.ObjectLockEnabledForBucket != nil {
+		t.Errorf("expected no object lock setting, got %v", *input.ObjectLockEnabledForBucket)
+	}
+	bucketCfg := input.CreateBucketConfiguration
+	if bucketCfg == nil || bucketCfg.Location == nil || bucketCfg.Bucket == nil {
+		t.Fatalf("expected location and bucket info, got %+v", bucketCfg)
+	}
+	if bucketCfg.LocationConstraint != "" {
+		t.Errorf("expected no LocationConstraint, got %q", bucketCfg.LocationConstraint)
+	}
+	if bucketCfg.Location.Type != types.LocationTypeAvailabilityZone || aws.ToString(bucketCfg.Location.Name) != "use1-az4" {
+		t.Errorf("expected availability zone use1-az4, got %s %q", bucketCfg.Location.Type, aws.ToString(bucketCfg.Location.Name))
+	}
+	if bucketCfg.Bucket.Type != types.BucketTypeDirectory || bucketCfg.Bucket.DataRedundancy != types.DataRedundancySingleAvailabilityZone {
+		t.Errorf("expected single-zone directory bucket, got %s %s", bucketCfg.Bucket.Type, bucketCfg.Bucket.DataRedundancy)
+	}
+}
+
+func TestExpressBucketName(t *testing.T) {
+	tests := []struct {
+		bucket   string
+		expected string
+	}{
+		{bucket: "attachments", expected: "attachments--use1-az4--x-s3"},
+		{bucket: "attachments--use1-az4--x-s3", expected: "attachments--use1-az4--x-s3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.bucket, func(t *testing.T) {
+			if got := expressBucketName(tt.bucket, "use1-az4"); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidateExpressOneZone(t *testing.T) {
+	express := func(modify func(c *Config)) Config {
+		c := Config{ExpressOneZone: true, AvailabilityZone: "use1-az4", Bucket: "attachments"}
+		if modify != nil {
+			modify(&c)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name        string
+		cfg         Config
+		expectError bool
+	}{
+		{name: "disabled", cfg: Config{}},
+		{name: "zone without express", cfg: Config{AvailabilityZone: "use1-az4"}, expectError: true},
+		{name: "express", cfg: express(nil)},
+		{name: "directory bucket name", cfg: express(func(c *Config) { c.Bucket = "attachments--use1-az4--x-s3" })},
+		{name: "bucket in another zone", cfg: express(func(c *Config) { c.Bucket = "attachments--usw2-az1--x-s3" }), expectError: true},
+		{name: "no zone", cfg: express(func(c *Config) { c.AvailabilityZone = "" }), expectError: true},
+		{name: "express storage class", cfg: express(func(c *Config) { c.StorageClass = "EXPRESS_ONEZONE" })},
+		{name: "other storage class", cfg: express(func(c *Config) { c.StorageClass = "GLACIER" }), expectError: true},
+		{name: "object lock", cfg: express(func(c *Config) { c.ObjectLockMode = ObjectLockGovernance }), expectError: true},
+		{name: "tenant ID", cfg: express(func(c *Config) { c.TenantID = "acme" }), expectError: true},
+		{name: "ACL", cfg: express(func(c *Config) { c.ACL = "private" }), expectError: true},
+		{name: "SSE-C", cfg: express(func(c *Config) { c.SSECustomerKey = make([]byte, 32) }), expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateExpressOneZone(tt.cfg)
+			if tt.expectError != (err != nil) {
+				t.Errorf("expected error=%v, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
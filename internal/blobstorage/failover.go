@@ -0,0 +1,139 @@
+package blobstorage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// defaultFailoverTimeout is how long, in seconds, a read waits for a response
+// before failing over when FailoverConfig.Timeout isn't set
+const defaultFailoverTimeout = 5
+
+// errFailoverTimeout cancels a read that got no response within the failover
+// timeout. It wraps context.DeadlineExceeded so the circuit breaker counts it
+// as a backend failure.
+var errFailoverTimeout = fmt.Errorf("no response within the failover timeout: %w", context.DeadlineExceeded)
+
+// FailoverConfig lists read replicas of the bucket, e.g. kept in another
+// region by S3 replication. Reads that fail with a connection error, a
+// timeout or a 5xx response are retried against each replica in turn; a
+// blob that isn't found is not retried. Writes only go to the primary.
+type FailoverConfig struct {
+	Replicas []FailoverReplica `yaml:"replicas"`
+	// Timeout is how long, in seconds, to wait for a response from each
+	// location before trying the next (default 5). It only bounds the wait
+	// for the response to start, not the download of the content.
+	Timeout int `yaml:"timeout"`
+}
+
+// FailoverReplica is a read replica, accessed with the primary's credentials
+type FailoverReplica struct {
+	Endpoint string `yaml:"endpoint"`
+	Region   string `yaml:"region"`
+	// Bucket defaults to the primary bucket's name
+	Bucket string `yaml:"bucket"`
+}
+
+// replica is a read replica's client, bucket, circuit breaker and a name
+// for logs
+type replica struct {
+	client  S3Api
+	bucket  string
+	name    string
+	breaker *circuitBreaker
+}
+
+// primary returns the primary bucket as a read location
+func (s *S3BlobStorage) primary() replica {
+	return replica{client: s.client, bucket: s.bucket, name: "primary", breaker: s.breaker}
+}
+
+// getObject starts downloading a blob, failing over to the read replicas
+// when the primary is unavailable, and maps a missing key to ErrBlobNotFound.
+// It also returns the location that served the blob.
+func (s *S3BlobStorage) getObject(ctx context.Context, blobID string) (*s3.GetObjectOutput, replica, error) {
+	key := s.blobKey(blobID)
+
+	loc := s.primary()
+	var result *s3.GetObjectOutput
+	var err error
+	if len(s.replicas) == 0 {
+		result, err = s.client.GetObject(ctx, s.getObjectInput(s.bucket, key))
+	} else {
+		result, loc, err = s.getObjectWithFailover(ctx, key)
+	}
+
+	if err != nil {
+		if isNotFound(err) {
+			return nil, loc, fmt.Errorf("failed to retrieve blob: %w: %w", ErrBlobNotFound, err)
+		}
+		if isArchived(err) {
+			return nil, loc, fmt.Errorf("failed to retrieve blob: %w: %w", s.archivedError(ctx, key, err), err)
+		}
+		return nil, loc, fmt.Errorf("failed to retrieve blob: %w", err)
+	}
+	return result, loc, nil
+}
+
+// getObjectWithFailover tries the primary and then each replica until one
+// responds with anything other than an availability failure. Locations whose
+// circuit breaker is open are skipped without a request.
+func (s *S3BlobStorage) getObjectWithFailover(ctx context.Context, key string) (*s3.GetObjectOutput, replica, error) {
+	locations := append([]replica{s.primary()}, s.replicas...)
+
+	var err error
+	for i, loc := range locations {
+		if i > 0 {
+			s.logger.Warnf("blobstorage: failed to read %s from %s, trying %s: %v", key, locations[i-1].name, loc.name, err)
+		}
+		if err = loc.breaker.allow(); err != nil {
+			continue
+		}
+
+		var result *s3.GetObjectOutput
+		var timedOut bool
+		result, timedOut, err = s.getObjectFrom(ctx, loc, key)
+		if err == nil {
+			return result, loc, nil
+		}
+		if ctx.Err() != nil || !(timedOut || isBackendFailure(err)) {
+			return nil, loc, err
+		}
+	}
+	return nil, locations[len(locations)-1], err
+}
+
+// getObjectFrom sends a GetObject to one location, giving up if no response
+// arrives within the failover timeout. The returned body stays tied to the
+// request's context until it is closed.
+func (s *S3BlobStorage) getObjectFrom(ctx context.Context, loc replica, key string) (*s3.GetObjectOutput, bool, error) {
+	// The timeout only covers the response headers, so it can't be a
+	// context deadline
+	attemptCtx, cancel := context.WithCancelCause(ctx)
+	timer := time.AfterFunc(s.failoverTimeout, func() { cancel(errFailoverTimeout) })
+
+	result, err := loc.client.GetObject(attemptCtx, s.getObjectInput(loc.bucket, key))
+	stopped := timer.Stop()
+	if err != nil {
+		cancel(nil)
+		return nil, !stopped, err
+	}
+
+	result.Body = &cancelOnClose{ReadCloser: result.Body, cancel: func() { cancel(nil) }}
+	return result, false, nil
+}
+
+// cancelOnClose releases a request's context when its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
@@ -0,0 +1,269 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// serveMock serves content for every GetObject, recording the buckets asked
+func serveMock(content string, buckets *[]string) *mockS3Client {
+	return &mockS3Client{
+		getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			*buckets = append(*buckets, aws.ToString(params.Bucket))
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(content))}, nil
+		},
+	}
+}
+
+func TestRetrieveFailover(t *testing.T) {
+	content := "replicated content"
+	blobID := testBlobID(content)
+
+	tests := []struct {
+		name           string
+		primaryErr     error
+		primaryBlocks  bool
+		expectFailover bool
+		expectNotFound bool
+	}{
+		{name: "connection error", primaryErr: errors.New("dial tcp: connection refused"), expectFailover: true},
+		{name: "server error", primaryErr: &smithy.GenericAPIError{Code: "InternalError", Fault: smithy.FaultServer}, expectFailover: true},
+		{name: "timeout", primaryBlocks: true, expectFailover: true},
+		{name: "not found", primaryErr: &smithy.GenericAPIError{Code: "NoSuchKey"}, expectNotFound: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &mockS3Client{
+				getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					if tt.primaryBlocks {
+						<-ctx.Done()
+						return nil, ctx.Err()
+					}
+					return nil, tt.primaryErr
+				},
+			}
+			var replicaBuckets []string
+			storage := newMockS3BlobStorage(primary, "test-bucket", true)
+			storage.replicas = []replica{{client: serveMock(content, &replicaBuckets), bucket: "replica-bucket", name: "eu-west-1"}}
+			storage.failoverTimeout = 50 * time.Millisecond
+
+			retrieved, err := storage.Retrieve(blobID)
+
+			if tt.expectNotFound {
+				if !errors.Is(err, ErrBlobNotFound) {
+					t.Errorf("expected ErrBlobNotFound, got %v", err)
+				}
+				if len(replicaBuckets) != 0 {
+					t.Errorf("expected no failover for a missing blob, got reads from %v", replicaBuckets)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if retrieved != content {
+				t.Errorf("expected content=%q, got %q", content, retrieved)
+			}
+			if len(replicaBuckets) != 1 || replicaBuckets[0] != "replica-bucket" {
+				t.Errorf("expected one read from the replica bucket, got %v", replicaBuckets)
+			}
+		})
+	}
+}
+
+func TestRetrieveFailoverAllLocationsDown(t *testing.T) {
+	down := &mockS3Client{
+		getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return nil, errors.New("dial tcp: connection refused")
+		},
+	}
+	storage := newMockS3BlobStorage(down, "test-bucket", true)
+	storage.replicas = []replica{{client: down, bucket: "replica-a"}, {client: down, bucket: "replica-b"}}
+	storage.failoverTimeout = time.Second
+
+	if _, err := storage.Retrieve(testBlobID("content")); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("expected the last location's error, got %v", err)
+	}
+}
+
+func TestRetrieveReaderPrimaryBodyOutlivesFailoverTimeout(t *testing.T) {
+	content := "slowly read content"
+	var buckets []string
+	storage := newMockS3BlobStorage(serveMock(content, &buckets), "test-bucket", true)
+	storage.replicas = []replica{{client: serveMock(content, &buckets), bucket: "replica-bucket"}}
+	storage.failoverTimeout = 10 * time.Millisecond
+
+	r, err := storage.RetrieveReader(context.Background(), testBlobID(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+
+	time.Sleep(30 * time.Millisecond)
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != content {
+		t.Errorf("expected %q, got %q (err=%v)", content, string(data), err)
+	}
+	if len(buckets) != 1 || buckets[0] != "test-bucket" {
+		t.Errorf("expected a single read from the primary, got %v", buckets)
+	}
+}
+
+func TestRetrieveFailoverCircuitOpen(t *testing.T) {
+	content := "replicated content"
+	primaryCalls := 0
+	primary := &mockS3Client{
+		getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			primaryCalls++
+			return nil, errors.New("dial tcp: connection refused")
+		},
+	}
+	storage, _ := newBreakerStorage(primary, 1, time.Minute)
+	storage.failoverTimeout = time.Second
+
+	var replicaBuckets []string
+	replicaBreaker := newCircuitBreaker(1, time.Minute)
+	storage.replicas = []replica{{
+		client:  &instrumentedClient{next: serveMock(content, &replicaBuckets), metrics: NoopMetrics{}, breaker: replicaBreaker},
+		bucket:  "replica-bucket",
+		name:    "eu-west-1",
+		breaker: replicaBreaker,
+	}}
+
+	// The first read opens the primary's breaker and fails over
+	if _, err := storage.Retrieve(testBlobID(content)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Later reads skip the primary without a request and still reach the replica
+	for _, retrieve := range []func() error{
+		func() error { _, err := storage.Retrieve(testBlobID(content)); return err },
+		func() error { _, _, _, err := storage.RetrieveWithMetadata(testBlobID(content)); return err },
+		func() error {
+			r, err := storage.RetrieveReader(context.Background(), testBlobID(content))
+			if err == nil {
+				_, err = io.ReadAll(r)
+				_ = r.Close()
+			}
+			return err
+		},
+	} {
+		if err := retrieve(); err != nil {
+			t.Fatalf("expected the replica to serve the read, got %v", err)
+		}
+	}
+	if primaryCalls != 1 {
+		t.Errorf("expected the open breaker to skip the primary, got %d primary reads", primaryCalls)
+	}
+	if len(replicaBuckets) != 4 {
+		t.Errorf("expected 4 replica reads, got %v", replicaBuckets)
+	}
+
+	// Writes still fail fast on the primary's breaker
+	if _, err := storage.Store("new content"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen for a write, got %v", err)
+	}
+
+	// With every location's breaker open, reads fail fast
+	replicaBreaker.record(errors.New("dial tcp: connection refused"))
+	if _, err := storage.Retrieve(testBlobID(content)); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+	if primaryCalls != 1 || len(replicaBuckets) != 4 {
+		t.Errorf("expected no requests with every breaker open, got %d primary and %d replica reads", primaryCalls, len(replicaBuckets))
+	}
+}
+
+func TestRetrieveReaderResumesFromServingReplica(t *testing.T) {
+	content := strings.Repeat("replicated content ", 100)
+	primary := &mockS3Client{
+		getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return nil, errors.New("dial tcp: connection refused")
+		},
+	}
+
+	var ranges []string
+	replicaClient := &mockS3Client{
+		getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			if aws.ToString(params.Bucket) != "replica-bucket" {
+				t.Errorf("expected reads from replica-bucket, got %q", aws.ToString(params.Bucket))
+			}
+			start, remaining := 0, 300
+			if params.Range != nil {
+				ranges = append(ranges, *params.Range)
+				if _, err := fmt.Sscanf(*params.Range, "bytes=%d-", &start); err != nil {
+					return nil, err
+				}
+				remaining = len(content)
+			}
+			return &s3.GetObjectOutput{
+				Body:          &flakyBody{r: strings.NewReader(content[start:]), remaining: remaining},
+				ContentLength: aws.Int64(int64(len(content) - start)),
+				ETag:          aws.String(`"etag"`),
+			}, nil
+		},
+	}
+
+	storage := newMockS3BlobStorage(primary, "test-bucket", true)
+	storage.replicas = []replica{{client: replicaClient, bucket: "replica-bucket", name: "eu-west-1"}}
+	storage.failoverTimeout = time.Second
+	storage.resumeRetries = 1
+
+	r, err := storage.RetrieveReader(context.Background(), testBlobID(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != content {
+		t.Fatalf("expected full content, got %d bytes (err=%v)", len(data), err)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=300-" {
+		t.Errorf("expected one resume from the replica at byte 300, got %v", ranges)
+	}
+}
+
+func TestRetrieveFailoverStalledPrimaryOpensBreaker(t *testing.T) {
+	content := "replicated content"
+	primaryCalls := 0
+	primary := &mockS3Client{
+		getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			primaryCalls++
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	storage, _ := newBreakerStorage(primary, 3, time.Minute)
+	storage.failoverTimeout = 10 * time.Millisecond
+
+	var replicaBuckets []string
+	storage.replicas = []replica{{
+		client: &instrumentedClient{next: serveMock(content, &replicaBuckets), metrics: NoopMetrics{}},
+		bucket: "replica-bucket",
+		name:   "eu-west-1",
+	}}
+
+	for i := 0; i < 5; i++ {
+		if _, err := storage.Retrieve(testBlobID(content)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if primaryCalls != 3 {
+		t.Errorf("expected the breaker to open after 3 timed out reads, got %d primary reads", primaryCalls)
+	}
+	if len(replicaBuckets) != 5 {
+		t.Errorf("expected every read to be served by the replica, got %v", replicaBuckets)
+	}
+}
@@ -0,0 +1,83 @@
+package blobstorage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// StoreFile stores the contents of the file at path and returns its blob ID
+func (s *S3BlobStorage) StoreFile(path string) (_ string, err error) {
+	var result StoreResult
+	defer s.wrapStoreError("StoreFile", &result, &err)
+	if !s.enabled {
+		return "", ErrStorageDisabled
+	}
+
+	f, err := os.Open(path) // #nosec G304 -- path is chosen by the caller
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	result, err = s.storeReader(f, -1, nil)
+	return result.BlobID, err
+}
+
+// RetrieveToFile writes a blob's content to path. The content is streamed to
+// a temporary file in the same directory that is renamed into place once
+// complete, so path never holds a partial blob. Blobs larger than MaxBlobSize
+// are rejected with ErrBlobTooLarge.
+func (s *S3BlobStorage) RetrieveToFile(blobID, path string) (err error) {
+	defer s.wrapError("RetrieveToFile", blobID, &err)
+	if !s.enabled {
+		return ErrStorageDisabled
+	}
+
+	r, err := s.retrieveReader(s.ctx, blobID)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	// Copy at most one byte past the size limit so oversized blobs are
+	// rejected without writing all of them
+	src := io.Reader(r)
+	if s.maxBlobSize > 0 {
+		src = io.LimitReader(r, s.maxBlobSize+1)
+	}
+
+	size, err := s.copyBuffered(tmp, src)
+	if err != nil {
+		return fmt.Errorf("failed to write blob to file: %w", err)
+	}
+	if err := s.checkSize(size); err != nil {
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
+	committed = true
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package blobstorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestStoreFileRetrieveToFile(t *testing.T) {
+	dir := t.TempDir()
+	content := "attachment stored from a file"
+
+	src := filepath.Join(dir, "src.txt")
+	if err := os.WriteFile(src, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write source file: %v", err)
+	}
+
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.verifyOnRetrieve = true
+
+	blobID, err := storage.StoreFile(src)
+	if err != nil {
+		t.Fatalf("unexpected error storing file: %v", err)
+	}
+	if blobID != testBlobID(content) {
+		t.Errorf("expected blobID=%q, got %q", testBlobID(content), blobID)
+	}
+
+	dst := filepath.Join(dir, "dst.txt")
+	if err := storage.RetrieveToFile(blobID, dst); err != nil {
+		t.Fatalf("unexpected error retrieving to file: %v", err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+	if string(got) != content {
+		t.Errorf("expected file content=%q, got %q", content, got)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("expected only the source and destination files, found %d entries", len(entries))
+	}
+}
+
+func TestStoreFileMissing(t *testing.T) {
+	storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+
+	if _, err := storage.StoreFile(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestRetrieveToFileFailureLeavesNoFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(*S3BlobStorage, map[string]*storedObject) string
+		check func(*testing.T, error)
+	}{
+		{
+			name: "missing blob",
+			setup: func(s *S3BlobStorage, objects map[string]*storedObject) string {
+				return testBlobID("missing")
+			},
+			check: func(t *testing.T, err error) {
+				if !errors.Is(err, ErrBlobNotFound) {
+					t.Errorf("expected ErrBlobNotFound, got %v", err)
+				}
+			},
+		},
+		{
+			name: "blob over size limit",
+			setup: func(s *S3BlobStorage, objects map[string]*storedObject) string {
+				content := "content over the limit"
+				objects["blobs/"+testBlobID(content)] = &storedObject{body: []byte(content)}
+				s.maxBlobSize = 8
+				return testBlobID(content)
+			},
+			check: func(t *testing.T, err error) {
+				var tooLarge *ErrBlobTooLarge
+				if !errors.As(err, &tooLarge) {
+					t.Errorf("expected ErrBlobTooLarge, got %v", err)
+				}
+			},
+		},
+		{
+			name: "corrupted blob",
+			setup: func(s *S3BlobStorage, objects map[string]*storedObject) string {
+				objects["blobs/"+testBlobID("original")] = &storedObject{body: []byte("tampered")}
+				s.verifyOnRetrieve = true
+				return testBlobID("original")
+			},
+			check: func(t *testing.T, err error) {
+				if !errors.Is(err, ErrIntegrityMismatch) {
+					t.Errorf("expected ErrIntegrityMismatch, got %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, objects := newBucketMock()
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			blobID := tt.setup(storage, objects)
+
+			dir := t.TempDir()
+			tt.check(t, storage.RetrieveToFile(blobID, filepath.Join(dir, "dst")))
+
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("expected no files after a failed retrieve, found %d", len(entries))
+			}
+		})
+	}
+}
+
+// countingWriter counts the writes made to it
+type countingWriter struct {
+	writes int
+	n      int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func TestCopyBuffered(t *testing.T) {
+	const size = 4 << 20
+
+	tests := []struct {
+		name           string
+		copyBufferSize int
+		expectedWrites int
+	}{
+		{name: "default 64KB", expectedWrites: size / defaultCopyBufferSize},
+		{name: "1MB", copyBufferSize: 1 << 20, expectedWrites: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+			storage.copyBufferSize = tt.copyBufferSize
+
+			// bytes.Reader implements WriterTo, which must not bypass the buffer
+			w := &countingWriter{}
+			n, err := storage.copyBuffered(w, bytes.NewReader(make([]byte, size)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if n != size || w.n != size {
+				t.Errorf("expected %d bytes copied, got %d (written %d)", size, n, w.n)
+			}
+			if w.writes != tt.expectedWrites {
+				t.Errorf("expected %d writes, got %d", tt.expectedWrites, w.writes)
+			}
+		})
+	}
+}
+
+func BenchmarkRetrieveToFile(b *testing.B) {
+	content := bytes.Repeat([]byte("attachment bytes "), 1<<20)
+	blobID := testBlobID(string(content))
+	mock := &mockS3Client{
+		getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(content))}, nil
+		},
+	}
+	dst := filepath.Join(b.TempDir(), "blob")
+
+	for _, bufSize := range []int{defaultCopyBufferSize, 1 << 20} {
+		b.Run(fmt.Sprintf("buffer=%dKB", bufSize>>10), func(b *testing.B) {
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			storage.copyBufferSize = bufSize
+
+			b.SetBytes(int64(len(content)))
+			for i := 0; i < b.N; i++ {
+				if err := storage.RetrieveToFile(blobID, dst); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+//go:build gcs
+
+// GCS support is opt-in so the default build doesn't compile the Google
+// Cloud client. Build and test with -tags gcs.
+
+package blobstorage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+// GCSConfig holds Google Cloud Storage blob storage configuration
+type GCSConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Bucket  string `yaml:"bucket"`
+	// CredentialsFile is a service account key file; empty uses Application
+	// Default Credentials
+	CredentialsFile string `yaml:"credentials_file"`
+	// Endpoint overrides the GCS API endpoint, e.g. for an emulator
+	Endpoint string `yaml:"endpoint"`
+	Timeout  int    `yaml:"timeout"` // seconds
+}
+
+// GCSBlobStorage implements BlobStorage on Google Cloud Storage using the same
+// blobs/<sha256> object naming and deduplication as S3BlobStorage
+type GCSBlobStorage struct {
+	client  *storage.Client
+	bucket  *storage.BucketHandle
+	enabled bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+	closed  atomic.Bool
+}
+
+var _ BlobStorage = (*GCSBlobStorage)(nil)
+
+// NewGCSBlobStorage creates a new GCS blob storage instance. Unlike S3, the
+// bucket is not created on demand since that needs a project and location;
+// it must already exist.
+func NewGCSBlobStorage(cfg GCSConfig) (*GCSBlobStorage, error) {
+	if !cfg.Enabled {
+		return &GCSBlobStorage{enabled: false}, nil
+	}
+
+	if cfg.Bucket == "" {
+		cfg.Bucket = "email-attachments"
+	}
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 30
+	}
+
+	var opts []option.ClientOption
+	if cfg.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(cfg.CredentialsFile))
+	}
+	if cfg.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(cfg.Endpoint))
+	}
+
+	// Close cancels ctx to stop in-flight operations
+	ctx, cancel := context.WithCancel(context.Background())
+
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	return &GCSBlobStorage{
+		client:  client,
+		bucket:  client.Bucket(cfg.Bucket),
+		enabled: true,
+		ctx:     ctx,
+		cancel:  cancel,
+		timeout: time.Duration(cfg.Timeout) * time.Second,
+	}, nil
+}
+
+// IsEnabled returns whether blob storage is enabled
+func (g *GCSBlobStorage) IsEnabled() bool {
+	return g.enabled
+}
+
+// Store stores content in GCS and returns the blob ID (SHA256 hash)
+func (g *GCSBlobStorage) Store(content string) (string, error) {
+	if !g.enabled {
+		return "", ErrStorageDisabled
+	}
+	if g.closed.Load() {
+		return "", ErrClosed
+	}
+
+	hash := sha256.Sum256([]byte(content))
+	blobID := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(g.ctx, g.timeout)
+	defer cancel()
+
+	obj := g.bucket.Object(blobKeyPrefix + blobID)
+
+	// Check if blob already exists (deduplication)
+	exists, err := objectExistsGCS(ctx, obj)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if blob exists: %w", err)
+	}
+	if exists {
+		return blobID, nil
+	}
+
+	w := obj.NewWriter(ctx)
+	w.ContentType = defaultContentType
+	if _, err := io.WriteString(w, content); err != nil {
+		_ = w.Close()
+		return "", fmt.Errorf("failed to upload blob: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("failed to upload blob: %w", err)
+	}
+
+	return blobID, nil
+}
+
+// Retrieve retrieves content from GCS by blob ID
+func (g *GCSBlobStorage) Retrieve(blobID string) (string, error) {
+	if !g.enabled {
+		return "", ErrStorageDisabled
+	}
+	if g.closed.Load() {
+		return "", ErrClosed
+	}
+	if err := validateSHA256BlobID(blobID); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(g.ctx, g.timeout)
+	defer cancel()
+
+	r, err := g.bucket.Object(blobKeyPrefix + blobID).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return "", fmt.Errorf("failed to retrieve blob: %w: %w", ErrBlobNotFound, err)
+		}
+		return "", fmt.Errorf("failed to retrieve blob: %w", err)
+	}
+	defer func() { _ = r.Close() }()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read blob content: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// Delete deletes a blob from GCS. Deleting a missing blob is not an error,
+// matching S3.
+func (g *GCSBlobStorage) Delete(blobID string) error {
+	if !g.enabled {
+		return ErrStorageDisabled
+	}
+	if g.closed.Load() {
+		return ErrClosed
+	}
+	if err := validateSHA256BlobID(blobID); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(g.ctx, g.timeout)
+	defer cancel()
+
+	err := g.bucket.Object(blobKeyPrefix + blobID).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+
+	return nil
+}
+
+// Exists checks if a blob exists in GCS
+func (g *GCSBlobStorage) Exists(blobID string) (bool, error) {
+	if !g.enabled {
+		return false, ErrStorageDisabled
+	}
+	if g.closed.Load() {
+		return false, ErrClosed
+	}
+	if err := validateSHA256BlobID(blobID); err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(g.ctx, g.timeout)
+	defer cancel()
+
+	return objectExistsGCS(ctx, g.bucket.Object(blobKeyPrefix+blobID))
+}
+
+// Close cancels in-flight operations and closes the GCS client. Later
+// operations fail with ErrClosed. Closing more than once is a no-op.
+func (g *GCSBlobStorage) Close() error {
+	if !g.enabled || g.closed.Swap(true) {
+		return nil
+	}
+
+	g.cancel()
+	if err := g.client.Close(); err != nil {
+		return fmt.Errorf("failed to close GCS client: %w", err)
+	}
+	return nil
+}
+
+// objectExistsGCS checks whether obj exists by fetching its attributes
+func objectExistsGCS(ctx context.Context, obj *storage.ObjectHandle) (bool, error) {
+	_, err := obj.Attrs(ctx)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return false, nil
+	}
+	return false, err
+}
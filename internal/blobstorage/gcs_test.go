@@ -0,0 +1,227 @@
+//go:build gcs
+
+package blobstorage
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGCSServer serves the subset of the GCS JSON and XML APIs used by
+// GCSBlobStorage from an in-memory object map
+type fakeGCSServer struct {
+	bucket  string
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeGCSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := r.URL.EscapedPath()
+	switch {
+	case r.Method == http.MethodPost && strings.HasPrefix(path, "/upload/storage/v1/b/"+f.bucket+"/o"):
+		f.upload(w, r)
+	case strings.HasPrefix(path, "/storage/v1/b/"+f.bucket+"/o/"):
+		name, _ := url.PathUnescape(strings.TrimPrefix(path, "/storage/v1/b/"+f.bucket+"/o/"))
+		data, ok := f.objects[name]
+		if !ok {
+			writeGCSError(w, http.StatusNotFound)
+			return
+		}
+		switch {
+		case r.Method == http.MethodDelete:
+			delete(f.objects, name)
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Query().Get("alt") == "media":
+			_, _ = w.Write(data)
+		default:
+			writeGCSObject(w, f.bucket, name, data)
+		}
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/"+f.bucket+"/"):
+		// XML API reads
+		name, _ := url.PathUnescape(strings.TrimPrefix(path, "/"+f.bucket+"/"))
+		data, ok := f.objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	default:
+		http.Error(w, "unexpected request "+r.Method+" "+path, http.StatusNotImplemented)
+	}
+}
+
+// upload handles a multipart (metadata + media) object upload
+func (f *fakeGCSServer) upload(w http.ResponseWriter, r *http.Request) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+
+	var meta struct {
+		Name string `json:"name"`
+	}
+	part, err := mr.NextPart()
+	if err == nil {
+		err = json.NewDecoder(part).Decode(&meta)
+	}
+	if err == nil {
+		part, err = mr.NextPart()
+	}
+	var data []byte
+	if err == nil {
+		data, err = io.ReadAll(part)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.objects[meta.Name] = data
+	writeGCSObject(w, f.bucket, meta.Name, data)
+}
+
+func writeGCSObject(w http.ResponseWriter, bucket, name string, data []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"bucket": bucket,
+		"name":   name,
+		"size":   strconv.Itoa(len(data)),
+	})
+}
+
+func writeGCSError(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"code": code, "message": http.StatusText(code)},
+	})
+}
+
+// newFakeGCSStorage returns a GCSBlobStorage talking to a fake GCS server
+// through the client's emulator support
+func newFakeGCSStorage(t *testing.T) (*GCSBlobStorage, *fakeGCSServer) {
+	t.Helper()
+
+	fake := &fakeGCSServer{bucket: "test-bucket", objects: make(map[string][]byte)}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	t.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(srv.URL, "http://"))
+
+	storage, err := NewGCSBlobStorage(GCSConfig{Enabled: true, Bucket: fake.bucket})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = storage.Close() })
+	return storage, fake
+}
+
+func TestGCSBlobStorage(t *testing.T) {
+	storage, fake := newFakeGCSStorage(t)
+	content := "gcs blob content"
+
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if blobID != testBlobID(content) {
+		t.Errorf("expected blobID=%q, got %q", testBlobID(content), blobID)
+	}
+	if got := string(fake.objects[blobKeyPrefix+blobID]); got != content {
+		t.Errorf("expected stored content=%q, got %q", content, got)
+	}
+
+	dupID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dupID != blobID || len(fake.objects) != 1 {
+		t.Errorf("expected duplicate content to be deduplicated, got %d objects", len(fake.objects))
+	}
+
+	retrieved, err := storage.Retrieve(blobID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if retrieved != content {
+		t.Errorf("expected content=%q, got %q", content, retrieved)
+	}
+
+	exists, err := storage.Exists(blobID)
+	if err != nil || !exists {
+		t.Errorf("expected blob to exist, got exists=%v err=%v", exists, err)
+	}
+
+	if err := storage.Delete(blobID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := storage.Delete(blobID); err != nil {
+		t.Errorf("expected deleting a missing blob to succeed, got %v", err)
+	}
+
+	if _, err := storage.Retrieve(blobID); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+	exists, err = storage.Exists(blobID)
+	if err != nil || exists {
+		t.Errorf("expected blob to be gone, got exists=%v err=%v", exists, err)
+	}
+}
+
+func TestGCSBlobStorageInvalidBlobID(t *testing.T) {
+	storage, fake := newFakeGCSStorage(t)
+	fake.objects[blobKeyPrefix+"../secret"] = []byte("secret")
+
+	tests := []struct {
+		name string
+		op   func(blobID string) error
+	}{
+		{"Retrieve", func(blobID string) error { _, err := storage.Retrieve(blobID); return err }},
+		{"Delete", storage.Delete},
+		{"Exists", func(blobID string) error { _, err := storage.Exists(blobID); return err }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op("../secret"); !errors.Is(err, ErrInvalidBlobID) {
+				t.Errorf("expected ErrInvalidBlobID, got %v", err)
+			}
+		})
+	}
+
+	if _, ok := fake.objects[blobKeyPrefix+"../secret"]; !ok {
+		t.Error("expected invalid blob ID not to reach the backend")
+	}
+}
+
+func TestGCSBlobStorageDisabledAndClosed(t *testing.T) {
+	disabled, err := NewGCSBlobStorage(GCSConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := disabled.Store("content"); !errors.Is(err, ErrStorageDisabled) {
+		t.Errorf("expected ErrStorageDisabled, got %v", err)
+	}
+
+	storage, _ := newFakeGCSStorage(t)
+	if err := storage.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := storage.Retrieve(testBlobID("content")); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
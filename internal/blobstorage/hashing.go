@@ -0,0 +1,79 @@
+package blobstorage
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+)
+
+const (
+	// HashSHA256 derives blob IDs from the SHA-256 of their content (default)
+	HashSHA256 = "sha256"
+	// HashSHA512 derives blob IDs from the SHA-512 of their content
+	HashSHA512 = "sha512"
+)
+
+// ComputeBlobID returns the ID Store gives content under the default SHA-256
+// hash algorithm, without uploading it, e.g. to check Exists first. Use the
+// S3BlobStorage method of the same name to honor a configured HashAlgorithm.
+func ComputeBlobID(content string) string {
+	return ComputeBlobIDBytes([]byte(content))
+}
+
+// ComputeBlobIDBytes is ComputeBlobID for a byte slice
+func ComputeBlobIDBytes(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// ComputeBlobID returns the ID Store gives content under the configured hash
+// algorithm, without uploading it
+func (s *S3BlobStorage) ComputeBlobID(content string) string {
+	return s.computeBlobID([]byte(content))
+}
+
+// newHash returns a hash for computing blob IDs with the configured algorithm
+func (s *S3BlobStorage) newHash() hash.Hash {
+	if s.hashAlgorithm == HashSHA512 {
+		return sha512.New()
+	}
+	return sha256.New()
+}
+
+// computeBlobID returns the blob ID content is stored under
+func (s *S3BlobStorage) computeBlobID(content []byte) string {
+	h := s.newHash()
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// validateBlobID checks that blobID looks like an ID returned by Store
+func (s *S3BlobStorage) validateBlobID(blobID string) error {
+	if size := s.newHash().Size(); !isHexDigest(blobID, size) {
+		return fmt.Errorf("%w %q: expected %d lowercase hex characters", ErrInvalidBlobID, blobID, size*2)
+	}
+	return nil
+}
+
+// validateSHA256BlobID checks that blobID is a lowercase SHA256 hex digest
+func validateSHA256BlobID(blobID string) error {
+	if !isHexDigest(blobID, sha256.Size) {
+		return fmt.Errorf("%w %q: expected %d lowercase hex characters", ErrInvalidBlobID, blobID, sha256.Size*2)
+	}
+	return nil
+}
+
+// isHexDigest reports whether s is a lowercase hex-encoded digest of size bytes
+func isHexDigest(s string, size int) bool {
+	if len(s) != size*2 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
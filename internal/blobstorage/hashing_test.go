@@ -0,0 +1,107 @@
+package blobstorage
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewS3BlobStorageInvalidHashAlgorithm(t *testing.T) {
+	_, err := NewS3BlobStorage(Config{
+		Enabled:       true,
+		AccessKey:     "access",
+		SecretKey:     "secret",
+		HashAlgorithm: "md5",
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid hash algorithm") {
+		t.Errorf("expected invalid hash algorithm error, got %v", err)
+	}
+}
+
+func TestSHA512BlobIDs(t *testing.T) {
+	content := "attachment addressed by SHA-512"
+	sum := sha512.Sum512([]byte(content))
+	expectedID := hex.EncodeToString(sum[:])
+
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.hashAlgorithm = HashSHA512
+	storage.verifyOnRetrieve = true
+
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if blobID != expectedID {
+		t.Errorf("expected blobID=%q, got %q", expectedID, blobID)
+	}
+	if _, ok := objects["blobs/"+expectedID]; !ok {
+		t.Error("expected blob to be stored under its SHA-512 ID")
+	}
+
+	got, err := storage.Retrieve(blobID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != content {
+		t.Errorf("expected content=%q, got %q", content, got)
+	}
+
+	// A SHA-256 ID has the wrong length for SHA-512 storage
+	if _, err := storage.Exists(testBlobID(content)); !errors.Is(err, ErrInvalidBlobID) {
+		t.Errorf("expected ErrInvalidBlobID for a SHA-256 ID, got %v", err)
+	}
+}
+
+func TestSHA512StoreReaderWithSizeAndHash(t *testing.T) {
+	content := "streamed with a precomputed SHA-512"
+	sum := sha512.Sum512([]byte(content))
+
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.hashAlgorithm = HashSHA512
+
+	blobID, err := storage.StoreReaderWithSizeAndHash(strings.NewReader(content), int64(len(content)), hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if blobID != hex.EncodeToString(sum[:]) {
+		t.Errorf("expected blobID=%q, got %q", hex.EncodeToString(sum[:]), blobID)
+	}
+
+	if _, err := storage.StoreReaderWithSizeAndHash(strings.NewReader(content), int64(len(content)), testBlobID(content)); !errors.Is(err, ErrInvalidBlobID) {
+		t.Errorf("expected ErrInvalidBlobID for a SHA-256 hash, got %v", err)
+	}
+}
+
+func TestComputeBlobID(t *testing.T) {
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	content := "content to check before uploading"
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("failed to store blob: %v", err)
+	}
+
+	if got := ComputeBlobID(content); got != blobID {
+		t.Errorf("expected ComputeBlobID=%q, got %q", blobID, got)
+	}
+	if got := ComputeBlobIDBytes([]byte(content)); got != blobID {
+		t.Errorf("expected ComputeBlobIDBytes=%q, got %q", blobID, got)
+	}
+	if got := storage.ComputeBlobID(content); got != blobID {
+		t.Errorf("expected the ComputeBlobID method to return %q, got %q", blobID, got)
+	}
+
+	storage.hashAlgorithm = HashSHA512
+	sha512ID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("failed to store blob: %v", err)
+	}
+	if got := storage.ComputeBlobID(content); got != sha512ID {
+		t.Errorf("expected the ComputeBlobID method to honor SHA-512, got %q", got)
+	}
+}
@@ -0,0 +1,170 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestRetrieveVerified(t *testing.T) {
+	content := "content that a flaky gateway truncates"
+	blobID := testBlobID(content)
+
+	tests := []struct {
+		name             string
+		verifyOnRetrieve bool
+		integrityRetries int
+		served           []string
+		expectError      bool
+		expectedGets     int
+	}{
+		{name: "intact content", integrityRetries: 1, served: []string{content}, expectedGets: 1},
+		{name: "recovers from a truncated read", integrityRetries: 1, served: []string{content[:10], content}, expectedGets: 2},
+		{name: "recovers with VerifyOnRetrieve", verifyOnRetrieve: true, integrityRetries: 1, served: []string{content[:10], content}, expectedGets: 2},
+		{name: "every attempt truncated", integrityRetries: 2, served: []string{content[:10], content[:10], content[:10]}, expectError: true, expectedGets: 3},
+		{name: "retries disabled", integrityRetries: 0, served: []string{content[:10], content}, expectError: true, expectedGets: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gets := 0
+			mock := &mockS3Client{
+				getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					served := tt.served[min(gets, len(tt.served)-1)]
+					gets++
+					return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(served))}, nil
+				},
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			storage.verifyOnRetrieve = tt.verifyOnRetrieve
+			storage.integrityRetries = tt.integrityRetries
+
+			retrieved, err := storage.RetrieveVerified(blobID)
+
+			if gets != tt.expectedGets {
+				t.Errorf("expected %d GetObject calls, got %d", tt.expectedGets, gets)
+			}
+			if tt.expectError {
+				if !errors.Is(err, ErrIntegrityMismatch) {
+					t.Errorf("expected ErrIntegrityMismatch, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if retrieved != content {
+				t.Errorf("expected content=%q, got %q", content, retrieved)
+			}
+		})
+	}
+}
+
+func TestRetrieveVerifiedDoesNotRetryOtherErrors(t *testing.T) {
+	gets := 0
+	mock := &mockS3Client{
+		getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			gets++
+			return nil, errors.New("connection reset")
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.integrityRetries = 3
+
+	if _, err := storage.RetrieveVerified(testBlobID("content")); err == nil {
+		t.Fatal("expected an error")
+	}
+	if gets != 1 {
+		t.Errorf("expected 1 GetObject call, got %d", gets)
+	}
+}
+
+// withList adds a single-page ListObjectsV2 over objects to a bucket mock
+func withList(mock *mockS3Client, objects map[string]*storedObject) {
+	mock.listObjectsFunc = func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+		out := &s3.ListObjectsV2Output{}
+		for _, key := range objectKeys(objects) {
+			if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+				out.Contents = append(out.Contents, types.Object{Key: aws.String(key)})
+			}
+		}
+		return out, nil
+	}
+}
+
+func TestVerify(t *testing.T) {
+	mock, objects := newBucketMock()
+	withList(mock, objects)
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	var intact []string
+	for _, content := range []string{"first", "second", "third"} {
+		blobID, err := storage.Store(content)
+		if err != nil {
+			t.Fatalf("unexpected store error: %v", err)
+		}
+		intact = append(intact, blobID)
+	}
+	corrupted := testBlobID("original")
+	objects["blobs/"+corrupted] = &storedObject{body: []byte("overwritten")}
+	objects["blobs/not-a-blob"] = &storedObject{body: []byte("stray")}
+
+	type result struct {
+		ok  bool
+		err error
+	}
+	results := make(map[string]result)
+	err := storage.Verify(context.Background(), func(blobID string, ok bool, err error) {
+		results[blobID] = result{ok, err}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(results) != 5 {
+		t.Errorf("expected 5 blobs checked, got %d", len(results))
+	}
+	for _, blobID := range intact {
+		if r := results[blobID]; !r.ok || r.err != nil {
+			t.Errorf("expected %s to verify, got %+v", blobID, r)
+		}
+	}
+	if r := results[corrupted]; r.ok || r.err != nil {
+		t.Errorf("expected a mismatch for the corrupted blob, got %+v", r)
+	}
+	if r := results["not-a-blob"]; r.ok || !errors.Is(r.err, ErrInvalidBlobID) {
+		t.Errorf("expected ErrInvalidBlobID for the stray key, got %+v", r)
+	}
+}
+
+func TestVerifyCancel(t *testing.T) {
+	mock, objects := newBucketMock()
+	withList(mock, objects)
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.opSlots = make(chan struct{}, 1)
+
+	for _, blobID := range testBlobIDs(20) {
+		objects["blobs/"+blobID] = &storedObject{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	err := storage.Verify(ctx, func(blobID string, ok bool, err error) {
+		calls++
+		cancel()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if calls >= 20 {
+		t.Errorf("expected the scan to stop early, got %d calls", calls)
+	}
+}
@@ -0,0 +1,42 @@
+package blobstorage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// GetLastModified returns when a blob was last written, e.g. so retention
+// jobs can find blobs older than a cutoff. Since blobs are content addressed
+// and deduplicated this is when the content was first stored, not when it
+// was last passed to Store.
+func (s *S3BlobStorage) GetLastModified(blobID string) (_ time.Time, err error) {
+	defer s.wrapError("GetLastModified", blobID, &err)
+	if !s.enabled {
+		return time.Time{}, ErrStorageDisabled
+	}
+
+	if err := s.validateBlobID(blobID); err != nil {
+		return time.Time{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "GetLastModified"); err != nil {
+		return time.Time{}, err
+	}
+	defer s.releaseOp()
+
+	result, err := s.client.HeadObject(ctx, s.headObjectInput(s.blobKey(blobID)))
+	if err != nil {
+		if isNotFound(err) {
+			return time.Time{}, fmt.Errorf("failed to get blob last modified time: %w: %w", ErrBlobNotFound, err)
+		}
+		return time.Time{}, fmt.Errorf("failed to get blob last modified time: %w", err)
+	}
+
+	return aws.ToTime(result.LastModified), nil
+}
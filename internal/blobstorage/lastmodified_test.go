@@ -0,0 +1,76 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestGetLastModified(t *testing.T) {
+	stored := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		setup         func(*mockS3Client, map[string]*storedObject)
+		expected      time.Time
+		expectedErr   error
+		errorContains string
+	}{
+		{
+			name: "existing blob",
+			setup: func(m *mockS3Client, objects map[string]*storedObject) {
+				objects["blobs/"+testBlobID("attachment")] = &storedObject{body: []byte("attachment"), lastModified: stored}
+			},
+			expected: stored,
+		},
+		{
+			name:        "missing blob",
+			setup:       func(m *mockS3Client, objects map[string]*storedObject) {},
+			expectedErr: ErrBlobNotFound,
+		},
+		{
+			name: "head object error",
+			setup: func(m *mockS3Client, objects map[string]*storedObject) {
+				m.headObjectFunc = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+					return nil, errors.New("connection reset")
+				}
+			},
+			errorContains: "connection reset",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, objects := newBucketMock()
+			tt.setup(mock, objects)
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+			got, err := storage.GetLastModified(testBlobID("attachment"))
+
+			switch {
+			case tt.expectedErr != nil:
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("expected %v, got %v", tt.expectedErr, err)
+				}
+			case tt.errorContains != "":
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("expected error containing %q, got %v", tt.errorContains, err)
+				}
+				if errors.Is(err, ErrBlobNotFound) {
+					t.Error("expected other errors not to be reported as ErrBlobNotFound")
+				}
+			default:
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !got.Equal(tt.expected) {
+					t.Errorf("expected %v, got %v", tt.expected, got)
+				}
+			}
+		})
+	}
+}
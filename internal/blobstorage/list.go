@@ -0,0 +1,153 @@
+package blobstorage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// hexDigits are the possible first characters of a blob ID. Listings are
+// split into one prefix per digit so they can be paged concurrently.
+const hexDigits = "0123456789abcdef"
+
+// List returns the IDs of all blobs stored in the bucket in key order,
+// listing key ranges concurrently. For very large buckets prefer ListFunc,
+// which does not hold every ID in memory.
+func (s *S3BlobStorage) List(ctx context.Context) (_ []string, err error) {
+	defer s.wrapError("List", "", &err)
+	if !s.enabled {
+		return nil, ErrStorageDisabled
+	}
+
+	shards := make([][]string, len(hexDigits))
+	err = s.listParallel(ctx, func(shard int, obj types.Object) error {
+		shards[shard] = append(shards[shard], s.blobIDFromKey(aws.ToString(obj.Key)))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var blobIDs []string
+	for _, ids := range shards {
+		blobIDs = append(blobIDs, ids...)
+	}
+	if s.availabilityZone != "" {
+		// Directory bucket listings are not in key order
+		slices.Sort(blobIDs)
+	}
+	return blobIDs, nil
+}
+
+// ListFunc calls fn for each blob ID in the bucket as pages are fetched.
+// Listing stops at the first error returned by fn, which is returned wrapped
+// in a BlobError.
+func (s *S3BlobStorage) ListFunc(ctx context.Context, fn func(blobID string) error) (err error) {
+	defer s.wrapError("ListFunc", "", &err)
+	if !s.enabled {
+		return ErrStorageDisabled
+	}
+
+	return s.listObjects(ctx, s.keyPrefix+blobKeyPrefix, func(obj types.Object) error {
+		return fn(s.blobIDFromKey(aws.ToString(obj.Key)))
+	})
+}
+
+// Stats returns the number of stored blobs and their total size in bytes as
+// stored (i.e. after any compression or encryption). It lists the whole
+// bucket, an O(n) scan meant for periodic reporting rather than per-request
+// use.
+func (s *S3BlobStorage) Stats(ctx context.Context) (count int64, totalBytes int64, err error) {
+	defer s.wrapError("Stats", "", &err)
+	if !s.enabled {
+		return 0, 0, ErrStorageDisabled
+	}
+
+	counts := make([]int64, len(hexDigits))
+	sizes := make([]int64, len(hexDigits))
+	err = s.listParallel(ctx, func(shard int, obj types.Object) error {
+		counts[shard]++
+		sizes[shard] += aws.ToInt64(obj.Size)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for i := range hexDigits {
+		count += counts[i]
+		totalBytes += sizes[i]
+	}
+	return count, totalBytes, nil
+}
+
+// listParallel lists each hexDigits prefix on the worker pool, calling fn
+// with the prefix's index for each object. Calls for the same index are
+// sequential, but calls for different indexes run concurrently. Directory
+// buckets only list prefixes ending in "/", so there everything is listed
+// sequentially as index 0.
+func (s *S3BlobStorage) listParallel(ctx context.Context, fn func(shard int, obj types.Object) error) error {
+	if s.availabilityZone != "" {
+		return s.listObjects(ctx, s.keyPrefix+blobKeyPrefix, func(obj types.Object) error {
+			return fn(0, obj)
+		})
+	}
+
+	return s.runParallel(ctx, len(hexDigits), func(shard int) error {
+		prefix := s.keyPrefix + blobKeyPrefix + hexDigits[shard:shard+1]
+		return s.listObjects(ctx, prefix, func(obj types.Object) error {
+			return fn(shard, obj)
+		})
+	})
+}
+
+// listObjects pages through every object under prefix, calling fn for each
+// one
+func (s *S3BlobStorage) listObjects(ctx context.Context, prefix string, fn func(obj types.Object) error) error {
+	var continuationToken *string
+
+	for {
+		page, err := s.listPage(ctx, prefix, continuationToken)
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range page.Contents {
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+
+		if !aws.ToBool(page.IsTruncated) || page.NextContinuationToken == nil {
+			return nil
+		}
+		continuationToken = page.NextContinuationToken
+	}
+}
+
+// listPage fetches a single page of keys under prefix starting at
+// continuationToken
+func (s *S3BlobStorage) listPage(ctx context.Context, prefix string, continuationToken *string) (*s3.ListObjectsV2Output, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "List"); err != nil {
+		return nil, err
+	}
+	defer s.releaseOp()
+
+	page, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:            aws.String(s.bucket),
+		Prefix:            aws.String(prefix),
+		ContinuationToken: continuationToken,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	return page, nil
+}
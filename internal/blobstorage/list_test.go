@@ -0,0 +1,245 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// pagedListMock returns a ListObjectsV2 func serving the keys from the given
+// pages that match the requested prefix, using the page index as the
+// continuation token
+func pagedListMock(t *testing.T, pages [][]string) func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	return func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+		prefix := aws.ToString(params.Prefix)
+		if !strings.HasPrefix(prefix, blobKeyPrefix) {
+			t.Errorf("expected prefix under %q, got %q", blobKeyPrefix, prefix)
+		}
+
+		index := 0
+		if params.ContinuationToken != nil {
+			var err error
+			if index, err = strconv.Atoi(*params.ContinuationToken); err != nil {
+				t.Fatalf("unexpected continuation token %q", *params.ContinuationToken)
+			}
+		}
+
+		out := &s3.ListObjectsV2Output{}
+		for _, key := range pages[index] {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			out.Contents = append(out.Contents, types.Object{Key: aws.String(key), Size: aws.Int64(int64(len(key)))})
+		}
+		if index+1 < len(pages) {
+			out.IsTruncated = aws.Bool(true)
+			out.NextContinuationToken = aws.String(strconv.Itoa(index + 1))
+		}
+		return out, nil
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		name          string
+		enabled       bool
+		setupMock     func(*mockS3Client)
+		expectError   bool
+		errorContains string
+		expectedIDs   []string
+	}{
+		{
+			name:          "disabled storage",
+			enabled:       false,
+			setupMock:     func(m *mockS3Client) {},
+			expectError:   true,
+			errorContains: "blob storage is not enabled",
+		},
+		{
+			name:        "empty bucket",
+			enabled:     true,
+			setupMock:   func(m *mockS3Client) {},
+			expectError: false,
+			expectedIDs: nil,
+		},
+		{
+			name:    "single page",
+			enabled: true,
+			setupMock: func(m *mockS3Client) {
+				m.listObjectsFunc = pagedListMock(t, [][]string{{"blobs/aaa", "blobs/bbb"}})
+			},
+			expectError: false,
+			expectedIDs: []string{"aaa", "bbb"},
+		},
+		{
+			name:    "multiple pages",
+			enabled: true,
+			setupMock: func(m *mockS3Client) {
+				m.listObjectsFunc = pagedListMock(t, [][]string{
+					{"blobs/aaa", "blobs/bbb"},
+					{"blobs/ccc"},
+					{"blobs/ddd", "blobs/eee"},
+				})
+			},
+			expectError: false,
+			expectedIDs: []string{"aaa", "bbb", "ccc", "ddd", "eee"},
+		},
+		{
+			name:    "list error",
+			enabled: true,
+			setupMock: func(m *mockS3Client) {
+				m.listObjectsFunc = func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+					return nil, errors.New("list failed")
+				}
+			},
+			expectError:   true,
+			errorContains: "failed to list blobs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockS3Client{}
+			tt.setupMock(mock)
+			storage := newMockS3BlobStorage(mock, "test-bucket", tt.enabled)
+
+			blobIDs, err := storage.List(context.Background())
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				} else if tt.errorContains != "" && !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("expected error containing %q, got %q", tt.errorContains, err.Error())
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(blobIDs, tt.expectedIDs) {
+				t.Errorf("expected blobIDs=%v, got %v", tt.expectedIDs, blobIDs)
+			}
+		})
+	}
+}
+
+func TestListFunc(t *testing.T) {
+	pages := [][]string{
+		{"blobs/aaa", "blobs/bbb"},
+		{"blobs/ccc"},
+		{"blobs/ddd", "blobs/eee"},
+	}
+
+	t.Run("visits every blob across pages", func(t *testing.T) {
+		mock := &mockS3Client{listObjectsFunc: pagedListMock(t, pages)}
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+		var visited []string
+		err := storage.ListFunc(context.Background(), func(blobID string) error {
+			visited = append(visited, blobID)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := []string{"aaa", "bbb", "ccc", "ddd", "eee"}
+		if !reflect.DeepEqual(visited, expected) {
+			t.Errorf("expected visited=%v, got %v", expected, visited)
+		}
+	})
+
+	t.Run("stops early when callback fails", func(t *testing.T) {
+		calls := 0
+		list := pagedListMock(t, pages)
+		mock := &mockS3Client{
+			listObjectsFunc: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				calls++
+				return list(ctx, params, optFns...)
+			},
+		}
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+		stop := errors.New("stop")
+		var visited []string
+		err := storage.ListFunc(context.Background(), func(blobID string) error {
+			visited = append(visited, blobID)
+			if blobID == "bbb" {
+				return stop
+			}
+			return nil
+		})
+		if !errors.Is(err, stop) {
+			t.Fatalf("expected callback error, got %v", err)
+		}
+
+		if !reflect.DeepEqual(visited, []string{"aaa", "bbb"}) {
+			t.Errorf("expected to stop after bbb, visited %v", visited)
+		}
+		if calls != 1 {
+			t.Errorf("expected only the first page to be fetched, got %d calls", calls)
+		}
+	})
+
+	t.Run("disabled storage", func(t *testing.T) {
+		storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", false)
+
+		err := storage.ListFunc(context.Background(), func(string) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "blob storage is not enabled") {
+			t.Errorf("expected disabled error, got %v", err)
+		}
+	})
+}
+
+func TestStats(t *testing.T) {
+	t.Run("sums sizes across pages", func(t *testing.T) {
+		// pagedListMock reports each object's size as its key length
+		mock := &mockS3Client{listObjectsFunc: pagedListMock(t, [][]string{
+			{"blobs/aaa", "blobs/bbbb"},
+			{"blobs/ccccc"},
+		})}
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+		count, totalBytes, err := storage.Stats(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if count != 3 {
+			t.Errorf("expected count=3, got %d", count)
+		}
+		if expected := int64(len("blobs/aaa") + len("blobs/bbbb") + len("blobs/ccccc")); totalBytes != expected {
+			t.Errorf("expected totalBytes=%d, got %d", expected, totalBytes)
+		}
+	})
+
+	t.Run("list error", func(t *testing.T) {
+		mock := &mockS3Client{
+			listObjectsFunc: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				return nil, errors.New("list failed")
+			},
+		}
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+		if _, _, err := storage.Stats(context.Background()); err == nil {
+			t.Error("expected error but got none")
+		}
+	})
+
+	t.Run("disabled storage", func(t *testing.T) {
+		storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", false)
+
+		if _, _, err := storage.Stats(context.Background()); !errors.Is(err, ErrStorageDisabled) {
+			t.Errorf("expected ErrStorageDisabled, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package blobstorage
+
+import (
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// Logger receives diagnostics that would otherwise be swallowed, such as
+// bucket creation outcomes, request retries and body close errors
+type Logger interface {
+	Debugf(format string, args ...any)
+	Warnf(format string, args ...any)
+	Errorf(format string, args ...any)
+}
+
+// NoopLogger is the default Logger, discarding all messages
+type NoopLogger struct{}
+
+// Debugf does nothing
+func (NoopLogger) Debugf(string, ...any) {}
+
+// Warnf does nothing
+func (NoopLogger) Warnf(string, ...any) {}
+
+// Errorf does nothing
+func (NoopLogger) Errorf(string, ...any) {}
+
+// loggingRetryer wraps the SDK retryer to log each retry attempt
+type loggingRetryer struct {
+	aws.RetryerV2
+	logger Logger
+}
+
+// RetryDelay is called by the SDK once it has decided to retry a request
+func (r *loggingRetryer) RetryDelay(attempt int, err error) (time.Duration, error) {
+	delay, delayErr := r.RetryerV2.RetryDelay(attempt, err)
+	if delayErr == nil {
+		r.logger.Warnf("blobstorage: retrying S3 request (attempt %d) in %s: %v", attempt, delay, err)
+	}
+	return delay, delayErr
+}
+
+// closeBody closes a response body, logging rather than returning any error
+// since the body has already been read
+func (s *S3BlobStorage) closeBody(body io.Closer, key string) {
+	if err := body.Close(); err != nil {
+		s.logger.Warnf("blobstorage: failed to close response body for %s: %v", key, err)
+	}
+}
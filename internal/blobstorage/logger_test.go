@@ -0,0 +1,115 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// recordingLogger records formatted messages by level
+type recordingLogger struct {
+	debug, warn, error []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...any) {
+	l.debug = append(l.debug, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Warnf(format string, args ...any) {
+	l.warn = append(l.warn, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Errorf(format string, args ...any) {
+	l.error = append(l.error, fmt.Sprintf(format, args...))
+}
+
+func TestEnsureBucketLogging(t *testing.T) {
+	tests := []struct {
+		name        string
+		createErr   error
+		expectDebug string
+		expectWarn  string
+	}{
+		{name: "created", expectDebug: "created bucket"},
+		{name: "already owned", createErr: &smithy.GenericAPIError{Code: "BucketAlreadyOwnedByYou"}, expectDebug: "already exists"},
+		{name: "access denied", createErr: &smithy.GenericAPIError{Code: "AccessDenied"}, expectWarn: "failed to create bucket"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockS3Client{
+				createBucketFunc: func(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+					if tt.createErr != nil {
+						return nil, tt.createErr
+					}
+					return &s3.CreateBucketOutput{}, nil
+				},
+			}
+			logger := &recordingLogger{}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			storage.logger = logger
+
+			if err := storage.ensureBucket(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.expectDebug != "" && (len(logger.debug) != 1 || !strings.Contains(logger.debug[0], tt.expectDebug)) {
+				t.Errorf("expected debug message containing %q, got %v", tt.expectDebug, logger.debug)
+			}
+			if tt.expectWarn != "" && (len(logger.warn) != 1 || !strings.Contains(logger.warn[0], tt.expectWarn)) {
+				t.Errorf("expected warning containing %q, got %v", tt.expectWarn, logger.warn)
+			}
+		})
+	}
+}
+
+// failingCloseBody is a response body whose Close fails
+type failingCloseBody struct {
+	io.Reader
+}
+
+func (failingCloseBody) Close() error {
+	return errors.New("connection reset")
+}
+
+func TestRetrieveLogsBodyCloseError(t *testing.T) {
+	mock := &mockS3Client{
+		getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{Body: failingCloseBody{strings.NewReader("content")}}, nil
+		},
+	}
+	logger := &recordingLogger{}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.logger = logger
+
+	content, err := storage.Retrieve(testBlobID("content"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content != "content" {
+		t.Errorf("expected content=%q, got %q", "content", content)
+	}
+	if len(logger.warn) != 1 || !strings.Contains(logger.warn[0], "connection reset") {
+		t.Errorf("expected close error to be logged, got %v", logger.warn)
+	}
+}
+
+func TestLoggingRetryerLogsAttempts(t *testing.T) {
+	logger := &recordingLogger{}
+	retryer := &loggingRetryer{RetryerV2: retry.NewStandard(), logger: logger}
+
+	if _, err := retryer.RetryDelay(1, errors.New("SlowDown")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(logger.warn) != 1 || !strings.Contains(logger.warn[0], "attempt 1") || !strings.Contains(logger.warn[0], "SlowDown") {
+		t.Errorf("expected retry attempt to be logged, got %v", logger.warn)
+	}
+}
@@ -0,0 +1,70 @@
+package blobstorage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// MemoryBlobStorage is an in-memory BlobStorage for tests. It uses the same
+// SHA256 blob IDs and deduplication semantics as S3BlobStorage.
+type MemoryBlobStorage struct {
+	mu    sync.RWMutex
+	blobs map[string][]byte
+}
+
+var _ BlobStorage = (*MemoryBlobStorage)(nil)
+
+// NewMemoryBlobStorage creates an empty in-memory blob storage
+func NewMemoryBlobStorage() *MemoryBlobStorage {
+	return &MemoryBlobStorage{blobs: make(map[string][]byte)}
+}
+
+// IsEnabled always returns true
+func (m *MemoryBlobStorage) IsEnabled() bool {
+	return true
+}
+
+// Store stores content and returns the blob ID (SHA256 hash)
+func (m *MemoryBlobStorage) Store(content string) (string, error) {
+	hash := sha256.Sum256([]byte(content))
+	blobID := hex.EncodeToString(hash[:])
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.blobs[blobID]; !exists {
+		m.blobs[blobID] = []byte(content)
+	}
+	return blobID, nil
+}
+
+// Retrieve returns the content stored under blobID, or ErrBlobNotFound
+func (m *MemoryBlobStorage) Retrieve(blobID string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data, exists := m.blobs[blobID]
+	if !exists {
+		return "", ErrBlobNotFound
+	}
+	return string(data), nil
+}
+
+// Delete removes a blob. Like S3, deleting a missing blob is not an error.
+func (m *MemoryBlobStorage) Delete(blobID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.blobs, blobID)
+	return nil
+}
+
+// Exists checks if a blob is stored
+func (m *MemoryBlobStorage) Exists(blobID string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, exists := m.blobs[blobID]
+	return exists, nil
+}
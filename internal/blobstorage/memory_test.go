@@ -0,0 +1,76 @@
+package blobstorage
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestMemoryBlobStorage(t *testing.T) {
+	storage := NewMemoryBlobStorage()
+	content := "in-memory blob content"
+
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if blobID != testBlobID(content) {
+		t.Errorf("expected blobID=%q, got %q", testBlobID(content), blobID)
+	}
+
+	dupID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dupID != blobID || len(storage.blobs) != 1 {
+		t.Errorf("expected duplicate content to be deduplicated, got %d blobs", len(storage.blobs))
+	}
+
+	retrieved, err := storage.Retrieve(blobID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if retrieved != content {
+		t.Errorf("expected content=%q, got %q", content, retrieved)
+	}
+
+	exists, err := storage.Exists(blobID)
+	if err != nil || !exists {
+		t.Errorf("expected blob to exist, got exists=%v err=%v", exists, err)
+	}
+
+	if err := storage.Delete(blobID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := storage.Delete(blobID); err != nil {
+		t.Errorf("expected deleting a missing blob to succeed, got %v", err)
+	}
+
+	if _, err := storage.Retrieve(blobID); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+	exists, err = storage.Exists(blobID)
+	if err != nil || exists {
+		t.Errorf("expected blob to be gone, got exists=%v err=%v", exists, err)
+	}
+}
+
+func TestMemoryBlobStorageConcurrentStore(t *testing.T) {
+	storage := NewMemoryBlobStorage()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := storage.Store("shared content"); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(storage.blobs) != 1 {
+		t.Errorf("expected 1 blob, got %d", len(storage.blobs))
+	}
+}
@@ -0,0 +1,135 @@
+package blobstorage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// reservedMetadataKeys are object metadata keys used internally to record how
+// a blob is stored. They take precedence over caller metadata and are not
+// returned by GetMetadata.
+var reservedMetadataKeys = map[string]bool{
+	metaEncoding:   true,
+	metaEncryption: true,
+	metaExpiresAt:  true,
+}
+
+// StoreWithMetadata stores content like Store and attaches metadata (e.g. the
+// original filename) to the object. Metadata does not affect the blob ID, so
+// if identical content is already stored the existing blob and its metadata
+// are kept as is.
+func (s *S3BlobStorage) StoreWithMetadata(content string, metadata map[string]string) (_ string, err error) {
+	var result StoreResult
+	defer s.wrapStoreError("StoreWithMetadata", &result, &err)
+	result, err = s.storeResult(s.ctx, []byte(content), putOptions{metadata: metadata})
+	return result.BlobID, err
+}
+
+// GetMetadata returns the metadata attached to a blob. S3 returns metadata
+// keys in lower case.
+func (s *S3BlobStorage) GetMetadata(blobID string) (_ map[string]string, err error) {
+	defer s.wrapError("GetMetadata", blobID, &err)
+	if !s.enabled {
+		return nil, ErrStorageDisabled
+	}
+
+	if err := s.validateBlobID(blobID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "GetMetadata"); err != nil {
+		return nil, err
+	}
+	defer s.releaseOp()
+
+	result, err := s.client.HeadObject(ctx, s.headObjectInput(s.blobKey(blobID)))
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("failed to get blob metadata: %w: %w", ErrBlobNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to get blob metadata: %w", err)
+	}
+
+	return visibleMetadata(result.Metadata), nil
+}
+
+// RetrieveWithMetadata returns a blob's content together with its metadata
+// and content type, e.g. to render an attachment with its filename, from a
+// single GetObject rather than a Retrieve and a GetMetadata. Like
+// GetMetadata it leaves out internal metadata keys, and like Retrieve it
+// retries a download that fails VerifyOnRetrieve.
+func (s *S3BlobStorage) RetrieveWithMetadata(blobID string) (content string, metadata map[string]string, contentType string, err error) {
+	defer s.wrapError("RetrieveWithMetadata", blobID, &err)
+	if !s.enabled {
+		return "", nil, "", ErrStorageDisabled
+	}
+
+	if err := s.validateBlobID(blobID); err != nil {
+		return "", nil, "", err
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.opTimeout(s.downloadTimeout))
+	defer cancel()
+
+	if err := s.acquireReadOp(ctx, "RetrieveWithMetadata"); err != nil {
+		return "", nil, "", err
+	}
+	defer s.releaseOp()
+
+	var result *s3.GetObjectOutput
+	download := func() ([]byte, error) {
+		out, _, err := s.getObject(ctx, blobID)
+		if err != nil {
+			return nil, err
+		}
+		defer s.closeBody(out.Body, s.blobKey(blobID))
+
+		result = out
+		return s.readBlob(ctx, blobID, out)
+	}
+
+	var data []byte
+	if s.verifyOnRetrieve {
+		data, err = s.downloadVerified(blobID, download)
+	} else {
+		data, err = download()
+	}
+	if err != nil {
+		return "", nil, "", err
+	}
+	return string(data), visibleMetadata(result.Metadata), aws.ToString(result.ContentType), nil
+}
+
+// visibleMetadata returns object metadata without the reserved keys
+func visibleMetadata(objectMetadata map[string]string) map[string]string {
+	metadata := make(map[string]string, len(objectMetadata))
+	for k, v := range objectMetadata {
+		if !reservedMetadataKeys[k] {
+			metadata[k] = v
+		}
+	}
+	return metadata
+}
+
+// mergeMetadata combines caller metadata with internal metadata, letting the
+// internal keys win. It returns nil when there is nothing to send.
+func mergeMetadata(metadata, internal map[string]string) map[string]string {
+	if len(metadata) == 0 && len(internal) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]string, len(metadata)+len(internal))
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	for k, v := range internal {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,283 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+func TestStoreWithMetadata(t *testing.T) {
+	content := "attachment bytes"
+	metadata := map[string]string{"filename": "report.pdf", "mime-type": "application/pdf"}
+
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	blobID, err := storage.StoreWithMetadata(content, metadata)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if plainID := testBlobID(content); blobID != plainID {
+		t.Errorf("expected metadata not to affect blob ID: got %q, want %q", blobID, plainID)
+	}
+
+	if got := objects["blobs/"+blobID].metadata; !reflect.DeepEqual(got, metadata) {
+		t.Errorf("expected stored metadata=%v, got %v", metadata, got)
+	}
+
+	// Same content with a different filename dedupes to the same blob
+	otherID, err := storage.StoreWithMetadata(content, map[string]string{"filename": "copy.pdf"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if otherID != blobID {
+		t.Errorf("expected deduplicated blobID=%q, got %q", blobID, otherID)
+	}
+	if len(objects) != 1 {
+		t.Errorf("expected 1 stored object, got %d", len(objects))
+	}
+}
+
+func TestStoreWithMetadataKeepsEncodingMarker(t *testing.T) {
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.compression = CompressionGzip
+
+	content := strings.Repeat("compressible ", 50)
+	blobID, err := storage.StoreWithMetadata(content, map[string]string{metaEncoding: "none", "filename": "a.txt"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := objects["blobs/"+blobID].metadata[metaEncoding]; got != CompressionGzip {
+		t.Errorf("expected internal encoding marker %q to win, got %q", CompressionGzip, got)
+	}
+
+	retrieved, err := storage.Retrieve(blobID)
+	if err != nil {
+		t.Fatalf("unexpected retrieve error: %v", err)
+	}
+	if retrieved != content {
+		t.Error("retrieved content does not match original")
+	}
+}
+
+func TestDefaultMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		store    func(s *S3BlobStorage, content string) (string, error)
+		expected map[string]string
+	}{
+		{
+			name:     "store",
+			store:    func(s *S3BlobStorage, content string) (string, error) { return s.Store(content) },
+			expected: map[string]string{"service": "raven", "app-version": "1.4.0"},
+		},
+		{
+			name: "per-call metadata overrides defaults",
+			store: func(s *S3BlobStorage, content string) (string, error) {
+				return s.StoreWithMetadata(content, map[string]string{"app-version": "1.5.0-rc1", "filename": "a.txt"})
+			},
+			expected: map[string]string{"service": "raven", "app-version": "1.5.0-rc1", "filename": "a.txt"},
+		},
+		{
+			name: "streamed multipart store",
+			store: func(s *S3BlobStorage, content string) (string, error) {
+				s.multipartThreshold = 4
+				s.multipartPartSize = 4
+				return s.StoreReader(strings.NewReader(content))
+			},
+			expected: map[string]string{"service": "raven", "app-version": "1.4.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, objects := newBucketMock()
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			storage.defaultMetadata = map[string]string{"service": "raven", "app-version": "1.4.0"}
+			content := "audited attachment"
+
+			blobID, err := tt.store(storage, content)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if blobID != testBlobID(content) {
+				t.Errorf("expected metadata not to affect blob ID: got %q, want %q", blobID, testBlobID(content))
+			}
+			if got := objects["blobs/"+blobID].metadata; !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected stored metadata=%v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetMetadata(t *testing.T) {
+	testBlobID := "abc123def456abc123def456abc123def456abc123def456abc123def456abcd"
+
+	tests := []struct {
+		name          string
+		enabled       bool
+		setupMock     func(*mockS3Client)
+		expectError   bool
+		errorIs       error
+		errorContains string
+		expected      map[string]string
+	}{
+		{
+			name:          "disabled storage",
+			enabled:       false,
+			setupMock:     func(m *mockS3Client) {},
+			expectError:   true,
+			errorContains: "blob storage is not enabled",
+		},
+		{
+			name:    "returns caller metadata without internal keys",
+			enabled: true,
+			setupMock: func(m *mockS3Client) {
+				m.headObjectFunc = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+					if *params.Key != "blobs/"+testBlobID {
+						t.Errorf("expected key=%q, got %q", "blobs/"+testBlobID, *params.Key)
+					}
+					return &s3.HeadObjectOutput{Metadata: map[string]string{
+						"filename":   "report.pdf",
+						metaEncoding: CompressionGzip,
+					}}, nil
+				}
+			},
+			expected: map[string]string{"filename": "report.pdf"},
+		},
+		{
+			name:    "head object error",
+			enabled: true,
+			setupMock: func(m *mockS3Client) {
+				m.headObjectFunc = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+					return nil, errors.New("head failed")
+				}
+			},
+			expectError:   true,
+			errorContains: "failed to get blob metadata",
+		},
+		{
+			name:    "missing blob",
+			enabled: true,
+			setupMock: func(m *mockS3Client) {
+				m.headObjectFunc = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+					return nil, &smithy.GenericAPIError{Code: "NotFound"}
+				}
+			},
+			expectError: true,
+			errorIs:     ErrBlobNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockS3Client{}
+			tt.setupMock(mock)
+			storage := newMockS3BlobStorage(mock, "test-bucket", tt.enabled)
+
+			metadata, err := storage.GetMetadata(testBlobID)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				} else if tt.errorIs != nil && !errors.Is(err, tt.errorIs) {
+					t.Errorf("expected error %v, got %v", tt.errorIs, err)
+				} else if tt.errorContains != "" && !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("expected error containing %q, got %q", tt.errorContains, err.Error())
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(metadata, tt.expected) {
+				t.Errorf("expected metadata=%v, got %v", tt.expected, metadata)
+			}
+		})
+	}
+}
+
+func TestRetrieveWithMetadata(t *testing.T) {
+	content := "attachment bytes"
+	metadata := map[string]string{"filename": "report.pdf"}
+
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.compression = CompressionGzip
+
+	blobID, err := storage.store(context.Background(), []byte(content), putOptions{metadata: metadata, contentType: "application/pdf"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mock.headObjectFunc = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		t.Error("HeadObject should not be called")
+		return nil, errors.New("unexpected HeadObject")
+	}
+
+	retrieved, gotMetadata, contentType, err := storage.RetrieveWithMetadata(blobID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if retrieved != content {
+		t.Errorf("expected content %q, got %q", content, retrieved)
+	}
+	if !reflect.DeepEqual(gotMetadata, metadata) {
+		t.Errorf("expected metadata %v without internal keys, got %v", metadata, gotMetadata)
+	}
+	if contentType != "application/pdf" {
+		t.Errorf("expected content type application/pdf, got %q", contentType)
+	}
+
+	if _, _, _, err := storage.RetrieveWithMetadata(testBlobID("missing")); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+}
+
+func TestRetrieveWithMetadataRetriesIntegrityMismatch(t *testing.T) {
+	content := "content that a flaky gateway truncates"
+	served := []string{content[:10], content}
+	gets := 0
+	mock := &mockS3Client{
+		getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			body := served[min(gets, len(served)-1)]
+			gets++
+			return &s3.GetObjectOutput{
+				Body:     io.NopCloser(strings.NewReader(body)),
+				Metadata: map[string]string{"filename": "report.pdf"},
+			}, nil
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.verifyOnRetrieve = true
+	storage.integrityRetries = 1
+
+	retrieved, metadata, _, err := storage.RetrieveWithMetadata(testBlobID(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if retrieved != content || metadata["filename"] != "report.pdf" {
+		t.Errorf("expected the retried content and its metadata, got %q and %v", retrieved, metadata)
+	}
+	if gets != 2 {
+		t.Errorf("expected 2 GetObject calls, got %d", gets)
+	}
+
+	storage.integrityRetries = 0
+	gets = 0
+	if _, _, _, err := storage.RetrieveWithMetadata(testBlobID(content)); !errors.Is(err, ErrIntegrityMismatch) {
+		t.Errorf("expected ErrIntegrityMismatch with retries disabled, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Metrics receives the latency and outcome of every S3 operation, letting
+// callers export them (e.g. to Prometheus) without this package depending on
+// a metrics library
+type Metrics interface {
+	// ObserveOp is called after each S3 operation with its name (e.g.
+	// "PutObject"), how long it took and the error it returned, if any
+	ObserveOp(op string, dur time.Duration, err error)
+}
+
+// NoopMetrics is the default Metrics, discarding all observations
+type NoopMetrics struct{}
+
+// ObserveOp does nothing
+func (NoopMetrics) ObserveOp(string, time.Duration, error) {}
+
+// instrumentedClient wraps an S3Api, reporting each call to Metrics and the
+// circuit breaker
+type instrumentedClient struct {
+	next    S3Api
+	metrics Metrics
+	breaker *circuitBreaker
+}
+
+// observe reports an operation that started at start. A request cancelled
+// with a cause, such as the failover timeout, is reported with that cause
+// rather than context.Canceled.
+func (c *instrumentedClient) observe(ctx context.Context, op string, start time.Time, err error) {
+	if errors.Is(err, context.Canceled) {
+		if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+			err = cause
+		}
+	}
+	c.metrics.ObserveOp(op, time.Since(start), err)
+	c.breaker.record(err)
+}
+
+func (c *instrumentedClient) CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	start := time.Now()
+	out, err := c.next.CreateBucket(ctx, params, optFns...)
+	c.observe(ctx, "CreateBucket", start, err)
+	return out, err
+}
+
+func (c *instrumentedClient) HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	start := time.Now()
+	out, err := c.next.HeadBucket(ctx, params, optFns...)
+	c.observe(ctx, "HeadBucket", start, err)
+	return out, err
+}
+
+func (c *instrumentedClient) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	start := time.Now()
+	out, err := c.next.PutObject(ctx, params, optFns...)
+	c.observe(ctx, "PutObject", start, err)
+	return out, err
+}
+
+func (c *instrumentedClient) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	start := time.Now()
+	out, err := c.next.GetObject(ctx, params, optFns...)
+	c.observe(ctx, "GetObject", start, err)
+	return out, err
+}
+
+func (c *instrumentedClient) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	start := time.Now()
+	out, err := c.next.HeadObject(ctx, params, optFns...)
+	c.observe(ctx, "HeadObject", start, err)
+	return out, err
+}
+
+func (c *instrumentedClient) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	start := time.Now()
+	out, err := c.next.DeleteObject(ctx, params, optFns...)
+	c.observe(ctx, "DeleteObject", start, err)
+	return out, err
+}
+
+func (c *instrumentedClient) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	start := time.Now()
+	out, err := c.next.DeleteObjects(ctx, params, optFns...)
+	c.observe(ctx, "DeleteObjects", start, err)
+	return out, err
+}
+
+func (c *instrumentedClient) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	start := time.Now()
+	out, err := c.next.CreateMultipartUpload(ctx, params, optFns...)
+	c.observe(ctx, "CreateMultipartUpload", start, err)
+	return out, err
+}
+
+func (c *instrumentedClient) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	start := time.Now()
+	out, err := c.next.UploadPart(ctx, params, optFns...)
+	c.observe(ctx, "UploadPart", start, err)
+	return out, err
+}
+
+func (c *instrumentedClient) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	start := time.Now()
+	out, err := c.next.CompleteMultipartUpload(ctx, params, optFns...)
+	c.observe(ctx, "CompleteMultipartUpload", start, err)
+	return out, err
+}
+
+func (c *instrumentedClient) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	start := time.Now()
+	out, err := c.next.AbortMultipartUpload(ctx, params, optFns...)
+	c.observe(ctx, "AbortMultipartUpload", start, err)
+	return out, err
+}
+
+func (c *instrumentedClient) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	start := time.Now()
+	out, err := c.next.ListObjectsV2(ctx, params, optFns...)
+	c.observe(ctx, "ListObjectsV2", start, err)
+	return out, err
+}
+
+func (c *instrumentedClient) GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	start := time.Now()
+	out, err := c.next.GetObjectTagging(ctx, params, optFns...)
+	c.observe(ctx, "GetObjectTagging", start, err)
+	return out, err
+}
+
+func (c *instrumentedClient) PutObjectTagging(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+	start := time.Now()
+	out, err := c.next.PutObjectTagging(ctx, params, optFns...)
+	c.observe(ctx, "PutObjectTagging", start, err)
+	return out, err
+}
+
+func (c *instrumentedClient) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	start := time.Now()
+	out, err := c.next.CopyObject(ctx, params, optFns...)
+	c.observe(ctx, "CopyObject", start, err)
+	return out, err
+}
+
+func (c *instrumentedClient) RestoreObject(ctx context.Context, params *s3.RestoreObjectInput, optFns ...func(*s3.Options)) (*s3.RestoreObjectOutput, error) {
+	start := time.Now()
+	out, err := c.next.RestoreObject(ctx, params, optFns...)
+	c.observe(ctx, "RestoreObject", start, err)
+	return out, err
+}
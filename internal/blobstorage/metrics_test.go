@@ -0,0 +1,81 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// observation is a single call recorded by recordingMetrics
+type observation struct {
+	op  string
+	err error
+}
+
+// recordingMetrics records every operation it observes
+type recordingMetrics struct {
+	observations []observation
+}
+
+func (m *recordingMetrics) ObserveOp(op string, dur time.Duration, err error) {
+	if dur < 0 {
+		panic("negative operation duration")
+	}
+	m.observations = append(m.observations, observation{op: op, err: err})
+}
+
+func TestMetricsObserveOperations(t *testing.T) {
+	mock, _ := newBucketMock()
+	metrics := &recordingMetrics{}
+	storage := newMockS3BlobStorage(&instrumentedClient{next: mock, metrics: metrics}, "test-bucket", true)
+
+	blobID, err := storage.Store("observed content")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := storage.Retrieve(blobID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var ops []string
+	for _, o := range metrics.observations {
+		ops = append(ops, o.op)
+	}
+	// The first HeadObject is the dedup check for a blob that doesn't exist yet
+	expected := []string{"HeadObject", "PutObject", "GetObject"}
+	if !reflect.DeepEqual(ops, expected) {
+		t.Errorf("expected ops=%v, got %v", expected, ops)
+	}
+	if metrics.observations[0].err == nil {
+		t.Error("expected the not-found HeadObject to be observed with its error")
+	}
+	if metrics.observations[1].err != nil {
+		t.Errorf("expected successful PutObject, got %v", metrics.observations[1].err)
+	}
+}
+
+func TestMetricsObserveErrors(t *testing.T) {
+	deleteErr := errors.New("delete failed")
+	mock := &mockS3Client{
+		deleteObjectFunc: func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+			return nil, deleteErr
+		},
+	}
+	metrics := &recordingMetrics{}
+	storage := newMockS3BlobStorage(&instrumentedClient{next: mock, metrics: metrics}, "test-bucket", true)
+
+	if err := storage.Delete(testBlobID("content")); err == nil {
+		t.Fatal("expected error but got none")
+	}
+
+	if len(metrics.observations) != 1 {
+		t.Fatalf("expected 1 observation, got %d", len(metrics.observations))
+	}
+	if o := metrics.observations[0]; o.op != "DeleteObject" || !errors.Is(o.err, deleteErr) {
+		t.Errorf("expected DeleteObject observed with its error, got %+v", o)
+	}
+}
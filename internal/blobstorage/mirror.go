@@ -0,0 +1,93 @@
+package blobstorage
+
+import "fmt"
+
+// MirrorMode controls how MirrorBlobStorage treats failures on its secondary
+type MirrorMode int
+
+const (
+	// MirrorBestEffort logs secondary failures and reports success once the
+	// primary has succeeded
+	MirrorBestEffort MirrorMode = iota
+	// MirrorRequired fails writes that fail on the secondary, after they have
+	// been applied to the primary
+	MirrorRequired
+)
+
+// MirrorBlobStorage mirrors writes to a secondary BlobStorage, e.g. to fill a
+// new backend during a migration so reads can be cut over safely. Store and
+// Delete go to the primary and then the secondary; Retrieve and Exists only
+// read from the primary.
+type MirrorBlobStorage struct {
+	primary   BlobStorage
+	secondary BlobStorage
+	mode      MirrorMode
+	logger    Logger
+}
+
+var _ BlobStorage = (*MirrorBlobStorage)(nil)
+
+// NewMirrorBlobStorage creates a MirrorBlobStorage writing to primary and
+// secondary. Secondary failures are logged to logger, which may be nil.
+func NewMirrorBlobStorage(primary, secondary BlobStorage, mode MirrorMode, logger Logger) *MirrorBlobStorage {
+	if logger == nil {
+		logger = NoopLogger{}
+	}
+	return &MirrorBlobStorage{primary: primary, secondary: secondary, mode: mode, logger: logger}
+}
+
+// IsEnabled reports whether the primary storage is enabled
+func (m *MirrorBlobStorage) IsEnabled() bool {
+	return m.primary.IsEnabled()
+}
+
+// Store stores content in the primary and then the secondary, returning the
+// primary's blob ID
+func (m *MirrorBlobStorage) Store(content string) (string, error) {
+	blobID, err := m.primary.Store(content)
+	if err != nil {
+		return "", err
+	}
+
+	mirroredID, err := m.secondary.Store(content)
+	if err == nil && mirroredID != blobID {
+		err = fmt.Errorf("secondary stored it as %s", mirroredID)
+	}
+	if err != nil {
+		return blobID, m.secondaryFailed("store", blobID, err)
+	}
+	return blobID, nil
+}
+
+// Retrieve returns a blob's content from the primary
+func (m *MirrorBlobStorage) Retrieve(blobID string) (string, error) {
+	return m.primary.Retrieve(blobID)
+}
+
+// Delete deletes a blob from the primary and then the secondary
+func (m *MirrorBlobStorage) Delete(blobID string) error {
+	if err := m.primary.Delete(blobID); err != nil {
+		return err
+	}
+
+	if err := m.secondary.Delete(blobID); err != nil {
+		return m.secondaryFailed("delete", blobID, err)
+	}
+	return nil
+}
+
+// Exists checks the primary for a blob
+func (m *MirrorBlobStorage) Exists(blobID string) (bool, error) {
+	return m.primary.Exists(blobID)
+}
+
+// secondaryFailed handles a failed write to the secondary according to the
+// mirror mode, returning the error to report, if any
+func (m *MirrorBlobStorage) secondaryFailed(op, blobID string, err error) error {
+	err = fmt.Errorf("failed to %s blob %s on secondary: %w", op, blobID, err)
+	if m.mode == MirrorRequired {
+		return err
+	}
+	m.logger.Errorf("blobstorage: %v", err)
+	return nil
+}
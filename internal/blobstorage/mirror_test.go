@@ -0,0 +1,105 @@
+package blobstorage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// failingBlobStorage fails every write with err
+type failingBlobStorage struct {
+	BlobStorage
+	err error
+}
+
+func (f *failingBlobStorage) Store(content string) (string, error) {
+	return "", f.err
+}
+
+func (f *failingBlobStorage) Delete(blobID string) error {
+	return f.err
+}
+
+func TestMirrorBlobStorage(t *testing.T) {
+	primary, secondary := NewMemoryBlobStorage(), NewMemoryBlobStorage()
+	mirror := NewMirrorBlobStorage(primary, secondary, MirrorRequired, nil)
+	content := "migrating content"
+
+	blobID, err := mirror.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected store error: %v", err)
+	}
+	for name, backend := range map[string]BlobStorage{"primary": primary, "secondary": secondary} {
+		if retrieved, err := backend.Retrieve(blobID); err != nil || retrieved != content {
+			t.Errorf("expected %s to hold the blob, got %q (err=%v)", name, retrieved, err)
+		}
+	}
+
+	// Reads only go to the primary
+	if err := secondary.Delete(blobID); err != nil {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+	if retrieved, err := mirror.Retrieve(blobID); err != nil || retrieved != content {
+		t.Errorf("expected retrieve from primary, got %q (err=%v)", retrieved, err)
+	}
+	if exists, err := mirror.Exists(blobID); err != nil || !exists {
+		t.Errorf("expected exists from primary, got %v (err=%v)", exists, err)
+	}
+
+	if _, err := secondary.Store(content); err != nil {
+		t.Fatalf("unexpected store error: %v", err)
+	}
+	if err := mirror.Delete(blobID); err != nil {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+	for name, backend := range map[string]BlobStorage{"primary": primary, "secondary": secondary} {
+		if exists, _ := backend.Exists(blobID); exists {
+			t.Errorf("expected blob to be deleted from %s", name)
+		}
+	}
+}
+
+func TestMirrorBlobStorageSecondaryFailure(t *testing.T) {
+	secondaryErr := errors.New("secondary unavailable")
+
+	tests := []struct {
+		name      string
+		mode      MirrorMode
+		expectErr bool
+	}{
+		{name: "best effort", mode: MirrorBestEffort},
+		{name: "required", mode: MirrorRequired, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := NewMemoryBlobStorage()
+			logger := &recordingLogger{}
+			mirror := NewMirrorBlobStorage(primary, &failingBlobStorage{BlobStorage: NewMemoryBlobStorage(), err: secondaryErr}, tt.mode, logger)
+
+			blobID, storeErr := mirror.Store("content")
+			deleteErr := mirror.Delete(testBlobID("content"))
+
+			for op, err := range map[string]error{"store": storeErr, "delete": deleteErr} {
+				if tt.expectErr != (err != nil) {
+					t.Errorf("%s: expected error=%v, got %v", op, tt.expectErr, err)
+				}
+				if err != nil && !errors.Is(err, secondaryErr) {
+					t.Errorf("%s: expected the secondary's error, got %v", op, err)
+				}
+			}
+
+			// The primary is written either way
+			if blobID != testBlobID("content") {
+				t.Errorf("expected blobID=%q, got %q", testBlobID("content"), blobID)
+			}
+			if len(primary.blobs) != 0 {
+				t.Error("expected the primary delete to have been applied")
+			}
+
+			if !tt.expectErr && (len(logger.error) != 2 || !strings.Contains(logger.error[0], "secondary unavailable")) {
+				t.Errorf("expected both failures to be logged, got %v", logger.error)
+			}
+		})
+	}
+}
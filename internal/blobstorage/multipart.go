@@ -0,0 +1,242 @@
+package blobstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// defaultMultipartThreshold is the stored size above which uploads switch
+	// from a single PutObject to a multipart upload
+	defaultMultipartThreshold = 100 * 1024 * 1024
+	// defaultMultipartPartSize is the size of each uploaded part except the last
+	defaultMultipartPartSize = 16 * 1024 * 1024
+	// minMultipartPartSize and maxMultipartPartSize bound MultipartPartSize;
+	// S3 and MinIO reject smaller parts other than the last
+	minMultipartPartSize = 5 * 1024 * 1024
+	maxMultipartPartSize = 5 * 1024 * 1024 * 1024
+	// maxMultipartParts is the most parts S3 accepts in one upload
+	maxMultipartParts = 10000
+)
+
+// Checks for Config.VerifyMultipart
+const (
+	// VerifyMultipartSize checks the completed object's size with HeadObject
+	VerifyMultipartSize = "size"
+	// VerifyMultipartContent also downloads the completed object and checks
+	// its SHA256 against the bytes uploaded
+	VerifyMultipartContent = "content"
+)
+
+// multipartPartCount returns how many parts of partSize an upload of size
+// bytes takes
+func multipartPartCount(size, partSize int64) int64 {
+	return (size + partSize - 1) / partSize
+}
+
+// checkPartCount fails if an upload of size bytes would take more than
+// maxMultipartParts parts at the configured part size
+func (s *S3BlobStorage) checkPartCount(size int64) error {
+	if parts := multipartPartCount(size, s.multipartPartSize); parts > maxMultipartParts {
+		return fmt.Errorf("blob of %d bytes needs %d parts of %d bytes, more than the limit of %d: increase the multipart part size",
+			size, parts, s.multipartPartSize, maxMultipartParts)
+	}
+	return nil
+}
+
+// uploadMultipart uploads body to key in parts, aborting the upload if any
+// part or the completion fails so no orphaned parts are left behind
+func (s *S3BlobStorage) uploadMultipart(ctx context.Context, key string, body io.Reader, opts putOptions, encodingMetadata map[string]string) error {
+	var sent *uploadDigest
+	if s.verifyMultipart != "" {
+		sent = &uploadDigest{hash: sha256.New()}
+		body = io.TeeReader(body, sent)
+	}
+
+	created, err := s.client.CreateMultipartUpload(ctx, s.newCreateMultipartUploadInput(key, opts, encodingMetadata))
+	if err != nil {
+		return fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	uploadID := created.UploadId
+
+	parts, err := s.uploadParts(ctx, key, uploadID, body, opts.progress)
+	if err == nil {
+		_, err = s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(s.bucket),
+			Key:             aws.String(key),
+			UploadId:        uploadID,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+		})
+		if err != nil {
+			err = fmt.Errorf("failed to complete multipart upload: %w", err)
+		}
+	}
+	if err != nil {
+		if abortErr := s.abortMultipart(key, uploadID); abortErr != nil {
+			s.logger.Errorf("blobstorage: multipart upload %s for %s may be left incomplete: %v", aws.ToString(uploadID), key, abortErr)
+			return errors.Join(err, abortErr)
+		}
+		return err
+	}
+
+	if sent != nil {
+		if err := s.verifyCompletedUpload(ctx, key, sent); err != nil {
+			// Leaving the object would let deduplication skip future
+			// uploads of the same content
+			if _, delErr := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket: aws.String(s.bucket),
+				Key:    aws.String(key),
+			}); delErr != nil {
+				s.logger.Errorf("blobstorage: failed to delete %s after failed multipart verification: %v", key, delErr)
+				return errors.Join(err, delErr)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// uploadDigest records the size and SHA256 of the bytes sent in an upload
+type uploadDigest struct {
+	size int64
+	hash hash.Hash
+}
+
+func (d *uploadDigest) Write(p []byte) (int, error) {
+	d.size += int64(len(p))
+	return d.hash.Write(p)
+}
+
+// verifyCompletedUpload checks that the object S3 assembled at key from a
+// multipart upload matches what was sent: its size with VerifyMultipartSize,
+// and also its SHA256 with VerifyMultipartContent. Multipart ETags are not
+// content MD5s, so the upload's own responses can't show this.
+func (s *S3BlobStorage) verifyCompletedUpload(ctx context.Context, key string, sent *uploadDigest) error {
+	head, err := s.client.HeadObject(ctx, s.headObjectInput(key))
+	if err != nil {
+		return fmt.Errorf("failed to verify multipart upload: %w", err)
+	}
+	if size := aws.ToInt64(head.ContentLength); size != sent.size {
+		return fmt.Errorf("%w: completed object is %d bytes, uploaded %d", ErrMultipartMismatch, size, sent.size)
+	}
+
+	if s.verifyMultipart != VerifyMultipartContent {
+		return nil
+	}
+
+	result, err := s.client.GetObject(ctx, s.getObjectInput(s.bucket, key))
+	if err != nil {
+		return fmt.Errorf("failed to verify multipart upload: %w", err)
+	}
+	defer func() { _ = result.Body.Close() }()
+
+	stored := sha256.New()
+	if _, err := s.copyBuffered(stored, result.Body); err != nil {
+		return fmt.Errorf("failed to verify multipart upload: %w", err)
+	}
+	if !bytes.Equal(stored.Sum(nil), sent.hash.Sum(nil)) {
+		return fmt.Errorf("%w: completed object content differs from the uploaded parts", ErrMultipartMismatch)
+	}
+	return nil
+}
+
+// uploadParts reads body in part-sized chunks and uploads each one, passing
+// progress, if not nil, the bytes uploaded after each part completes
+func (s *S3BlobStorage) uploadParts(ctx context.Context, key string, uploadID *string, body io.Reader, progress func(written int64)) ([]types.CompletedPart, error) {
+	var parts []types.CompletedPart
+	var written int64
+	buf := make([]byte, s.multipartPartSize)
+
+	for partNumber := int32(1); ; partNumber++ {
+		n, readErr := io.ReadFull(body, buf)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("failed to read part %d: %w", partNumber, readErr)
+		}
+		if n == 0 {
+			return parts, nil
+		}
+		if partNumber > maxMultipartParts {
+			return nil, fmt.Errorf("upload exceeds the limit of %d parts of %d bytes", maxMultipartParts, s.multipartPartSize)
+		}
+
+		input := &s3.UploadPartInput{
+			Bucket:        aws.String(s.bucket),
+			Key:           aws.String(key),
+			UploadId:      uploadID,
+			PartNumber:    aws.Int32(partNumber),
+			Body:          bytes.NewReader(buf[:n]),
+			ContentLength: aws.Int64(int64(n)),
+		}
+		input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = s.sseCustomer()
+		if contentMD5 := s.contentMD5For(buf[:n]); contentMD5 != "" {
+			input.ContentMD5 = aws.String(contentMD5)
+		}
+		out, err := s.client.UploadPart(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+		}
+
+		parts = append(parts, types.CompletedPart{
+			ETag:       out.ETag,
+			PartNumber: aws.Int32(partNumber),
+		})
+		written += int64(n)
+		if progress != nil {
+			progress(written)
+		}
+
+		if readErr != nil {
+			// A short read means this was the last part
+			return parts, nil
+		}
+	}
+}
+
+// abortMultipart aborts an incomplete multipart upload. It uses its own
+// timeout so an abort still goes out when the upload's context has expired.
+func (s *S3BlobStorage) abortMultipart(key string, uploadID *string) error {
+	ctx, cancel := context.WithTimeout(s.ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: uploadID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
+}
+
+// newCreateMultipartUploadInput builds the CreateMultipartUpload request for a
+// blob, mirroring the settings newPutObjectInput applies to single uploads
+func (s *S3BlobStorage) newCreateMultipartUploadInput(key string, opts putOptions, encodingMetadata map[string]string) *s3.CreateMultipartUploadInput {
+	sse, kmsKeyID := s.serverSideEncryption()
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:                    aws.String(s.bucket),
+		Key:                       aws.String(key),
+		ContentType:               aws.String(opts.contentType),
+		Metadata:                  s.objectMetadata(opts, encodingMetadata),
+		Expires:                   expiresHeader(opts),
+		Tagging:                   encodeTags(s.objectTags(opts.tags)),
+		ServerSideEncryption:      sse,
+		SSEKMSKeyId:               kmsKeyID,
+		StorageClass:              s.storageClassFor(opts),
+		ACL:                       types.ObjectCannedACL(s.acl),
+		ObjectLockMode:            types.ObjectLockMode(s.objectLockMode),
+		ObjectLockRetainUntilDate: s.retainUntilFor(),
+	}
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = s.sseCustomer()
+	return input
+}
@@ -0,0 +1,309 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// newMultipartTestStorage returns storage that switches to multipart above
+// 16 bytes using 8 byte parts
+func newMultipartTestStorage(mock S3Api) *S3BlobStorage {
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.multipartThreshold = 16
+	storage.multipartPartSize = 8
+	return storage
+}
+
+func TestStoreMultipart(t *testing.T) {
+	content := "content large enough for three parts"
+
+	mock, objects := newBucketMock()
+	putObject := mock.putObjectFunc
+	mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		if *params.Key == "blobs/"+testBlobID(content) {
+			t.Error("PutObject should not be used above the multipart threshold")
+		}
+		return putObject(ctx, params, optFns...)
+	}
+	var partNumbers []int32
+	uploadPart := mock.uploadPartFunc
+	mock.uploadPartFunc = func(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+		partNumbers = append(partNumbers, *params.PartNumber)
+		return uploadPart(ctx, params, optFns...)
+	}
+	storage := newMultipartTestStorage(mock)
+
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if blobID != testBlobID(content) {
+		t.Errorf("expected blobID=%q, got %q", testBlobID(content), blobID)
+	}
+
+	expectedParts := (len(content) + 7) / 8
+	if len(partNumbers) != expectedParts {
+		t.Errorf("expected %d parts, got %d", expectedParts, len(partNumbers))
+	}
+	for i, n := range partNumbers {
+		if n != int32(i+1) {
+			t.Errorf("expected part number %d, got %d", i+1, n)
+		}
+	}
+
+	if got := string(objects["blobs/"+blobID].body); got != content {
+		t.Errorf("expected assembled content=%q, got %q", content, got)
+	}
+}
+
+func TestStoreBelowMultipartThreshold(t *testing.T) {
+	mock, _ := newBucketMock()
+	mock.createMultipartUploadFunc = func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+		t.Error("CreateMultipartUpload should not be called below the threshold")
+		return nil, errors.New("unexpected multipart upload")
+	}
+	storage := newMultipartTestStorage(mock)
+
+	if _, err := storage.Store("small content"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestStoreMultipartPartFailureAborts(t *testing.T) {
+	content := "content large enough for three parts"
+
+	mock, objects := newBucketMock()
+	uploadPart := mock.uploadPartFunc
+	mock.uploadPartFunc = func(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+		if *params.PartNumber == 2 {
+			return nil, errors.New("connection reset")
+		}
+		return uploadPart(ctx, params, optFns...)
+	}
+	var abortedUploadID string
+	mock.abortMultipartUploadFunc = func(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+		abortedUploadID = *params.UploadId
+		return &s3.AbortMultipartUploadOutput{}, nil
+	}
+	mock.completeMultipartUploadFunc = func(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+		t.Error("CompleteMultipartUpload should not be called after a part failure")
+		return &s3.CompleteMultipartUploadOutput{}, nil
+	}
+	storage := newMultipartTestStorage(mock)
+
+	_, err := storage.Store(content)
+	if err == nil {
+		t.Fatal("expected error but got none")
+	}
+	if !strings.Contains(err.Error(), "failed to upload part 2") {
+		t.Errorf("expected part failure error, got %q", err.Error())
+	}
+	if abortedUploadID != "upload-1" {
+		t.Errorf("expected upload-1 to be aborted, got %q", abortedUploadID)
+	}
+	if len(objects) != 0 {
+		t.Errorf("expected no stored objects, got %d", len(objects))
+	}
+}
+
+func TestStoreMultipartCompleteFailureAborts(t *testing.T) {
+	mock, _ := newBucketMock()
+	mock.completeMultipartUploadFunc = func(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+		return nil, errors.New("invalid part order")
+	}
+	aborted := false
+	mock.abortMultipartUploadFunc = func(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+		aborted = true
+		return &s3.AbortMultipartUploadOutput{}, nil
+	}
+	storage := newMultipartTestStorage(mock)
+
+	_, err := storage.Store("content large enough for three parts")
+	if err == nil || !strings.Contains(err.Error(), "failed to complete multipart upload") {
+		t.Errorf("expected completion error, got %v", err)
+	}
+	if !aborted {
+		t.Error("expected multipart upload to be aborted")
+	}
+}
+
+func TestStoreReaderWithSizeAndHashMultipartMismatchAborts(t *testing.T) {
+	content := "content large enough for three parts"
+
+	mock, objects := newBucketMock()
+	aborted := false
+	abort := mock.abortMultipartUploadFunc
+	mock.abortMultipartUploadFunc = func(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+		aborted = true
+		return abort(ctx, params, optFns...)
+	}
+	storage := newMultipartTestStorage(mock)
+
+	_, err := storage.StoreReaderWithSizeAndHash(strings.NewReader(content), int64(len(content)), testBlobID("different content"))
+	if !errors.Is(err, ErrHashMismatch) {
+		t.Fatalf("expected ErrHashMismatch, got %v", err)
+	}
+	if !aborted {
+		t.Error("expected multipart upload to be aborted")
+	}
+	if len(objects) != 0 {
+		t.Errorf("expected no stored objects, got %d", len(objects))
+	}
+}
+
+func TestStoreReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "single upload", content: "small"},
+		{name: "multipart upload", content: "content large enough for three parts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, objects := newBucketMock()
+			storage := newMultipartTestStorage(mock)
+
+			blobID, err := storage.StoreReader(strings.NewReader(tt.content))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if blobID != testBlobID(tt.content) {
+				t.Errorf("expected blobID=%q, got %q", testBlobID(tt.content), blobID)
+			}
+			if got := string(objects["blobs/"+blobID].body); got != tt.content {
+				t.Errorf("expected stored content=%q, got %q", tt.content, got)
+			}
+		})
+	}
+
+	t.Run("disabled storage", func(t *testing.T) {
+		storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", false)
+		if _, err := storage.StoreReader(strings.NewReader("x")); err == nil {
+			t.Error("expected error but got none")
+		}
+	})
+}
+
+func TestVerifyMultipart(t *testing.T) {
+	content := "content large enough for three parts"
+
+	tests := []struct {
+		name        string
+		verify      string
+		corrupt     func(body []byte) []byte
+		expectError bool
+	}{
+		{name: "correct assembly", verify: VerifyMultipartContent, corrupt: func(body []byte) []byte { return body }},
+		{name: "missing part by size", verify: VerifyMultipartSize, corrupt: func(body []byte) []byte { return body[8:] }, expectError: true},
+		{name: "reordered parts by content", verify: VerifyMultipartContent, corrupt: func(body []byte) []byte {
+			return append(append([]byte{}, body[8:16]...), append(body[:8:8], body[16:]...)...)
+		}, expectError: true},
+		{name: "reordered parts pass the size check", verify: VerifyMultipartSize, corrupt: func(body []byte) []byte {
+			return append(append([]byte{}, body[8:16]...), append(body[:8:8], body[16:]...)...)
+		}},
+		{name: "disabled", corrupt: func(body []byte) []byte { return body[8:] }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, objects := newBucketMock()
+			complete := mock.completeMultipartUploadFunc
+			mock.completeMultipartUploadFunc = func(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+				out, err := complete(ctx, params, optFns...)
+				if err == nil {
+					// A buggy backend assembling the parts wrongly
+					obj := objects[*params.Key]
+					obj.body = tt.corrupt(obj.body)
+				}
+				return out, err
+			}
+			storage := newMultipartTestStorage(mock)
+			storage.verifyMultipart = tt.verify
+
+			blobID, err := storage.Store(content)
+			if !tt.expectError {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if _, ok := objects["blobs/"+blobID]; !ok {
+					t.Error("expected the object to be kept")
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrMultipartMismatch) {
+				t.Fatalf("expected ErrMultipartMismatch, got %v", err)
+			}
+			if _, ok := objects["blobs/"+testBlobID(content)]; ok {
+				t.Error("expected the mismatched object to be deleted")
+			}
+			if exists, err := storage.Exists(testBlobID(content)); err != nil || exists {
+				t.Errorf("expected the blob not to be reported stored, got %v, %v", exists, err)
+			}
+		})
+	}
+}
+
+func TestMultipartPartCount(t *testing.T) {
+	const mb = 1024 * 1024
+
+	tests := []struct {
+		name     string
+		size     int64
+		partSize int64
+		expected int64
+		tooMany  bool
+	}{
+		{name: "one byte", size: 1, partSize: 5 * mb, expected: 1},
+		{name: "exactly one part", size: 5 * mb, partSize: 5 * mb, expected: 1},
+		{name: "one byte over a part", size: 5*mb + 1, partSize: 5 * mb, expected: 2},
+		{name: "exactly the part limit", size: 10000 * 5 * mb, partSize: 5 * mb, expected: 10000},
+		{name: "one byte over the part limit", size: 10000*5*mb + 1, partSize: 5 * mb, expected: 10001, tooMany: true},
+		{name: "larger parts fit", size: 10000*5*mb + 1, partSize: 16 * mb, expected: 3126},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := multipartPartCount(tt.size, tt.partSize); got != tt.expected {
+				t.Errorf("expected %d parts, got %d", tt.expected, got)
+			}
+
+			storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+			storage.multipartPartSize = tt.partSize
+			err := storage.checkPartCount(tt.size)
+			if tt.tooMany && (err == nil || !strings.Contains(err.Error(), "increase the multipart part size")) {
+				t.Errorf("expected a part count error, got %v", err)
+			}
+			if !tt.tooMany && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreMultipartTooManyParts(t *testing.T) {
+	mock := &mockS3Client{
+		headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return nil, &smithy.GenericAPIError{Code: "NotFound"}
+		},
+		createMultipartUploadFunc: func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+			t.Error("CreateMultipartUpload should not be called")
+			return &s3.CreateMultipartUploadOutput{}, nil
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.multipartThreshold = 1
+	storage.multipartPartSize = 1
+
+	_, err := storage.Store(strings.Repeat("x", maxMultipartParts+1))
+	if err == nil || !strings.Contains(err.Error(), "needs 10001 parts") {
+		t.Errorf("expected a part count error, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	// ObjectLockGovernance retains blobs against deletion except by users
+	// with s3:BypassGovernanceRetention
+	ObjectLockGovernance = "GOVERNANCE"
+	// ObjectLockCompliance retains blobs against deletion by anyone, including
+	// the root user, until the retention period ends
+	ObjectLockCompliance = "COMPLIANCE"
+)
+
+// ErrBlobRetained is returned when deleting a blob whose object lock
+// retention period hasn't ended
+var ErrBlobRetained = errors.New("blob is retained by object lock")
+
+// validateObjectLock checks the object lock settings in c
+func validateObjectLock(c Config) error {
+	switch c.ObjectLockMode {
+	case "":
+		if c.ObjectLockDays != 0 || !c.ObjectLockRetainUntil.IsZero() {
+			return fmt.Errorf("object lock retention requires an object lock mode")
+		}
+		return nil
+	case ObjectLockGovernance, ObjectLockCompliance:
+	default:
+		return fmt.Errorf("invalid object lock mode %q", c.ObjectLockMode)
+	}
+
+	if c.ObjectLockDays < 0 {
+		return fmt.Errorf("invalid object lock days %d", c.ObjectLockDays)
+	}
+	if (c.ObjectLockDays > 0) == !c.ObjectLockRetainUntil.IsZero() {
+		return fmt.Errorf("object lock requires exactly one of object lock days and retain until")
+	}
+	return nil
+}
+
+// retainUntilFor returns when a blob uploaded now should be retained
+// until, or nil without object lock
+func (s *S3BlobStorage) retainUntilFor() *time.Time {
+	switch {
+	case s.objectLockMode == "":
+		return nil
+	case s.objectLockDays > 0:
+		return aws.Time(s.clock().AddDate(0, 0, s.objectLockDays))
+	default:
+		return aws.Time(s.objectLockRetainUntil)
+	}
+}
+
+// checkRetention fails with ErrBlobRetained if the blob at key is still
+// within its object lock retention period
+func (s *S3BlobStorage) checkRetention(ctx context.Context, blobID, key string) error {
+	head, err := s.client.HeadObject(ctx, s.headObjectInput(key))
+	if err != nil {
+		if isNotFound(err) {
+			// Deleting a missing blob is not an error
+			return nil
+		}
+		return fmt.Errorf("failed to check blob retention: %w", err)
+	}
+
+	if head.ObjectLockMode == "" || head.ObjectLockRetainUntilDate == nil || !s.clock().Before(*head.ObjectLockRetainUntilDate) {
+		return nil
+	}
+	return fmt.Errorf("cannot delete blob %s under %s object lock until %s: %w",
+		blobID, head.ObjectLockMode, head.ObjectLockRetainUntilDate.UTC().Format(time.RFC3339), ErrBlobRetained)
+}
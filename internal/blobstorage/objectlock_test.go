@@ -0,0 +1,140 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestStoreObjectLock(t *testing.T) {
+	retainUntil := time.Date(2035, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		mode        string
+		days        int
+		retainUntil time.Time
+		expectedMin time.Time
+		expectedMax time.Time
+	}{
+		{name: "disabled"},
+		{
+			name:        "governance for 30 days",
+			mode:        ObjectLockGovernance,
+			days:        30,
+			expectedMin: time.Now().AddDate(0, 0, 30).Add(-time.Minute),
+			expectedMax: time.Now().AddDate(0, 0, 30).Add(time.Minute),
+		},
+		{
+			name:        "compliance until a fixed date",
+			mode:        ObjectLockCompliance,
+			retainUntil: retainUntil,
+			expectedMin: retainUntil,
+			expectedMax: retainUntil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, _ := newBucketMock()
+			putObject := mock.putObjectFunc
+			var input *s3.PutObjectInput
+			mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				input = params
+				return putObject(ctx, params, optFns...)
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			storage.objectLockMode = tt.mode
+			storage.objectLockDays = tt.days
+			storage.objectLockRetainUntil = tt.retainUntil
+
+			if _, err := storage.Store("regulated content"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if input.ObjectLockMode != types.ObjectLockMode(tt.mode) {
+				t.Errorf("expected ObjectLockMode=%q, got %q", tt.mode, input.ObjectLockMode)
+			}
+			if tt.mode == "" {
+				if input.ObjectLockRetainUntilDate != nil {
+					t.Errorf("expected no retain until date, got %v", input.ObjectLockRetainUntilDate)
+				}
+				return
+			}
+			got := aws.ToTime(input.ObjectLockRetainUntilDate)
+			if got.Before(tt.expectedMin) || got.After(tt.expectedMax) {
+				t.Errorf("expected retain until between %v and %v, got %v", tt.expectedMin, tt.expectedMax, got)
+			}
+		})
+	}
+}
+
+func TestDeleteRetainedBlob(t *testing.T) {
+	retainUntil := time.Now().Add(time.Hour)
+	deletes := 0
+	mock := &mockS3Client{
+		headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{
+				ObjectLockMode:            types.ObjectLockModeCompliance,
+				ObjectLockRetainUntilDate: aws.Time(retainUntil),
+			}, nil
+		},
+		deleteObjectFunc: func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+			deletes++
+			return &s3.DeleteObjectOutput{}, nil
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.objectLockMode = ObjectLockCompliance
+	storage.objectLockDays = 30
+
+	err := storage.Delete(testBlobID("regulated content"))
+	if !errors.Is(err, ErrBlobRetained) {
+		t.Fatalf("expected ErrBlobRetained, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "COMPLIANCE object lock until") {
+		t.Errorf("expected the error to explain the retention, got %q", err.Error())
+	}
+	if deletes != 0 {
+		t.Errorf("expected no DeleteObject calls, got %d", deletes)
+	}
+
+	retainUntil = time.Now().Add(-time.Hour)
+	if err := storage.Delete(testBlobID("regulated content")); err != nil {
+		t.Fatalf("expected a blob past its retention to be deleted, got %v", err)
+	}
+	if deletes != 1 {
+		t.Errorf("expected 1 DeleteObject call, got %d", deletes)
+	}
+}
+
+func TestValidateObjectLock(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		expectError bool
+	}{
+		{name: "disabled", cfg: Config{}},
+		{name: "days", cfg: Config{ObjectLockMode: ObjectLockGovernance, ObjectLockDays: 7}},
+		{name: "fixed date", cfg: Config{ObjectLockMode: ObjectLockCompliance, ObjectLockRetainUntil: time.Now().AddDate(1, 0, 0)}},
+		{name: "invalid mode", cfg: Config{ObjectLockMode: "FOREVER", ObjectLockDays: 7}, expectError: true},
+		{name: "no retention", cfg: Config{ObjectLockMode: ObjectLockGovernance}, expectError: true},
+		{name: "both retentions", cfg: Config{ObjectLockMode: ObjectLockGovernance, ObjectLockDays: 7, ObjectLockRetainUntil: time.Now()}, expectError: true},
+		{name: "retention without mode", cfg: Config{ObjectLockDays: 7}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateObjectLock(tt.cfg)
+			if tt.expectError != (err != nil) {
+				t.Errorf("expected error=%v, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// defaultWorkers is the worker pool size for batch operations when
+// MaxConcurrentOps doesn't set one
+const defaultWorkers = 8
+
+// workers returns how many goroutines batch operations fan out to. With
+// MaxConcurrentOps set it matches the number of operation slots, since more
+// workers would only wait for one.
+func (s *S3BlobStorage) workers() int {
+	if s.opSlots != nil {
+		return cap(s.opSlots)
+	}
+	return defaultWorkers
+}
+
+// runParallel calls fn for each index in [0, n) on a pool of workers and
+// returns every error fn returned, joined in index order. Once ctx is done
+// it stops dispatching, and the indexes never run fail with ctx.Err().
+func (s *S3BlobStorage) runParallel(ctx context.Context, n int, fn func(i int) error) error {
+	errs := make([]error, n)
+	tasks := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(s.workers(), n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			for ; i < n; i++ {
+				errs[i] = err
+			}
+			break
+		}
+		tasks <- i
+	}
+	close(tasks)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
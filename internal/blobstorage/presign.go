@@ -0,0 +1,143 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// maxPresignExpiry is the longest validity SigV4 allows for a presigned URL
+const maxPresignExpiry = 7 * 24 * time.Hour
+
+// errPresignSSECustomer is returned when presigning with SSE-C, since the
+// URL's user would need the key to send with the request
+var errPresignSSECustomer = errors.New("presigned URLs are not supported with SSE-C")
+
+// PresignOptions configures a presigned download URL
+type PresignOptions struct {
+	// Expiry is how long the URL stays valid, up to 7 days
+	Expiry time.Duration
+	// ContentDisposition overrides the Content-Disposition S3 responds with,
+	// e.g. "inline" so a PDF opens in the browser or
+	// `attachment; filename="setup.exe"` to force a download. Empty keeps
+	// the object's own.
+	ContentDisposition string
+	// ContentType overrides the Content-Type S3 responds with. Empty keeps
+	// the object's own.
+	ContentType string
+}
+
+// PresignGetURL returns a URL that downloads the blob directly from S3 until
+// expiry elapses. The URL serves the stored bytes as is, so it is only useful
+// for blobs stored without client-side compression or encryption.
+func (s *S3BlobStorage) PresignGetURL(blobID string, expiry time.Duration) (_ string, err error) {
+	defer s.wrapError("PresignGetURL", blobID, &err)
+	return s.presignGet(blobID, PresignOptions{Expiry: expiry})
+}
+
+// PresignGetURLWithOptions returns a URL like PresignGetURL that also
+// controls how the browser handles the response. The overrides are part of
+// the signature, so they can't be altered without invalidating the URL.
+func (s *S3BlobStorage) PresignGetURLWithOptions(blobID string, opts PresignOptions) (_ string, err error) {
+	defer s.wrapError("PresignGetURLWithOptions", blobID, &err)
+	return s.presignGet(blobID, opts)
+}
+
+// presignGet presigns a GetObject request for blobID
+func (s *S3BlobStorage) presignGet(blobID string, opts PresignOptions) (string, error) {
+	if !s.enabled {
+		return "", ErrStorageDisabled
+	}
+
+	if err := s.validateBlobID(blobID); err != nil {
+		return "", err
+	}
+
+	if err := s.checkOpen(); err != nil {
+		return "", err
+	}
+
+	if err := validatePresignExpiry(opts.Expiry); err != nil {
+		return "", err
+	}
+
+	if s.sseC != nil {
+		return "", errPresignSSECustomer
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.blobKey(blobID)),
+	}
+	if opts.ContentDisposition != "" {
+		input.ResponseContentDisposition = aws.String(opts.ContentDisposition)
+	}
+	if opts.ContentType != "" {
+		input.ResponseContentType = aws.String(opts.ContentType)
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	req, err := s.presigner.PresignGetObject(ctx, input, s3.WithPresignExpires(opts.Expiry))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign blob download: %w", err)
+	}
+
+	return req.URL, nil
+}
+
+// PresignPutURL returns a URL that lets a client such as a browser upload
+// content directly to S3, along with the blob ID it will be stored under. The
+// client must PUT exactly the given content for the blob ID to be valid.
+// Direct uploads bypass client-side compression and encryption, so they are
+// rejected when either is configured.
+func (s *S3BlobStorage) PresignPutURL(content string, expiry time.Duration) (_, _ string, err error) {
+	defer s.wrapError("PresignPutURL", "", &err)
+	if !s.enabled {
+		return "", "", ErrStorageDisabled
+	}
+
+	if err := s.checkOpen(); err != nil {
+		return "", "", err
+	}
+
+	if err := validatePresignExpiry(expiry); err != nil {
+		return "", "", err
+	}
+
+	if s.encodesContent() {
+		return "", "", fmt.Errorf("presigned uploads are not supported with client-side compression or encryption")
+	}
+	if s.sseC != nil {
+		return "", "", errPresignSSECustomer
+	}
+
+	blobID := s.computeBlobID([]byte(content))
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	req, err := s.presigner.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.blobKey(blobID)),
+		ContentType: aws.String(defaultContentType),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to presign blob upload: %w", err)
+	}
+
+	return req.URL, blobID, nil
+}
+
+// validatePresignExpiry checks that expiry is within what SigV4 allows
+func validatePresignExpiry(expiry time.Duration) error {
+	if expiry <= 0 || expiry > maxPresignExpiry {
+		return fmt.Errorf("invalid presign expiry %s: must be between 0 and %s", expiry, maxPresignExpiry)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// mockPresigner is a mock implementation of S3Presigner for testing
+type mockPresigner struct {
+	presignGetObjectFunc func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+	presignPutObjectFunc func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+}
+
+func (m *mockPresigner) PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	if m.presignGetObjectFunc != nil {
+		return m.presignGetObjectFunc(ctx, params, optFns...)
+	}
+	return &v4.PresignedHTTPRequest{URL: "https://example.com/" + *params.Key}, nil
+}
+
+func (m *mockPresigner) PresignPutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	if m.presignPutObjectFunc != nil {
+		return m.presignPutObjectFunc(ctx, params, optFns...)
+	}
+	return &v4.PresignedHTTPRequest{URL: "https://example.com/" + *params.Key, Method: "PUT"}, nil
+}
+
+// presignExpiry applies presign options and returns the resulting expiry
+func presignExpiry(optFns []func(*s3.PresignOptions)) time.Duration {
+	var opts s3.PresignOptions
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+	return opts.Expires
+}
+
+func TestPresignGetURL(t *testing.T) {
+	testBlobID := testBlobID("presigned content")
+
+	tests := []struct {
+		name          string
+		enabled       bool
+		expiry        time.Duration
+		presigner     *mockPresigner
+		expectError   bool
+		errorIs       error
+		errorContains string
+	}{
+		{
+			name:        "disabled storage",
+			enabled:     false,
+			expiry:      time.Minute,
+			presigner:   &mockPresigner{},
+			expectError: true,
+			errorIs:     ErrStorageDisabled,
+		},
+		{
+			name:    "successful presign",
+			enabled: true,
+			expiry:  15 * time.Minute,
+			presigner: &mockPresigner{
+				presignGetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+					if *params.Key != "blobs/"+testBlobID {
+						t.Errorf("expected key=%q, got %q", "blobs/"+testBlobID, *params.Key)
+					}
+					if expiry := presignExpiry(optFns); expiry != 15*time.Minute {
+						t.Errorf("expected expiry=%s, got %s", 15*time.Minute, expiry)
+					}
+					return &v4.PresignedHTTPRequest{URL: "https://example.com/signed"}, nil
+				},
+			},
+		},
+		{
+			name:          "zero expiry",
+			enabled:       true,
+			expiry:        0,
+			presigner:     &mockPresigner{},
+			expectError:   true,
+			errorContains: "invalid presign expiry",
+		},
+		{
+			name:          "expiry beyond SigV4 limit",
+			enabled:       true,
+			expiry:        8 * 24 * time.Hour,
+			presigner:     &mockPresigner{},
+			expectError:   true,
+			errorContains: "invalid presign expiry",
+		},
+		{
+			name:    "presign error",
+			enabled: true,
+			expiry:  time.Minute,
+			presigner: &mockPresigner{
+				presignGetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+					return nil, errors.New("signing failed")
+				},
+			},
+			expectError:   true,
+			errorContains: "failed to presign blob download",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", tt.enabled)
+			storage.presigner = tt.presigner
+
+			url, err := storage.PresignGetURL(testBlobID, tt.expiry)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				} else if tt.errorIs != nil && !errors.Is(err, tt.errorIs) {
+					t.Errorf("expected error %v, got %v", tt.errorIs, err)
+				} else if tt.errorContains != "" && !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("expected error containing %q, got %q", tt.errorContains, err.Error())
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if url != "https://example.com/signed" {
+				t.Errorf("expected signed URL, got %q", url)
+			}
+		})
+	}
+}
+
+func TestPresignGetURLWithOptions(t *testing.T) {
+	blobID := testBlobID("quarterly report")
+
+	tests := []struct {
+		name                string
+		opts                PresignOptions
+		expectDisposition   string
+		expectContentType   string
+		expectQueryContains []string
+	}{
+		{
+			name:                "inline PDF",
+			opts:                PresignOptions{Expiry: time.Hour, ContentDisposition: "inline", ContentType: "application/pdf"},
+			expectDisposition:   "inline",
+			expectContentType:   "application/pdf",
+			expectQueryContains: []string{"response-content-disposition=inline", "response-content-type=application%2Fpdf"},
+		},
+		{
+			name:                "forced download",
+			opts:                PresignOptions{Expiry: time.Hour, ContentDisposition: `attachment; filename="setup.exe"`},
+			expectDisposition:   `attachment; filename="setup.exe"`,
+			expectQueryContains: []string{"response-content-disposition=attachment"},
+		},
+		{
+			name: "no overrides",
+			opts: PresignOptions{Expiry: time.Hour},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input *s3.GetObjectInput
+			storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+			storage.presigner = &mockPresigner{
+				presignGetObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+					input = params
+					return &v4.PresignedHTTPRequest{URL: "https://example.com/signed"}, nil
+				},
+			}
+
+			if _, err := storage.PresignGetURLWithOptions(blobID, tt.opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := aws.ToString(input.ResponseContentDisposition); got != tt.expectDisposition {
+				t.Errorf("expected ResponseContentDisposition=%q, got %q", tt.expectDisposition, got)
+			}
+			if got := aws.ToString(input.ResponseContentType); got != tt.expectContentType {
+				t.Errorf("expected ResponseContentType=%q, got %q", tt.expectContentType, got)
+			}
+
+			// The overrides are signed into the URL by the real presigner
+			storage.presigner = s3.NewPresignClient(s3.New(s3.Options{
+				Region:      "us-east-1",
+				Credentials: credentials.NewStaticCredentialsProvider("test-key", "test-secret", ""),
+			}))
+			url, err := storage.PresignGetURLWithOptions(blobID, tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, want := range tt.expectQueryContains {
+				if !strings.Contains(url, want) {
+					t.Errorf("expected URL to contain %q, got %q", want, url)
+				}
+			}
+			if len(tt.expectQueryContains) == 0 && strings.Contains(url, "response-content") {
+				t.Errorf("expected no response overrides, got %q", url)
+			}
+		})
+	}
+
+	t.Run("expiry is validated", func(t *testing.T) {
+		storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+		storage.presigner = &mockPresigner{}
+		if _, err := storage.PresignGetURLWithOptions(blobID, PresignOptions{ContentDisposition: "inline"}); err == nil || !strings.Contains(err.Error(), "invalid presign expiry") {
+			t.Errorf("expected an invalid expiry error, got %v", err)
+		}
+	})
+}
+
+func TestPresignPutURL(t *testing.T) {
+	content := "browser uploaded content"
+
+	t.Run("returns URL and blob ID", func(t *testing.T) {
+		storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+		storage.presigner = &mockPresigner{
+			presignPutObjectFunc: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+				if expiry := presignExpiry(optFns); expiry != time.Hour {
+					t.Errorf("expected expiry=%s, got %s", time.Hour, expiry)
+				}
+				return &v4.PresignedHTTPRequest{URL: "https://example.com/" + *params.Key, Method: "PUT"}, nil
+			},
+		}
+
+		url, blobID, err := storage.PresignPutURL(content, time.Hour)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if blobID != testBlobID(content) {
+			t.Errorf("expected blobID=%q, got %q", testBlobID(content), blobID)
+		}
+		if url != "https://example.com/blobs/"+blobID {
+			t.Errorf("expected URL for blob key, got %q", url)
+		}
+	})
+
+	t.Run("disabled storage", func(t *testing.T) {
+		storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", false)
+		if _, _, err := storage.PresignPutURL(content, time.Hour); !errors.Is(err, ErrStorageDisabled) {
+			t.Errorf("expected ErrStorageDisabled, got %v", err)
+		}
+	})
+
+	t.Run("rejected with client-side compression", func(t *testing.T) {
+		storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+		storage.presigner = &mockPresigner{}
+		storage.compression = CompressionGzip
+
+		if _, _, err := storage.PresignPutURL(content, time.Hour); err == nil {
+			t.Error("expected error but got none")
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package blobstorage
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Providers with presets for Config.Provider
+const (
+	ProviderAWS    = "aws"
+	ProviderMinIO  = "minio"
+	ProviderR2     = "r2"
+	ProviderSpaces = "spaces"
+)
+
+// validateProvider checks that provider is a supported preset and has the
+// endpoint every non-AWS provider needs
+func validateProvider(provider, endpoint string) error {
+	switch provider {
+	case "", ProviderAWS:
+		return nil
+	case ProviderMinIO, ProviderR2, ProviderSpaces:
+		if endpoint == "" {
+			return fmt.Errorf("provider %q requires an endpoint", provider)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid provider %q: must be %q, %q, %q or %q",
+			provider, ProviderAWS, ProviderMinIO, ProviderR2, ProviderSpaces)
+	}
+}
+
+// applyProviderDefaults fills in the settings cfg.Provider presets. Settings
+// made explicitly are kept; SkipBucketCreation, being a plain bool, can only
+// be turned on.
+func applyProviderDefaults(cfg *Config) {
+	switch cfg.Provider {
+	case ProviderMinIO:
+		if cfg.UsePathStyle == nil {
+			cfg.UsePathStyle = aws.Bool(true)
+		}
+	case ProviderR2:
+		if cfg.UsePathStyle == nil {
+			cfg.UsePathStyle = aws.Bool(true)
+		}
+		if cfg.Region == "" {
+			cfg.Region = "auto"
+		}
+		cfg.SkipBucketCreation = true
+	case ProviderSpaces:
+		if cfg.UsePathStyle == nil {
+			cfg.UsePathStyle = aws.Bool(false)
+		}
+	}
+}
+
+// applyProviderChecksums limits request checksums and response validation to
+// operations that require them for providers that reject the checksums the
+// SDK otherwise sends with every upload
+func applyProviderChecksums(provider string, o *s3.Options) {
+	switch provider {
+	case ProviderR2, ProviderSpaces:
+		o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+	}
+}
@@ -0,0 +1,73 @@
+package blobstorage
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestProviderDefaults(t *testing.T) {
+	tests := []struct {
+		name              string
+		config            Config
+		expectedPathStyle bool
+		expectedRegion    string
+		expectedSkip      bool
+		expectedChecksums aws.RequestChecksumCalculation
+	}{
+		{
+			name:              "aws",
+			config:            Config{Provider: ProviderAWS},
+			expectedChecksums: aws.RequestChecksumCalculationWhenSupported,
+		},
+		{
+			name:              "minio",
+			config:            Config{Provider: ProviderMinIO, Endpoint: "http://localhost:9000"},
+			expectedPathStyle: true,
+			expectedChecksums: aws.RequestChecksumCalculationWhenSupported,
+		},
+		{
+			name:              "r2",
+			config:            Config{Provider: ProviderR2, Endpoint: "https://account.r2.cloudflarestorage.com"},
+			expectedPathStyle: true,
+			expectedRegion:    "auto",
+			expectedSkip:      true,
+			expectedChecksums: aws.RequestChecksumCalculationWhenRequired,
+		},
+		{
+			name:              "spaces",
+			config:            Config{Provider: ProviderSpaces, Endpoint: "https://nyc3.digitaloceanspaces.com"},
+			expectedChecksums: aws.RequestChecksumCalculationWhenRequired,
+		},
+		{
+			name:              "explicit settings win",
+			config:            Config{Provider: ProviderR2, Endpoint: "https://account.r2.cloudflarestorage.com", Region: "wnam", UsePathStyle: aws.Bool(false)},
+			expectedRegion:    "wnam",
+			expectedSkip:      true,
+			expectedChecksums: aws.RequestChecksumCalculationWhenRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.config
+			applyProviderDefaults(&cfg)
+			if cfg.Region != tt.expectedRegion {
+				t.Errorf("expected Region=%q, got %q", tt.expectedRegion, cfg.Region)
+			}
+			if cfg.SkipBucketCreation != tt.expectedSkip {
+				t.Errorf("expected SkipBucketCreation=%v, got %v", tt.expectedSkip, cfg.SkipBucketCreation)
+			}
+
+			o := s3.Options{RequestChecksumCalculation: aws.RequestChecksumCalculationWhenSupported}
+			s3ClientOptions(cfg)(&o)
+			if o.UsePathStyle != tt.expectedPathStyle {
+				t.Errorf("expected UsePathStyle=%v, got %v", tt.expectedPathStyle, o.UsePathStyle)
+			}
+			if o.RequestChecksumCalculation != tt.expectedChecksums {
+				t.Errorf("expected RequestChecksumCalculation=%v, got %v", tt.expectedChecksums, o.RequestChecksumCalculation)
+			}
+		})
+	}
+}
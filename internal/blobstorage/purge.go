@@ -0,0 +1,65 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrRootPurge is returned by Purge when no KeyPrefix is configured and
+// AllowRootPurge is not set, since purging would empty the whole bucket
+var ErrRootPurge = errors.New("refusing to purge the whole bucket without a key prefix")
+
+// Purge deletes every object under the configured KeyPrefix, e.g. to
+// offboard a tenant or tear down a test, and returns how many were removed.
+// Keys are listed page by page and deleted with DeleteObjects in batches of
+// up to 1000, so reference counts and other sidecar objects under the
+// prefix go too. Without a KeyPrefix it fails with ErrRootPurge unless
+// AllowRootPurge is set. Purging stops at the first batch that fails to
+// delete, returning the error along with the count removed so far.
+func (s *S3BlobStorage) Purge(ctx context.Context) (_ int, err error) {
+	defer s.wrapError("Purge", "", &err)
+	if !s.enabled {
+		return 0, ErrStorageDisabled
+	}
+
+	if s.keyPrefix == "" && !s.allowRootPurge {
+		return 0, ErrRootPurge
+	}
+
+	s.dedupCache.clear()
+
+	purged := 0
+	batch := make([]string, 0, maxDeleteObjects)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if s.dryRun {
+			s.logger.Debugf("blobstorage: dry run, would purge %d objects under %q", len(batch), s.keyPrefix)
+			purged += len(batch)
+		} else {
+			deleted, err := s.deleteKeys(ctx, batch)
+			purged += deleted
+			if err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return ctx.Err()
+	}
+
+	err = s.listObjects(ctx, s.keyPrefix, func(obj types.Object) error {
+		batch = append(batch, aws.ToString(obj.Key))
+		if len(batch) < maxDeleteObjects {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	return purged, err
+}
@@ -0,0 +1,177 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// keyspaceMock serves ListObjectsV2 and DeleteObjects over keys, listing
+// pageSize keys at a time with the last key listed as the continuation token
+// so deletes between pages behave as they do on S3
+func keyspaceMock(keys map[string]bool, pageSize int, batchSizes *[]int) *mockS3Client {
+	return &mockS3Client{
+		listObjectsFunc: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			var matching []string
+			for key := range keys {
+				if strings.HasPrefix(key, aws.ToString(params.Prefix)) && key > aws.ToString(params.ContinuationToken) {
+					matching = append(matching, key)
+				}
+			}
+			sort.Strings(matching)
+
+			out := &s3.ListObjectsV2Output{}
+			for _, key := range matching[:min(pageSize, len(matching))] {
+				out.Contents = append(out.Contents, types.Object{Key: aws.String(key)})
+			}
+			if len(matching) > pageSize {
+				out.IsTruncated = aws.Bool(true)
+				out.NextContinuationToken = aws.String(matching[pageSize-1])
+			}
+			return out, nil
+		},
+		deleteObjectsFunc: func(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+			*batchSizes = append(*batchSizes, len(params.Delete.Objects))
+			for _, obj := range params.Delete.Objects {
+				delete(keys, aws.ToString(obj.Key))
+			}
+			return &s3.DeleteObjectsOutput{}, nil
+		},
+	}
+}
+
+func TestPurge(t *testing.T) {
+	keys := make(map[string]bool)
+	for _, id := range testBlobIDs(2400) {
+		keys["tenant-a/blobs/"+id] = true
+	}
+	for _, id := range testBlobIDs(100) {
+		keys["tenant-a/refs/"+id] = true
+		keys["tenant-b/blobs/"+id] = true
+	}
+
+	var batchSizes []int
+	storage := newMockS3BlobStorage(keyspaceMock(keys, 700, &batchSizes), "test-bucket", true)
+	storage.keyPrefix = "tenant-a/"
+
+	purged, err := storage.Purge(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if purged != 2500 {
+		t.Errorf("expected 2500 objects purged, got %d", purged)
+	}
+	if fmt.Sprint(batchSizes) != "[1000 1000 500]" {
+		t.Errorf("expected batches of 1000, 1000 and 500, got %v", batchSizes)
+	}
+	if len(keys) != 100 {
+		t.Errorf("expected only tenant-b's 100 objects to remain, got %d", len(keys))
+	}
+	for key := range keys {
+		if !strings.HasPrefix(key, "tenant-b/") {
+			t.Errorf("expected %s to be purged", key)
+		}
+	}
+}
+
+func TestPurgeRootPrefix(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowRootPurge bool
+		expectErr      error
+		expectPurged   int
+	}{
+		{name: "refused by default", expectErr: ErrRootPurge},
+		{name: "allowed explicitly", allowRootPurge: true, expectPurged: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := make(map[string]bool)
+			for _, id := range testBlobIDs(10) {
+				keys["blobs/"+id] = true
+			}
+
+			var batchSizes []int
+			storage := newMockS3BlobStorage(keyspaceMock(keys, 1000, &batchSizes), "test-bucket", true)
+			storage.allowRootPurge = tt.allowRootPurge
+
+			purged, err := storage.Purge(context.Background())
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+			if purged != tt.expectPurged {
+				t.Errorf("expected %d objects purged, got %d", tt.expectPurged, purged)
+			}
+			if len(keys) != 10-tt.expectPurged {
+				t.Errorf("expected %d objects to remain, got %d", 10-tt.expectPurged, len(keys))
+			}
+		})
+	}
+}
+
+func TestPurgeReportsFailedKeys(t *testing.T) {
+	keys := make(map[string]bool)
+	ids := testBlobIDs(5)
+	for _, id := range ids {
+		keys["tenant/blobs/"+id] = true
+	}
+
+	var batchSizes []int
+	mock := keyspaceMock(keys, 1000, &batchSizes)
+	deleteObjects := mock.deleteObjectsFunc
+	mock.deleteObjectsFunc = func(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+		if _, err := deleteObjects(ctx, params, optFns...); err != nil {
+			return nil, err
+		}
+		locked := "tenant/blobs/" + ids[0]
+		keys[locked] = true
+		return &s3.DeleteObjectsOutput{Errors: []types.Error{{Key: aws.String(locked), Code: aws.String("AccessDenied"), Message: aws.String("Access Denied")}}}, nil
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.keyPrefix = "tenant/"
+
+	purged, err := storage.Purge(context.Background())
+	if err == nil || !strings.Contains(err.Error(), ids[0]) {
+		t.Errorf("expected an error naming %s, got %v", ids[0], err)
+	}
+	if purged != 4 {
+		t.Errorf("expected 4 objects purged, got %d", purged)
+	}
+}
+
+func TestKeyPrefix(t *testing.T) {
+	content := "tenant content"
+
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.keyPrefix = normalizeKeyPrefix("tenant-a")
+
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected store error: %v", err)
+	}
+	if objects["tenant-a/blobs/"+blobID] == nil {
+		t.Errorf("expected blob under tenant-a/blobs/, got keys %v", objectKeys(objects))
+	}
+	if got := storage.refKey(blobID); got != "tenant-a/refs/"+blobID {
+		t.Errorf("expected ref key under tenant-a/refs/, got %q", got)
+	}
+}
+
+// objectKeys returns the keys of objects, for failure messages
+func objectKeys(objects map[string]*storedObject) []string {
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
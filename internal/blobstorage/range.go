@@ -0,0 +1,80 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// RetrieveRange returns a reader over bytes start through end (inclusive) of
+// a blob, e.g. to serve HTTP Range requests. Ranges address the stored bytes,
+// so they are only supported for blobs stored without compression or
+// encryption. A range starting past the end of the blob fails with
+// *ErrRangeNotSatisfiable. The caller must close the reader.
+func (s *S3BlobStorage) RetrieveRange(ctx context.Context, blobID string, start, end int64) (_ io.ReadCloser, err error) {
+	defer s.wrapError("RetrieveRange", blobID, &err)
+	if !s.enabled {
+		return nil, ErrStorageDisabled
+	}
+
+	if err := s.validateBlobID(blobID); err != nil {
+		return nil, err
+	}
+
+	if start < 0 || start > end {
+		return nil, fmt.Errorf("invalid range %d-%d", start, end)
+	}
+	if s.aead != nil {
+		return nil, fmt.Errorf("range reads are not supported with client-side encryption")
+	}
+
+	ctx, done, err := s.startRead(ctx, "RetrieveRange", false)
+	if err != nil {
+		return nil, err
+	}
+
+	input := s.getObjectInput(s.bucket, s.blobKey(blobID))
+	input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", start, end))
+	result, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		done()
+		if isNotFound(err) {
+			return nil, fmt.Errorf("failed to retrieve blob range: %w: %w", ErrBlobNotFound, err)
+		}
+		if isRangeNotSatisfiable(err) {
+			return nil, &ErrRangeNotSatisfiable{Start: start, End: end}
+		}
+		return nil, fmt.Errorf("failed to retrieve blob range: %w", err)
+	}
+
+	if s.isEncrypted(result.Metadata) {
+		s.closeBody(result.Body, s.blobKey(blobID))
+		done()
+		return nil, fmt.Errorf("range reads are not supported for encrypted blob %s", blobID)
+	}
+	if result.Metadata[metaEncoding] == CompressionGzip {
+		s.closeBody(result.Body, s.blobKey(blobID))
+		done()
+		return nil, fmt.Errorf("range reads are not supported for compressed blob %s", blobID)
+	}
+
+	return &blobReader{r: &contextReader{ctx: ctx, r: result.Body}, body: result.Body, done: done}, nil
+}
+
+// isRangeNotSatisfiable reports whether err is S3's 416 response to a range
+// that starts past the end of the object
+func isRangeNotSatisfiable(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange" {
+		return true
+	}
+
+	var respErr *smithyhttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusRequestedRangeNotSatisfiable
+}
@@ -0,0 +1,149 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+func TestRetrieveRange(t *testing.T) {
+	content := "0123456789abcdef"
+
+	tests := []struct {
+		name          string
+		start, end    int64
+		compress      bool
+		expected      string
+		errorIs       error
+		errorAs       bool
+		errorContains string
+	}{
+		{name: "middle of blob", start: 4, end: 9, expected: "456789"},
+		{name: "single byte", start: 0, end: 0, expected: "0"},
+		{name: "end past blob is truncated", start: 10, end: 100, expected: "abcdef"},
+		{name: "start past blob", start: 16, end: 20, errorAs: true},
+		{name: "start after end", start: 5, end: 4, errorContains: "invalid range"},
+		{name: "negative start", start: -1, end: 4, errorContains: "invalid range"},
+		{name: "compressed blob", start: 0, end: 4, compress: true, errorContains: "not supported for compressed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, _ := newBucketMock()
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			if tt.compress {
+				storage.compression = CompressionGzip
+			}
+			storage.opSlots = make(chan struct{}, 1)
+
+			blobID, err := storage.Store(content)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			reader, err := storage.RetrieveRange(context.Background(), blobID, tt.start, tt.end)
+			if len(storage.opSlots) != 0 && err != nil {
+				t.Error("expected a failed range read to release its slot")
+			}
+
+			if tt.errorAs || tt.errorContains != "" {
+				var rangeErr *ErrRangeNotSatisfiable
+				switch {
+				case err == nil:
+					t.Errorf("expected error but got none")
+				case tt.errorAs && !errors.As(err, &rangeErr):
+					t.Errorf("expected *ErrRangeNotSatisfiable, got %v", err)
+				case tt.errorContains != "" && !strings.Contains(err.Error(), tt.errorContains):
+					t.Errorf("expected error containing %q, got %q", tt.errorContains, err.Error())
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			data, err := io.ReadAll(reader)
+			_ = reader.Close()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, string(data))
+			}
+		})
+	}
+}
+
+func TestRetrieveRangeRequestAndErrors(t *testing.T) {
+	t.Run("sends range header", func(t *testing.T) {
+		var rangeHeader string
+		mock := &mockS3Client{
+			getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				rangeHeader = aws.ToString(params.Range)
+				return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(""))}, nil
+			},
+		}
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+		reader, err := storage.RetrieveRange(context.Background(), testBlobID("content"), 100, 199)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = reader.Close()
+
+		if rangeHeader != "bytes=100-199" {
+			t.Errorf("expected Range=%q, got %q", "bytes=100-199", rangeHeader)
+		}
+	})
+
+	t.Run("416 response", func(t *testing.T) {
+		mock := &mockS3Client{
+			getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return nil, &smithyhttp.ResponseError{
+					Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusRequestedRangeNotSatisfiable}},
+					Err:      errors.New("range not satisfiable"),
+				}
+			},
+		}
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+		_, err := storage.RetrieveRange(context.Background(), testBlobID("content"), 100, 199)
+		var rangeErr *ErrRangeNotSatisfiable
+		if !errors.As(err, &rangeErr) || rangeErr.Start != 100 || rangeErr.End != 199 {
+			t.Errorf("expected *ErrRangeNotSatisfiable for 100-199, got %v", err)
+		}
+	})
+
+	t.Run("missing blob", func(t *testing.T) {
+		mock, _ := newBucketMock()
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+		if _, err := storage.RetrieveRange(context.Background(), testBlobID("missing"), 0, 1); !errors.Is(err, ErrBlobNotFound) {
+			t.Errorf("expected ErrBlobNotFound, got %v", err)
+		}
+	})
+
+	t.Run("encrypted storage", func(t *testing.T) {
+		storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+		storage.aead, _ = newBlobCipher(testEncryptionKey(1))
+
+		if _, err := storage.RetrieveRange(context.Background(), testBlobID("content"), 0, 1); err == nil || !strings.Contains(err.Error(), "encryption") {
+			t.Errorf("expected encryption error, got %v", err)
+		}
+	})
+
+	t.Run("disabled storage", func(t *testing.T) {
+		storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", false)
+
+		if _, err := storage.RetrieveRange(context.Background(), testBlobID("content"), 0, 1); !errors.Is(err, ErrStorageDisabled) {
+			t.Errorf("expected ErrStorageDisabled, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package blobstorage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimiter throttles storage operations, e.g. so a gateway can cap each
+// tenant's request rate. Wait is called once per operation, however many S3
+// requests it makes, with the operation's name (e.g. "Store"); batch
+// operations call it for each blob or batch request. It must block until the
+// operation may proceed, returning an error if ctx is done first. The tenant
+// the operation is for is available from TenantIDFromContext(ctx).
+type RateLimiter interface {
+	Wait(ctx context.Context, op string) error
+}
+
+// NoopRateLimiter is the default RateLimiter, never waiting
+type NoopRateLimiter struct{}
+
+// Wait returns immediately
+func (NoopRateLimiter) Wait(context.Context, string) error { return nil }
+
+// tenantIDKey is the context key for WithTenantID
+type tenantIDKey struct{}
+
+// WithTenantID returns a copy of ctx that attributes requests made with it
+// to tenantID, overriding Config.TenantID for the RateLimiter. It lets one
+// storage shared by many tenants be limited per tenant through the methods
+// that take a context.
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantIDKey{}, tenantID)
+}
+
+// TenantIDFromContext returns the tenant requests made with ctx are for: the
+// ID set with WithTenantID, or else Config.TenantID
+func TenantIDFromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantIDKey{}).(string)
+	return tenantID
+}
+
+// TokenBucketLimiter is a RateLimiter giving each tenant its own token
+// bucket, refilled at rate requests per second up to burst. Every operation
+// costs one token. Buckets idle long enough to have refilled completely are
+// dropped, so memory is bounded by the tenants active recently rather than
+// every tenant seen.
+type TokenBucketLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+var _ RateLimiter = (*TokenBucketLimiter)(nil)
+
+// tokenBucket is one tenant's tokens as of last
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucketLimiter creates a TokenBucketLimiter allowing each tenant
+// rate requests per second on average and up to burst at once
+func NewTokenBucketLimiter(rate float64, burst int) (*TokenBucketLimiter, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("invalid rate %v: must be positive", rate)
+	}
+	if burst < 1 {
+		return nil, fmt.Errorf("invalid burst %d: must be at least 1", burst)
+	}
+
+	return &TokenBucketLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}, nil
+}
+
+// Wait takes a token from the bucket of ctx's tenant, waiting for one to
+// be refilled if it is empty
+func (l *TokenBucketLimiter) Wait(ctx context.Context, op string) error {
+	tenantID := TenantIDFromContext(ctx)
+
+	for {
+		delay, ok := l.take(tenantID)
+		if ok {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("failed to wait for rate limit on %s: %w", op, ctx.Err())
+		}
+	}
+}
+
+// take takes a token from tenantID's bucket, or if it is empty reports how
+// long until a token is refilled
+func (l *TokenBucketLimiter) take(tenantID string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[tenantID]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[tenantID] = b
+	}
+
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), false
+}
+
+// sweep drops buckets that have been idle long enough to refill completely,
+// since a new bucket would be identical. It scans at most once per refill
+// period so the cost is spread across calls.
+func (l *TokenBucketLimiter) sweep(now time.Time) {
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.lastSweep) < refill {
+		return
+	}
+	l.lastSweep = now
+
+	for tenantID, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, tenantID)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingLimiter records each call to Wait
+type recordingLimiter struct {
+	mu    sync.Mutex
+	calls []string
+	err   error
+}
+
+func (l *recordingLimiter) Wait(ctx context.Context, op string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, TenantIDFromContext(ctx)+" "+op)
+	return l.err
+}
+
+func TestRateLimiterCalls(t *testing.T) {
+	mock, _ := newBucketMock()
+	limiter := &recordingLimiter{}
+	metrics := &recordingMetrics{}
+	client := &instrumentedClient{next: mock, metrics: metrics}
+	storage := newMockS3BlobStorage(client, "test-bucket", true)
+	storage.limiter = limiter
+	storage.tenantID = "acme"
+
+	// A store makes a HeadObject and a PutObject but waits once
+	blobID, err := storage.Store("rate limited content")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := storage.ExistsBatch(WithTenantID(context.Background(), "globex"), []string{blobID, testBlobID("other")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"acme Store", "globex ExistsBatch", "globex ExistsBatch"}
+	if len(limiter.calls) != len(expected) {
+		t.Fatalf("expected calls %v, got %v", expected, limiter.calls)
+	}
+	for i := range expected {
+		if limiter.calls[i] != expected[i] {
+			t.Errorf("call %d: expected %q, got %q", i, expected[i], limiter.calls[i])
+		}
+	}
+
+	// A refused request never reaches S3 or the metrics
+	limiter.err = errors.New("rate limited")
+	observed := len(metrics.observations)
+	if _, err := storage.Retrieve(blobID); !errors.Is(err, limiter.err) {
+		t.Errorf("expected the limiter's error, got %v", err)
+	}
+	if len(metrics.observations) != observed {
+		t.Errorf("expected no observation of a refused request")
+	}
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	limiter, err := NewTokenBucketLimiter(10, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	acme := WithTenantID(context.Background(), "acme")
+	globex := WithTenantID(context.Background(), "globex")
+
+	take := func(ctx context.Context) bool {
+		_, ok := limiter.take(TenantIDFromContext(ctx))
+		return ok
+	}
+
+	if !take(acme) || !take(acme) {
+		t.Fatal("expected the burst to be allowed")
+	}
+	if take(acme) {
+		t.Error("expected the empty bucket to refuse")
+	}
+	if !take(globex) {
+		t.Error("expected another tenant's bucket to be independent")
+	}
+
+	if delay, _ := limiter.take("acme"); delay != 100*time.Millisecond {
+		t.Errorf("expected a 100ms wait, got %v", delay)
+	}
+	now = now.Add(100 * time.Millisecond)
+	if !take(acme) {
+		t.Error("expected a refilled token to be allowed")
+	}
+
+	// Drain globex's refilled bucket, then give up waiting for more
+	for range 2 {
+		if err := limiter.Wait(globex, "PutObject"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(globex)
+	cancel()
+	if err := limiter.Wait(ctx, "PutObject"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestNewTokenBucketLimiterInvalid(t *testing.T) {
+	if _, err := NewTokenBucketLimiter(0, 1); err == nil {
+		t.Error("expected an error for a zero rate")
+	}
+	if _, err := NewTokenBucketLimiter(1, 0); err == nil {
+		t.Error("expected an error for a zero burst")
+	}
+}
+
+func TestTokenBucketLimiterEvictsIdleBuckets(t *testing.T) {
+	limiter, err := NewTokenBucketLimiter(10, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	for i := range 100 {
+		limiter.take(fmt.Sprintf("tenant-%d", i))
+	}
+	limiter.take("tenant-0")
+	if len(limiter.buckets) != 100 {
+		t.Fatalf("expected 100 buckets, got %d", len(limiter.buckets))
+	}
+
+	// Buckets of 2 refill at 10/s within 200ms; tenant-0 is still in debt
+	now = now.Add(150 * time.Millisecond)
+	limiter.take("tenant-0")
+	now = now.Add(100 * time.Millisecond)
+	limiter.take("acme")
+
+	if len(limiter.buckets) != 2 {
+		t.Errorf("expected only tenant-0 and acme to keep buckets, got %d", len(limiter.buckets))
+	}
+	if _, ok := limiter.buckets["tenant-0"]; !ok {
+		t.Error("expected the bucket still refilling to be kept")
+	}
+	if _, ok := limiter.take("tenant-5"); !ok {
+		t.Error("expected an evicted tenant to start with a full bucket")
+	}
+}
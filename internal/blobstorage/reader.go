@@ -0,0 +1,131 @@
+package blobstorage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// RetrieveReader returns a reader over a blob's content that stops with the
+// context's error once ctx is canceled or the operation times out. Blobs
+// that are only compressed are decompressed as they are read; encrypted
+// blobs, and all blobs when VerifyOnRetrieve is set, are read in full first.
+// A download that fails mid-stream is resumed from where it stopped, up to
+// ResumeRetries times. The caller must close the reader.
+func (s *S3BlobStorage) RetrieveReader(ctx context.Context, blobID string) (_ io.ReadCloser, err error) {
+	defer s.wrapError("RetrieveReader", blobID, &err)
+	if !s.enabled {
+		return nil, ErrStorageDisabled
+	}
+
+	return s.retrieveReader(ctx, blobID)
+}
+
+// retrieveReader is RetrieveReader without the Event, for operations built
+// on it
+func (s *S3BlobStorage) retrieveReader(ctx context.Context, blobID string) (io.ReadCloser, error) {
+	if err := s.validateBlobID(blobID); err != nil {
+		return nil, err
+	}
+
+	ctx, done, err := s.startRead(ctx, "RetrieveReader", true)
+	if err != nil {
+		return nil, err
+	}
+
+	result, loc, err := s.getObject(ctx, blobID)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	result.Body = s.resumableBody(ctx, blobID, loc, result)
+
+	if s.isEncrypted(result.Metadata) || s.verifyOnRetrieve {
+		defer done()
+		defer s.closeBody(result.Body, s.blobKey(blobID))
+
+		data, err := s.readBlob(ctx, blobID, result)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	var r io.Reader = &contextReader{ctx: ctx, r: result.Body}
+	if result.Metadata[metaEncoding] == CompressionGzip {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			s.closeBody(result.Body, s.blobKey(blobID))
+			done()
+			return nil, fmt.Errorf("failed to decompress blob: %w", err)
+		}
+		r = zr
+	}
+
+	return &blobReader{r: r, body: result.Body, done: done}, nil
+}
+
+// startRead applies the operation timeout and takes an operation slot for
+// op, a download whose body outlives the call, with acquireReadOp when
+// failover is set because the download goes through getObject. The returned
+// func releases both and is safe to call more than once, since readers may
+// be closed repeatedly.
+func (s *S3BlobStorage) startRead(ctx context.Context, op string, failover bool) (context.Context, func(), error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opTimeout(s.downloadTimeout))
+
+	acquire := s.acquireOp
+	if failover {
+		acquire = s.acquireReadOp
+	}
+	if err := acquire(ctx, op); err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	// The body outlives s.ctx's derived contexts, so tie it to Close explicitly
+	stop := context.AfterFunc(s.ctx, cancel)
+
+	var once sync.Once
+	done := func() {
+		once.Do(func() {
+			stop()
+			s.releaseOp()
+			cancel()
+		})
+	}
+	return ctx, done, nil
+}
+
+// contextReader fails reads with the context's error once ctx is done, so
+// that long downloads stop promptly when their caller gives up
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// blobReader is returned by RetrieveReader, releasing the response body,
+// operation slot and context when closed
+type blobReader struct {
+	r    io.Reader
+	body io.Closer
+	done func()
+}
+
+func (b *blobReader) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+func (b *blobReader) Close() error {
+	defer b.done()
+	return b.body.Close()
+}
@@ -0,0 +1,150 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// slowBody is a response body that returns a few bytes per read, calling
+// onRead after each one so tests can act partway through a download
+type slowBody struct {
+	data   []byte
+	onRead func(reads int)
+	reads  int
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	if len(b.data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p[:min(len(p), 4)], b.data)
+	b.data = b.data[n:]
+	b.reads++
+	if b.onRead != nil {
+		b.onRead(b.reads)
+	}
+	return n, nil
+}
+
+func (b *slowBody) Close() error {
+	return nil
+}
+
+// slowGetMock returns a mock serving content through a slowBody that cancels
+// the context after the given number of reads
+func slowGetMock(content string, cancelAfter int, cancel context.CancelFunc) *mockS3Client {
+	return &mockS3Client{
+		getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{
+				Body: &slowBody{
+					data: []byte(content),
+					onRead: func(reads int) {
+						if reads == cancelAfter {
+							cancel()
+						}
+					},
+				},
+			}, nil
+		},
+	}
+}
+
+func TestRetrieveCanceledMidStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := newMockS3BlobStorage(slowGetMock(strings.Repeat("x", 64), 2, cancel), "test-bucket", true)
+	storage.ctx = ctx
+
+	_, err := storage.Retrieve(testBlobID("unused"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRetrieveReaderCanceledMidStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := newMockS3BlobStorage(slowGetMock(strings.Repeat("x", 64), 2, cancel), "test-bucket", true)
+
+	reader, err := storage.RetrieveReader(ctx, testBlobID("unused"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	data, err := io.ReadAll(reader)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if len(data) >= 64 {
+		t.Errorf("expected the read to stop early, got all %d bytes", len(data))
+	}
+}
+
+func TestRetrieveReader(t *testing.T) {
+	content := strings.Repeat("attachment content ", 20)
+
+	tests := []struct {
+		name  string
+		setup func(*S3BlobStorage)
+	}{
+		{name: "plain", setup: func(s *S3BlobStorage) {}},
+		{name: "gzip", setup: func(s *S3BlobStorage) { s.compression = CompressionGzip }},
+		{name: "encrypted", setup: func(s *S3BlobStorage) { s.aead, _ = newBlobCipher(testEncryptionKey(1)) }},
+		{name: "verified", setup: func(s *S3BlobStorage) { s.verifyOnRetrieve = true }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, _ := newBucketMock()
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			tt.setup(storage)
+
+			blobID, err := storage.Store(content)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			reader, err := storage.RetrieveReader(context.Background(), blobID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			data, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := reader.Close(); err != nil {
+				t.Errorf("unexpected close error: %v", err)
+			}
+			if string(data) != content {
+				t.Errorf("expected content=%q, got %q", content, string(data))
+			}
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		mock, _ := newBucketMock()
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+		if _, err := storage.RetrieveReader(context.Background(), testBlobID("missing")); !errors.Is(err, ErrBlobNotFound) {
+			t.Errorf("expected ErrBlobNotFound, got %v", err)
+		}
+	})
+
+	t.Run("disabled storage", func(t *testing.T) {
+		storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", false)
+
+		if _, err := storage.RetrieveReader(context.Background(), testBlobID("missing")); !errors.Is(err, ErrStorageDisabled) {
+			t.Errorf("expected ErrStorageDisabled, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,219 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// refKeyPrefix is the key prefix of the sidecar objects holding reference counts
+const refKeyPrefix = "refs/"
+
+// maxReferenceUpdateAttempts bounds the retries of a conditional reference
+// count update that lost a race with a concurrent update
+const maxReferenceUpdateAttempts = 5
+
+// Reference counting
+//
+// When ReferenceCounting is enabled every Store adds a reference to the blob
+// and Delete removes one, deleting the blob only when the last reference is
+// gone. Counts live in a sidecar object (refs/<blobID>) that is updated with
+// conditional writes: each update reads the sidecar and its ETag and writes
+// the new count with If-Match (or If-None-Match when creating it), retrying
+// when another writer got there first. The backend must support conditional
+// writes for the counts to be reliable.
+//
+// Store adds its reference before checking for and uploading content, so a
+// concurrent Delete cannot take the count to zero underneath it. One window
+// remains: if the last reference is removed just before a Store of the same
+// content adds a new one, the Store may see the blob still present and skip
+// the upload while the Delete goes on to remove it. Callers that can race a
+// final Delete with a Store of identical content should verify the blob with
+// Exists afterwards. A failed Store leaves its reference in place, which errs
+// on the side of keeping content.
+
+// AddReference records an additional reference to a blob and returns the new
+// reference count
+func (s *S3BlobStorage) AddReference(blobID string) (_ int64, err error) {
+	defer s.wrapError("AddReference", blobID, &err)
+	if !s.enabled {
+		return 0, ErrStorageDisabled
+	}
+
+	if err := s.validateBlobID(blobID); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "AddReference"); err != nil {
+		return 0, err
+	}
+	defer s.releaseOp()
+
+	count, _, err := s.updateReferenceCount(ctx, blobID, 1)
+	return count, err
+}
+
+// RemoveReference drops a reference to a blob and returns the remaining
+// reference count. The blob is deleted once no references remain. Blobs
+// without a reference count (e.g. stored before reference counting was
+// enabled) are treated as having a single reference.
+func (s *S3BlobStorage) RemoveReference(blobID string) (_ int64, err error) {
+	defer s.wrapError("RemoveReference", blobID, &err)
+	if !s.enabled {
+		return 0, ErrStorageDisabled
+	}
+
+	return s.removeReference(s.ctx, blobID)
+}
+
+// removeReference is RemoveReference without the Event, for Delete. The
+// operation's timeout is applied to ctx.
+func (s *S3BlobStorage) removeReference(ctx context.Context, blobID string) (int64, error) {
+	if err := s.validateBlobID(blobID); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "RemoveReference"); err != nil {
+		return 0, err
+	}
+	defer s.releaseOp()
+
+	count, etag, err := s.updateReferenceCount(ctx, blobID, -1)
+	if err != nil || count > 0 {
+		return count, err
+	}
+
+	// Writing the zero count claimed the deletion, so only one caller gets here
+	if s.objectLockMode != "" {
+		if err := s.checkRetention(ctx, blobID, s.blobKey(blobID)); err != nil {
+			return 0, err
+		}
+	}
+	s.dedupCache.remove(blobID)
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.blobKey(blobID)),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete blob: %w", err)
+	}
+
+	// Remove the sidecar unless a new reference has been added meanwhile
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:  aws.String(s.bucket),
+		Key:     aws.String(s.refKey(blobID)),
+		IfMatch: etag,
+	})
+	if err != nil && !isConditionalWriteConflict(err) {
+		return 0, fmt.Errorf("failed to delete reference count: %w", err)
+	}
+
+	return 0, nil
+}
+
+// refKey returns the sidecar object key holding a blob's reference count
+func (s *S3BlobStorage) refKey(blobID string) string {
+	return s.keyPrefix + refKeyPrefix + blobID
+}
+
+// updateReferenceCount atomically adds delta to a blob's reference count,
+// never going below zero. It returns the new count and the sidecar's ETag.
+func (s *S3BlobStorage) updateReferenceCount(ctx context.Context, blobID string, delta int64) (int64, *string, error) {
+	key := s.refKey(blobID)
+
+	for attempt := 0; attempt < maxReferenceUpdateAttempts; attempt++ {
+		count, etag, err := s.readReferenceCount(ctx, key)
+		if err != nil {
+			return 0, nil, err
+		}
+		if etag == nil && delta < 0 {
+			// Untracked blob, treat it as having a single reference
+			count = 1
+		}
+
+		count += delta
+		if count < 0 {
+			count = 0
+		}
+
+		sse, kmsKeyID := s.serverSideEncryption()
+		input := &s3.PutObjectInput{
+			Bucket:               aws.String(s.bucket),
+			Key:                  aws.String(key),
+			Body:                 strings.NewReader(strconv.FormatInt(count, 10)),
+			ContentType:          aws.String("text/plain"),
+			ServerSideEncryption: sse,
+			SSEKMSKeyId:          kmsKeyID,
+		}
+		if etag == nil {
+			input.IfNoneMatch = aws.String("*")
+		} else {
+			input.IfMatch = etag
+		}
+
+		out, err := s.client.PutObject(ctx, input)
+		if err == nil {
+			return count, out.ETag, nil
+		}
+		if !isConditionalWriteConflict(err) {
+			return 0, nil, fmt.Errorf("failed to update reference count: %w", err)
+		}
+	}
+
+	return 0, nil, fmt.Errorf("failed to update reference count for blob %s: too many concurrent updates", blobID)
+}
+
+// readReferenceCount reads a reference count sidecar, returning a nil ETag
+// when it doesn't exist
+func (s *S3BlobStorage) readReferenceCount(ctx context.Context, key string) (int64, *string, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return 0, nil, nil
+		}
+		return 0, nil, fmt.Errorf("failed to read reference count: %w", err)
+	}
+	defer s.closeBody(result.Body, key)
+
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read reference count: %w", err)
+	}
+
+	count, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid reference count %q: %w", data, err)
+	}
+
+	return count, result.ETag, nil
+}
+
+// isConditionalWriteConflict reports whether a conditional request failed
+// because the object changed (412) or a concurrent write won (409)
+func isConditionalWriteConflict(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "PreconditionFailed", "ConditionalRequestConflict":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+func newRefCountingStorage() (*S3BlobStorage, map[string]*storedObject, *mockS3Client) {
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.referenceCounting = true
+	return storage, objects, mock
+}
+
+func TestReferenceCountedDelete(t *testing.T) {
+	storage, objects, _ := newRefCountingStorage()
+	content := "attachment shared by two messages"
+
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := storage.Store(content); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := string(objects["refs/"+blobID].body); got != "2" {
+		t.Errorf("expected reference count 2, got %q", got)
+	}
+
+	// First delete only drops a reference
+	if err := storage.Delete(blobID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := objects["blobs/"+blobID]; !ok {
+		t.Fatal("expected blob to survive while still referenced")
+	}
+	if got := string(objects["refs/"+blobID].body); got != "1" {
+		t.Errorf("expected reference count 1, got %q", got)
+	}
+
+	retrieved, err := storage.Retrieve(blobID)
+	if err != nil || retrieved != content {
+		t.Errorf("expected surviving content to be retrievable, got %q, %v", retrieved, err)
+	}
+
+	// Second delete removes the blob and its reference count
+	if err := storage.Delete(blobID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := objects["blobs/"+blobID]; ok {
+		t.Error("expected blob to be deleted after the last reference")
+	}
+	if _, ok := objects["refs/"+blobID]; ok {
+		t.Error("expected reference count to be deleted after the last reference")
+	}
+}
+
+func TestAddRemoveReference(t *testing.T) {
+	storage, objects, _ := newRefCountingStorage()
+
+	blobID, err := storage.Store("referenced content")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	count, err := storage.AddReference(blobID)
+	if err != nil || count != 2 {
+		t.Fatalf("expected count 2, got %d, %v", count, err)
+	}
+
+	count, err = storage.RemoveReference(blobID)
+	if err != nil || count != 1 {
+		t.Fatalf("expected count 1, got %d, %v", count, err)
+	}
+
+	count, err = storage.RemoveReference(blobID)
+	if err != nil || count != 0 {
+		t.Fatalf("expected count 0, got %d, %v", count, err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("expected no objects left, got %d", len(objects))
+	}
+}
+
+func TestRemoveReferenceUntrackedBlob(t *testing.T) {
+	mock, objects := newBucketMock()
+	legacy := newMockS3BlobStorage(mock, "test-bucket", true)
+	blobID, err := legacy.Store("stored before reference counting")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.referenceCounting = true
+
+	if err := storage.Delete(blobID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("expected untracked blob to be deleted, %d objects left", len(objects))
+	}
+}
+
+func TestReferenceCountRetriesOnConflict(t *testing.T) {
+	storage, objects, mock := newRefCountingStorage()
+
+	blobID, err := storage.Store("contended content")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Simulate a concurrent writer winning the first conditional update
+	conflicts := 1
+	put := mock.putObjectFunc
+	mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		if *params.Key == "refs/"+blobID && conflicts > 0 {
+			conflicts--
+			objects["refs/"+blobID] = &storedObject{body: []byte("5"), etag: "\"concurrent\""}
+			return nil, &smithy.GenericAPIError{Code: "PreconditionFailed"}
+		}
+		return put(ctx, params, optFns...)
+	}
+
+	count, err := storage.AddReference(blobID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 6 {
+		t.Errorf("expected count to build on the concurrent update (6), got %d", count)
+	}
+}
+
+func TestReferenceCountGivesUpUnderContention(t *testing.T) {
+	storage, _, mock := newRefCountingStorage()
+	mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		return nil, &smithy.GenericAPIError{Code: "ConditionalRequestConflict"}
+	}
+
+	_, err := storage.AddReference(testBlobID("always contended"))
+	if err == nil {
+		t.Fatal("expected error but got none")
+	}
+}
+
+func TestReferenceCountDisabledStorage(t *testing.T) {
+	storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", false)
+
+	if _, err := storage.AddReference("id"); !errors.Is(err, ErrStorageDisabled) {
+		t.Errorf("expected ErrStorageDisabled, got %v", err)
+	}
+	if _, err := storage.RemoveReference("id"); !errors.Is(err, ErrStorageDisabled) {
+		t.Errorf("expected ErrStorageDisabled, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Rename moves a blob to newKey within the bucket without downloading it,
+// e.g. for migrations that change the key layout. Since blob IDs are content
+// hashes this changes where a blob lives, not its identity; later calls only
+// find it at newKey if the storage is configured to derive that key. The
+// blob is copied with CopyObject and the original deleted; if the delete
+// fails the copy is removed again so the blob is left only at its old key.
+// A reference count, kept in the refs/<blobID> sidecar, stays where it is:
+// it is keyed by blob ID, which a rename doesn't change.
+//
+// newKey must lie under the store's key prefix and outside refs/, or
+// ErrInvalidKey is returned. With ObjectLockMode set, a blob still under
+// retention fails with ErrBlobRetained, and the copy is locked like a new
+// upload.
+func (s *S3BlobStorage) Rename(ctx context.Context, oldBlobID, newKey string) (err error) {
+	defer s.wrapError("Rename", oldBlobID, &err)
+	if !s.enabled {
+		return ErrStorageDisabled
+	}
+
+	if err := s.validateBlobID(oldBlobID); err != nil {
+		return err
+	}
+
+	oldKey := s.blobKey(oldBlobID)
+	if err := s.checkRenameKey(oldKey, newKey); err != nil {
+		return fmt.Errorf("invalid rename target %q for blob %s: %w", newKey, oldBlobID, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opTimeout(s.uploadTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "Rename"); err != nil {
+		return err
+	}
+	defer s.releaseOp()
+
+	if s.dryRun {
+		exists, err := s.objectExists(ctx, oldKey)
+		if err != nil {
+			return fmt.Errorf("failed to check blob existence: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("failed to rename blob %s: %w", oldBlobID, ErrBlobNotFound)
+		}
+		s.logger.Debugf("blobstorage: dry run, would rename %s to %s", oldKey, newKey)
+		return nil
+	}
+
+	if s.objectLockMode != "" {
+		if err := s.checkRetention(ctx, oldBlobID, oldKey); err != nil {
+			return err
+		}
+	}
+
+	// Metadata is copied with the object
+	sse, kmsKeyID := s.serverSideEncryption()
+	input := &s3.CopyObjectInput{
+		Bucket:                    aws.String(s.bucket),
+		Key:                       aws.String(newKey),
+		CopySource:                copySource(s.bucket, oldKey),
+		ServerSideEncryption:      sse,
+		SSEKMSKeyId:               kmsKeyID,
+		StorageClass:              s.storageClassFor(putOptions{}),
+		ACL:                       types.ObjectCannedACL(s.acl),
+		ObjectLockMode:            types.ObjectLockMode(s.objectLockMode),
+		ObjectLockRetainUntilDate: s.retainUntilFor(),
+	}
+	s.setCopySSECustomer(input, s)
+	_, err = s.client.CopyObject(ctx, input)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("failed to rename blob: %w: %w", ErrBlobNotFound, err)
+		}
+		return fmt.Errorf("failed to rename blob: %w", err)
+	}
+
+	s.dedupCache.remove(oldBlobID)
+
+	if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(oldKey),
+	}); err != nil {
+		err = fmt.Errorf("failed to delete blob after copying it to %s: %w", newKey, err)
+
+		_, rollbackErr := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(newKey),
+		})
+		if rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to remove copy at %s: %w", newKey, rollbackErr))
+		}
+		return err
+	}
+
+	s.logger.Debugf("blobstorage: renamed %s to %s", oldKey, newKey)
+	return nil
+}
+
+// checkRenameKey fails with ErrInvalidKey unless newKey is a clean key under
+// the store's key prefix, other than oldKey and outside the refs/ namespace
+// holding reference counts
+func (s *S3BlobStorage) checkRenameKey(oldKey, newKey string) error {
+	rel, ok := strings.CutPrefix(newKey, s.keyPrefix)
+	switch {
+	case !ok || rel == "":
+		return fmt.Errorf("%w: must be under key prefix %q", ErrInvalidKey, s.keyPrefix)
+	case newKey == oldKey:
+		return fmt.Errorf("%w: same as the current key", ErrInvalidKey)
+	case path.Clean(newKey) != newKey || strings.HasPrefix(newKey, "/"):
+		return fmt.Errorf("%w: must be a clean relative path", ErrInvalidKey)
+	case strings.HasPrefix(rel, refKeyPrefix):
+		return fmt.Errorf("%w: reserved for reference counts", ErrInvalidKey)
+	}
+	return nil
+}
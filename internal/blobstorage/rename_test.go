@@ -0,0 +1,205 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// withCopy adds CopyObject support to a bucket mock
+func withCopy(mock *mockS3Client, objects map[string]*storedObject) *mockS3Client {
+	mock.copyObjectFunc = func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+		source, err := url.PathUnescape(strings.TrimPrefix(aws.ToString(params.CopySource), "test-bucket/"))
+		if err != nil {
+			return nil, err
+		}
+		src, ok := objects[source]
+		if !ok {
+			return nil, &smithy.GenericAPIError{Code: "NoSuchKey"}
+		}
+		copied := *src
+		objects[aws.ToString(params.Key)] = &copied
+		return &s3.CopyObjectOutput{}, nil
+	}
+	return mock
+}
+
+func TestRename(t *testing.T) {
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(withCopy(mock, objects), "test-bucket", true)
+
+	blobID, err := storage.Store("content to move")
+	if err != nil {
+		t.Fatalf("failed to store blob: %v", err)
+	}
+
+	newKey := "tenant-a/blobs/" + blobID
+	if err := storage.Rename(context.Background(), blobID, newKey); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := objects["blobs/"+blobID]; ok {
+		t.Error("expected the old key to be deleted")
+	}
+	if obj, ok := objects[newKey]; !ok || string(obj.body) != "content to move" {
+		t.Error("expected the blob to be copied to the new key")
+	}
+
+	if err := storage.Rename(context.Background(), blobID, newKey); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound renaming a missing blob, got %v", err)
+	}
+	if err := storage.Rename(context.Background(), blobID, "blobs/"+blobID); err == nil {
+		t.Error("expected an error renaming a blob to its own key")
+	}
+}
+
+func TestRenameDeleteFailureRollsBack(t *testing.T) {
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(withCopy(mock, objects), "test-bucket", true)
+
+	blobID, err := storage.Store("content to move")
+	if err != nil {
+		t.Fatalf("failed to store blob: %v", err)
+	}
+	oldKey := "blobs/" + blobID
+	newKey := "tenant-a/blobs/" + blobID
+
+	deleteObject := mock.deleteObjectFunc
+	mock.deleteObjectFunc = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		if aws.ToString(params.Key) == oldKey {
+			return nil, &smithy.GenericAPIError{Code: "AccessDenied"}
+		}
+		return deleteObject(ctx, params, optFns...)
+	}
+
+	err = storage.Rename(context.Background(), blobID, newKey)
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Fatalf("expected the delete error, got %v", err)
+	}
+
+	if _, ok := objects[oldKey]; !ok {
+		t.Error("expected the blob to remain at its old key")
+	}
+	if _, ok := objects[newKey]; ok {
+		t.Error("expected the copy to be rolled back")
+	}
+}
+
+func TestRenameEscapesKeyAndKeepsReferenceCount(t *testing.T) {
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(withCopy(mock, objects), "test-bucket", true)
+	storage.keyPrefix = "tenant a+b/"
+	storage.referenceCounting = true
+
+	var copySource string
+	copyObject := mock.copyObjectFunc
+	mock.copyObjectFunc = func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+		copySource = aws.ToString(params.CopySource)
+		return copyObject(ctx, params, optFns...)
+	}
+
+	content := "content to move"
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("failed to store blob: %v", err)
+	}
+	if _, err := storage.Store(content); err != nil {
+		t.Fatalf("failed to store blob: %v", err)
+	}
+
+	oldKey := "tenant a+b/blobs/" + blobID
+	if err := storage.Rename(context.Background(), blobID, "tenant a+b/moved/"+blobID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "test-bucket/" + url.PathEscape(oldKey); copySource != want {
+		t.Errorf("expected CopySource=%q, got %q", want, copySource)
+	}
+	if got := string(objects["tenant a+b/refs/"+blobID].body); got != "2" {
+		t.Errorf("expected the reference count to stay with the blob ID, got %q", got)
+	}
+}
+
+func TestRenameObjectLock(t *testing.T) {
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(withCopy(mock, objects), "test-bucket", true)
+
+	blobID, err := storage.Store("regulated content")
+	if err != nil {
+		t.Fatalf("failed to store blob: %v", err)
+	}
+	storage.objectLockMode = ObjectLockCompliance
+	storage.objectLockDays = 30
+
+	retainUntil := time.Now().Add(time.Hour)
+	headObject := mock.headObjectFunc
+	mock.headObjectFunc = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		out, err := headObject(ctx, params, optFns...)
+		if err == nil {
+			out.ObjectLockMode = types.ObjectLockModeCompliance
+			out.ObjectLockRetainUntilDate = aws.Time(retainUntil)
+		}
+		return out, err
+	}
+	var copyInput *s3.CopyObjectInput
+	copyObject := mock.copyObjectFunc
+	mock.copyObjectFunc = func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+		copyInput = params
+		return copyObject(ctx, params, optFns...)
+	}
+
+	newKey := "moved/" + blobID
+	if err := storage.Rename(context.Background(), blobID, newKey); !errors.Is(err, ErrBlobRetained) {
+		t.Fatalf("expected ErrBlobRetained, got %v", err)
+	}
+	if copyInput != nil {
+		t.Error("expected a retained blob not to be copied")
+	}
+	if _, ok := objects["blobs/"+blobID]; !ok {
+		t.Error("expected the retained blob to stay at its old key")
+	}
+
+	retainUntil = time.Now().Add(-time.Hour)
+	if err := storage.Rename(context.Background(), blobID, newKey); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if copyInput == nil || copyInput.ObjectLockMode != types.ObjectLockModeCompliance || copyInput.ObjectLockRetainUntilDate == nil {
+		t.Errorf("expected the copy to carry the object lock, got %+v", copyInput)
+	}
+}
+
+func TestRenameInvalidKey(t *testing.T) {
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(withCopy(mock, objects), "test-bucket", true)
+	storage.keyPrefix = "tenant-a/"
+
+	blobID, err := storage.Store("content to move")
+	if err != nil {
+		t.Fatalf("failed to store blob: %v", err)
+	}
+
+	for _, newKey := range []string{
+		"",
+		"tenant-a/",
+		"tenant-b/blobs/" + blobID,
+		"tenant-a/refs/" + blobID,
+		"tenant-a/moved/../../tenant-b/" + blobID,
+		"tenant-a//moved/" + blobID,
+		"tenant-a/blobs/" + blobID,
+	} {
+		if err := storage.Rename(context.Background(), blobID, newKey); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("expected ErrInvalidKey renaming to %q, got %v", newKey, err)
+		}
+	}
+	if len(objects) != 1 {
+		t.Errorf("expected no objects to be written, got %d", len(objects))
+	}
+}
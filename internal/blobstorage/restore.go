@@ -0,0 +1,178 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// restoreExpiryPattern extracts the expiry date from the x-amz-restore
+// header of a restored object, e.g.
+// ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"
+var restoreExpiryPattern = regexp.MustCompile(`expiry-date="([^"]+)"`)
+
+// restoreOngoingPattern matches the x-amz-restore header of an object that
+// is being restored
+var restoreOngoingPattern = regexp.MustCompile(`ongoing-request="true"`)
+
+// ArchiveState describes whether a blob has been archived, e.g. by a
+// lifecycle rule moving it to GLACIER, and the progress of restoring it
+type ArchiveState struct {
+	// StorageClass is the blob's storage class; empty means STANDARD
+	StorageClass string
+	// Archived is true when the content can't be read until it is restored
+	Archived bool
+	// RestoreInProgress is true while a Restore is running
+	RestoreInProgress bool
+	// RestoreExpiry is when the temporary copy made by a completed Restore is
+	// removed again, or zero if there is none
+	RestoreExpiry time.Time
+}
+
+// Restore starts restoring an archived blob so it can be read again for the
+// given number of days. tier is the retrieval speed, "Expedited", "Standard"
+// or "Bulk"; empty means Standard. Restores take minutes to hours; poll
+// RestoreStatus for completion. Restoring a blob that is already being
+// restored, or that isn't archived, succeeds without doing anything.
+func (s *S3BlobStorage) Restore(ctx context.Context, blobID string, days int, tier string) (err error) {
+	defer s.wrapError("Restore", blobID, &err)
+	if !s.enabled {
+		return ErrStorageDisabled
+	}
+
+	if err := s.validateBlobID(blobID); err != nil {
+		return err
+	}
+	if days < 1 || days > math.MaxInt32 {
+		return fmt.Errorf("invalid restore days %d: must be positive", days)
+	}
+	if tier == "" {
+		tier = string(types.TierStandard)
+	}
+	if err := validateRestoreTier(tier); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "Restore"); err != nil {
+		return err
+	}
+	defer s.releaseOp()
+
+	_, err = s.client.RestoreObject(ctx, &s3.RestoreObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.blobKey(blobID)),
+		RestoreRequest: &types.RestoreRequest{
+			Days:                 aws.Int32(int32(days)), // #nosec G115 -- bounded above
+			GlacierJobParameters: &types.GlacierJobParameters{Tier: types.Tier(tier)},
+		},
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) {
+			switch apiErr.ErrorCode() {
+			case "RestoreAlreadyInProgress", "ObjectAlreadyInActiveTierError":
+				s.logger.Debugf("blobstorage: blob %s needs no restore: %s", blobID, apiErr.ErrorCode())
+				return nil
+			}
+		}
+		if isNotFound(err) {
+			return fmt.Errorf("failed to restore blob: %w: %w", ErrBlobNotFound, err)
+		}
+		return fmt.Errorf("failed to restore blob: %w", err)
+	}
+	return nil
+}
+
+// RestoreStatus reports whether a blob is archived and how far along
+// restoring it is
+func (s *S3BlobStorage) RestoreStatus(blobID string) (_ ArchiveState, err error) {
+	defer s.wrapError("RestoreStatus", blobID, &err)
+	if !s.enabled {
+		return ArchiveState{}, ErrStorageDisabled
+	}
+
+	if err := s.validateBlobID(blobID); err != nil {
+		return ArchiveState{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "RestoreStatus"); err != nil {
+		return ArchiveState{}, err
+	}
+	defer s.releaseOp()
+
+	return s.archiveState(ctx, s.blobKey(blobID))
+}
+
+// archiveState reads the archive state of the object at key
+func (s *S3BlobStorage) archiveState(ctx context.Context, key string) (ArchiveState, error) {
+	result, err := s.client.HeadObject(ctx, s.headObjectInput(key))
+	if err != nil {
+		if isNotFound(err) {
+			return ArchiveState{}, fmt.Errorf("failed to get blob restore status: %w: %w", ErrBlobNotFound, err)
+		}
+		return ArchiveState{}, fmt.Errorf("failed to get blob restore status: %w", err)
+	}
+	return parseArchiveState(result.StorageClass, result.ArchiveStatus, aws.ToString(result.Restore)), nil
+}
+
+// archivedError builds the ErrBlobArchived for a read of key rejected with
+// err, looking up its restore status. If that fails the error carries what
+// err reports.
+func (s *S3BlobStorage) archivedError(ctx context.Context, key string, err error) *ErrBlobArchived {
+	state, headErr := s.archiveState(ctx, key)
+	if headErr != nil {
+		state = ArchiveState{Archived: true}
+		var stateErr *types.InvalidObjectState
+		if errors.As(err, &stateErr) {
+			state.StorageClass = string(stateErr.StorageClass)
+		}
+	}
+	return &ErrBlobArchived{State: state}
+}
+
+// parseArchiveState interprets an object's storage class, Intelligent-Tiering
+// archive status and x-amz-restore header
+func parseArchiveState(class types.StorageClass, tier types.ArchiveStatus, restore string) ArchiveState {
+	state := ArchiveState{StorageClass: string(class)}
+
+	if m := restoreExpiryPattern.FindStringSubmatch(restore); m != nil {
+		if expiry, err := http.ParseTime(m[1]); err == nil {
+			state.RestoreExpiry = expiry
+		}
+	}
+	state.RestoreInProgress = restoreOngoingPattern.MatchString(restore)
+
+	switch {
+	case tier != "":
+		// Intelligent-Tiering archive tiers are restored in place
+		state.Archived = true
+	case class == types.StorageClassGlacier || class == types.StorageClassDeepArchive:
+		state.Archived = state.RestoreExpiry.IsZero()
+	}
+	return state
+}
+
+// validateRestoreTier checks tier against the S3 restore tiers
+func validateRestoreTier(tier string) error {
+	for _, known := range types.Tier("").Values() {
+		if tier == string(known) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid restore tier %q", tier)
+}
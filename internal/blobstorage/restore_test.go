@@ -0,0 +1,173 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestRestore(t *testing.T) {
+	blobID := testBlobID("archived")
+
+	tests := []struct {
+		name        string
+		days        int
+		tier        string
+		restoreErr  error
+		expectTier  types.Tier
+		expectError bool
+		notFound    bool
+	}{
+		{name: "default tier", days: 7, expectTier: types.TierStandard},
+		{name: "bulk tier", days: 30, tier: "Bulk", expectTier: types.TierBulk},
+		{name: "already in progress", days: 7, expectTier: types.TierStandard, restoreErr: &smithy.GenericAPIError{Code: "RestoreAlreadyInProgress"}},
+		{name: "not archived", days: 7, expectTier: types.TierStandard, restoreErr: &smithy.GenericAPIError{Code: "ObjectAlreadyInActiveTierError"}},
+		{name: "missing blob", days: 7, expectTier: types.TierStandard, restoreErr: &smithy.GenericAPIError{Code: "NoSuchKey"}, expectError: true, notFound: true},
+		{name: "zero days", days: 0, expectError: true},
+		{name: "invalid tier", days: 7, tier: "Instant", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input *s3.RestoreObjectInput
+			mock := &mockS3Client{
+				restoreObjectFunc: func(ctx context.Context, params *s3.RestoreObjectInput, optFns ...func(*s3.Options)) (*s3.RestoreObjectOutput, error) {
+					input = params
+					return &s3.RestoreObjectOutput{}, tt.restoreErr
+				},
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+			err := storage.Restore(context.Background(), blobID, tt.days, tt.tier)
+			if tt.expectError != (err != nil) {
+				t.Fatalf("expected error=%v, got %v", tt.expectError, err)
+			}
+			if tt.notFound && !errors.Is(err, ErrBlobNotFound) {
+				t.Errorf("expected ErrBlobNotFound, got %v", err)
+			}
+			if tt.expectTier == "" {
+				if input != nil {
+					t.Errorf("expected no RestoreObject request")
+				}
+				return
+			}
+
+			if aws.ToString(input.Key) != storage.blobKey(blobID) {
+				t.Errorf("expected key %q, got %q", storage.blobKey(blobID), aws.ToString(input.Key))
+			}
+			if aws.ToInt32(input.RestoreRequest.Days) != int32(tt.days) {
+				t.Errorf("expected %d days, got %d", tt.days, aws.ToInt32(input.RestoreRequest.Days))
+			}
+			if input.RestoreRequest.GlacierJobParameters.Tier != tt.expectTier {
+				t.Errorf("expected tier %s, got %s", tt.expectTier, input.RestoreRequest.GlacierJobParameters.Tier)
+			}
+		})
+	}
+}
+
+func TestRetrieveArchived(t *testing.T) {
+	tests := []struct {
+		name           string
+		restore        *string
+		headErr        error
+		expectClass    string
+		expectProgress bool
+	}{
+		{name: "not restored", expectClass: "GLACIER"},
+		{name: "restore in progress", restore: aws.String(`ongoing-request="true"`), expectClass: "GLACIER", expectProgress: true},
+		{name: "status unavailable", headErr: errors.New("connection reset"), expectClass: "DEEP_ARCHIVE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockS3Client{
+				getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					return nil, &types.InvalidObjectState{StorageClass: types.StorageClassDeepArchive}
+				},
+				headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+					if tt.headErr != nil {
+						return nil, tt.headErr
+					}
+					return &s3.HeadObjectOutput{StorageClass: types.StorageClassGlacier, Restore: tt.restore}, nil
+				},
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+			_, err := storage.Retrieve(testBlobID("archived"))
+			var archived *ErrBlobArchived
+			if !errors.As(err, &archived) {
+				t.Fatalf("expected ErrBlobArchived, got %v", err)
+			}
+			if !archived.State.Archived {
+				t.Errorf("expected the blob to be reported archived")
+			}
+			if archived.State.StorageClass != tt.expectClass {
+				t.Errorf("expected storage class %q, got %q", tt.expectClass, archived.State.StorageClass)
+			}
+			if archived.State.RestoreInProgress != tt.expectProgress {
+				t.Errorf("expected RestoreInProgress=%v, got %v", tt.expectProgress, archived.State.RestoreInProgress)
+			}
+		})
+	}
+}
+
+func TestRestoreStatus(t *testing.T) {
+	expiry := time.Date(2012, 12, 21, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		output   *s3.HeadObjectOutput
+		expected ArchiveState
+	}{
+		{
+			name:     "standard",
+			output:   &s3.HeadObjectOutput{},
+			expected: ArchiveState{},
+		},
+		{
+			name:     "archived",
+			output:   &s3.HeadObjectOutput{StorageClass: types.StorageClassGlacier},
+			expected: ArchiveState{StorageClass: "GLACIER", Archived: true},
+		},
+		{
+			name:     "restoring",
+			output:   &s3.HeadObjectOutput{StorageClass: types.StorageClassDeepArchive, Restore: aws.String(`ongoing-request="true"`)},
+			expected: ArchiveState{StorageClass: "DEEP_ARCHIVE", Archived: true, RestoreInProgress: true},
+		},
+		{
+			name:     "restored",
+			output:   &s3.HeadObjectOutput{StorageClass: types.StorageClassGlacier, Restore: aws.String(`ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`)},
+			expected: ArchiveState{StorageClass: "GLACIER", RestoreExpiry: expiry},
+		},
+		{
+			name:     "intelligent-tiering archive",
+			output:   &s3.HeadObjectOutput{StorageClass: types.StorageClassIntelligentTiering, ArchiveStatus: types.ArchiveStatusArchiveAccess},
+			expected: ArchiveState{StorageClass: "INTELLIGENT_TIERING", Archived: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockS3Client{
+				headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+					return tt.output, nil
+				},
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+			state, err := storage.RestoreStatus(testBlobID("archived"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if state != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, state)
+			}
+		})
+	}
+}
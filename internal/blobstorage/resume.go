@@ -0,0 +1,102 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// defaultResumeRetries is how many times a download is resumed after a
+// mid-stream failure when ResumeRetries isn't set
+const defaultResumeRetries = 3
+
+// resumableBody is a GetObject response body that, when the connection
+// fails partway through, re-requests the rest of the object with a range GET
+// from the last offset read and carries on, so the caller sees one
+// continuous stream. The range GET is pinned to the original ETag so the
+// stream is never stitched together from two different objects.
+type resumableBody struct {
+	s       *S3BlobStorage
+	ctx     context.Context
+	loc     replica
+	key     string
+	etag    *string
+	body    io.ReadCloser
+	offset  int64
+	size    int64 // -1 when the response didn't say
+	retries int
+}
+
+// resumableBody wraps result's body, served by loc, so that it resumes from
+// the same location up to ResumeRetries times, or returns it unchanged when
+// resuming is disabled
+func (s *S3BlobStorage) resumableBody(ctx context.Context, blobID string, loc replica, result *s3.GetObjectOutput) io.ReadCloser {
+	if s.resumeRetries <= 0 {
+		return result.Body
+	}
+
+	size := int64(-1)
+	if result.ContentLength != nil {
+		size = *result.ContentLength
+	}
+	return &resumableBody{
+		s:       s,
+		ctx:     ctx,
+		loc:     loc,
+		key:     s.blobKey(blobID),
+		etag:    result.ETag,
+		body:    result.Body,
+		size:    size,
+		retries: s.resumeRetries,
+	}
+}
+
+func (r *resumableBody) Read(p []byte) (int, error) {
+	for {
+		n, err := r.body.Read(p)
+		r.offset += int64(n)
+
+		// A body that ends before its Content-Length was cut off too
+		if errors.Is(err, io.EOF) && r.size >= 0 && r.offset < r.size {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil || errors.Is(err, io.EOF) || r.retries == 0 || r.ctx.Err() != nil {
+			return n, err
+		}
+
+		r.retries--
+		r.s.logger.Warnf("blobstorage: download of %s failed at byte %d, resuming: %v", r.key, r.offset, err)
+		if resumeErr := r.resume(); resumeErr != nil {
+			return n, fmt.Errorf("%w (resume failed: %w)", err, resumeErr)
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// resume replaces the failed body with a range GET from the current offset
+func (r *resumableBody) resume() error {
+	_ = r.body.Close()
+
+	input := r.s.getObjectInput(r.loc.bucket, r.key)
+	input.Range = aws.String(fmt.Sprintf("bytes=%d-", r.offset))
+	input.IfMatch = r.etag
+
+	result, err := r.loc.client.GetObject(r.ctx, input)
+	if err != nil {
+		r.body = http.NoBody
+		return err
+	}
+	r.body = result.Body
+	return nil
+}
+
+func (r *resumableBody) Close() error {
+	return r.body.Close()
+}
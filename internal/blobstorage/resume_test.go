@@ -0,0 +1,111 @@
+package blobstorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// flakyBody serves data but fails with a connection error once remaining
+// bytes have been read
+type flakyBody struct {
+	r         io.Reader
+	remaining int
+}
+
+func (b *flakyBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, errors.New("connection reset by peer")
+	}
+	n, err := b.r.Read(p[:min(len(p), b.remaining)])
+	b.remaining -= n
+	return n, err
+}
+
+func (b *flakyBody) Close() error {
+	return nil
+}
+
+func TestRetrieveReaderResume(t *testing.T) {
+	content := strings.Repeat("resumable content ", 200)
+
+	tests := []struct {
+		name          string
+		failAfter     int
+		failures      int
+		retries       int
+		expectError   bool
+		expectedCalls int
+	}{
+		{name: "no failure", retries: 3, expectedCalls: 1},
+		{name: "one failure", failAfter: 700, failures: 1, retries: 3, expectedCalls: 2},
+		{name: "failures within budget", failAfter: 500, failures: 3, retries: 3, expectedCalls: 4},
+		{name: "failures exceed budget", failAfter: 500, failures: 4, retries: 3, expectError: true, expectedCalls: 4},
+		{name: "resuming disabled", failAfter: 500, failures: 1, expectError: true, expectedCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ranges []string
+			calls := 0
+			mock := &mockS3Client{
+				getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					calls++
+					start := 0
+					if params.Range != nil {
+						ranges = append(ranges, *params.Range)
+						if _, err := fmt.Sscanf(*params.Range, "bytes=%d-", &start); err != nil {
+							return nil, err
+						}
+						if aws.ToString(params.IfMatch) != `"etag"` {
+							t.Errorf("expected resume pinned to the original ETag, got %q", aws.ToString(params.IfMatch))
+						}
+					}
+					// Each of the first failures responses breaks after failAfter bytes
+					remaining := len(content) + 1
+					if calls <= tt.failures {
+						remaining = tt.failAfter
+					}
+					return &s3.GetObjectOutput{
+						Body:          &flakyBody{r: strings.NewReader(content[start:]), remaining: remaining},
+						ContentLength: aws.Int64(int64(len(content) - start)),
+						ETag:          aws.String(`"etag"`),
+					}, nil
+				},
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			storage.resumeRetries = tt.retries
+
+			r, err := storage.RetrieveReader(context.Background(), testBlobID(content))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer r.Close()
+
+			var buf bytes.Buffer
+			_, err = io.Copy(&buf, r)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if buf.String() != content {
+					t.Errorf("expected full content (%d bytes), got %d bytes", len(content), buf.Len())
+				}
+			}
+			if calls != tt.expectedCalls {
+				t.Errorf("expected %d GetObject calls, got %d (ranges %v)", tt.expectedCalls, calls, ranges)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package blobstorage
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// defaultMaxRetryAfter caps the Retry-After delay honored when MaxRetryAfter
+// isn't set
+const defaultMaxRetryAfter = 30 * time.Second
+
+// newRetryer builds the SDK's standard retryer with the attempt and backoff
+// limits from cfg, honoring Retry-After headers and logging each retry
+func newRetryer(cfg Config) aws.RetryerV2 {
+	var retryer aws.RetryerV2 = retry.NewStandard(func(o *retry.StandardOptions) {
+		if cfg.MaxAttempts > 0 {
+			o.MaxAttempts = cfg.MaxAttempts
+		}
+		if cfg.RetryMaxBackoff > 0 {
+			o.MaxBackoff = cfg.RetryMaxBackoff
+		}
+	})
+	if cfg.MaxRetryAfter >= 0 {
+		maxDelay := cfg.MaxRetryAfter
+		if maxDelay == 0 {
+			maxDelay = defaultMaxRetryAfter
+		}
+		retryer = &retryAfterRetryer{RetryerV2: retryer, max: maxDelay, now: time.Now}
+	}
+	return &loggingRetryer{RetryerV2: retryer, logger: cfg.Logger}
+}
+
+// retryAfterRetryer waits at least as long as a response's Retry-After header
+// asks before retrying, e.g. a MinIO 503 SlowDown, rather than only the
+// wrapped retryer's backoff. Requested delays are capped at max.
+type retryAfterRetryer struct {
+	aws.RetryerV2
+	max time.Duration
+	now func() time.Time
+}
+
+func (r *retryAfterRetryer) RetryDelay(attempt int, err error) (time.Duration, error) {
+	delay, delayErr := r.RetryerV2.RetryDelay(attempt, err)
+	if delayErr != nil {
+		return delay, delayErr
+	}
+	if retryAfter, ok := r.retryAfter(err); ok {
+		delay = max(delay, min(retryAfter, r.max))
+	}
+	return delay, nil
+}
+
+// retryAfter returns the delay the Retry-After header of err's response asks
+// for, given either in seconds or as an HTTP date
+func (r *retryAfterRetryer) retryAfter(err error) (time.Duration, bool) {
+	var respErr *smithyhttp.ResponseError
+	if !errors.As(err, &respErr) || respErr.Response == nil || respErr.Response.Response == nil {
+		return 0, false
+	}
+
+	value := strings.TrimSpace(respErr.Response.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(r.now()), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,147 @@
+package blobstorage
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+func TestNewRetryer(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		maxAttempts int
+		maxBackoff  time.Duration
+	}{
+		{name: "SDK defaults", cfg: Config{}, maxAttempts: retry.DefaultMaxAttempts, maxBackoff: retry.DefaultMaxBackoff},
+		{name: "custom", cfg: Config{MaxAttempts: 5, RetryMaxBackoff: 2 * time.Second}, maxAttempts: 5, maxBackoff: 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Logger = NoopLogger{}
+			retryer := newRetryer(tt.cfg)
+
+			if got := retryer.MaxAttempts(); got != tt.maxAttempts {
+				t.Errorf("expected MaxAttempts=%d, got %d", tt.maxAttempts, got)
+			}
+			for attempt := 1; attempt <= 20; attempt++ {
+				delay, err := retryer.RetryDelay(attempt, errors.New("throttled"))
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if delay > tt.maxBackoff {
+					t.Fatalf("attempt %d: expected delay <= %s, got %s", attempt, tt.maxBackoff, delay)
+				}
+			}
+		})
+	}
+}
+
+func TestNewS3BlobStorageNegativeRetrySettings(t *testing.T) {
+	for _, cfg := range []Config{{MaxAttempts: -1}, {RetryMaxBackoff: -time.Second}} {
+		cfg.Enabled = true
+		cfg.AccessKey = "test-key"
+		cfg.SecretKey = "test-secret"
+		if _, err := NewS3BlobStorage(cfg); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+// zeroDelayRetryer retries immediately, isolating the Retry-After delay
+type zeroDelayRetryer struct {
+	*retry.Standard
+}
+
+func (zeroDelayRetryer) RetryDelay(int, error) (time.Duration, error) {
+	return 0, nil
+}
+
+func TestRetryAfterDelay(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	slowDown := func(retryAfter string) error {
+		header := http.Header{}
+		if retryAfter != "" {
+			header.Set("Retry-After", retryAfter)
+		}
+		return &smithyhttp.ResponseError{Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusServiceUnavailable, Header: header}}}
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected time.Duration
+	}{
+		{name: "seconds", err: slowDown("3"), expected: 3 * time.Second},
+		{name: "HTTP date", err: slowDown(now.Add(5 * time.Second).Format(http.TimeFormat)), expected: 5 * time.Second},
+		{name: "past date", err: slowDown(now.Add(-time.Minute).Format(http.TimeFormat)), expected: 0},
+		{name: "capped", err: slowDown("3600"), expected: 10 * time.Second},
+		{name: "unparseable", err: slowDown("soon"), expected: 0},
+		{name: "no header", err: slowDown(""), expected: 0},
+		{name: "not a response error", err: errors.New("connection reset"), expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryer := &retryAfterRetryer{
+				RetryerV2: zeroDelayRetryer{retry.NewStandard()},
+				max:       10 * time.Second,
+				now:       func() time.Time { return now },
+			}
+			delay, err := retryer.RetryDelay(1, tt.err)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if delay != tt.expected {
+				t.Errorf("expected delay %s, got %s", tt.expected, delay)
+			}
+		})
+	}
+}
+
+func TestRetryAfterRespected(t *testing.T) {
+	var attempts []time.Time
+	httpClient := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			attempts = append(attempts, time.Now())
+			if len(attempts) == 1 {
+				return &http.Response{
+					StatusCode: http.StatusServiceUnavailable,
+					Header:     http.Header{"Retry-After": []string{"1"}},
+					Body:       io.NopCloser(strings.NewReader("")),
+					Request:    req,
+				}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+		}),
+	}
+
+	storage, err := NewS3BlobStorage(Config{
+		Enabled:            true,
+		Endpoint:           "http://localhost:9000",
+		AccessKey:          "test-key",
+		SecretKey:          "test-secret",
+		SkipBucketCreation: true,
+		HTTPClient:         httpClient,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := storage.Exists(testBlobID("content")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", len(attempts))
+	}
+	if waited := attempts[1].Sub(attempts[0]); waited < time.Second {
+		t.Errorf("expected the retry to wait at least the 1s Retry-After, waited %s", waited)
+	}
+}
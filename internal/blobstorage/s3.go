@@ -3,36 +3,168 @@ package blobstorage
 import (
 	"bytes"
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
+	"crypto/cipher"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
 )
 
 // S3Api defines the S3 operations used by S3BlobStorage for testability
 type S3Api interface {
 	CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error)
+	HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
 	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
 	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
 	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
 	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
+	PutObjectTagging(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error)
+	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	RestoreObject(ctx context.Context, params *s3.RestoreObjectInput, optFns ...func(*s3.Options)) (*s3.RestoreObjectOutput, error)
+}
+
+// BlobStorage is the content-addressed blob store used to keep message
+// parts out of the database. Blob IDs are the SHA256 hex digest of the content.
+type BlobStorage interface {
+	IsEnabled() bool
+	Store(content string) (string, error)
+	Retrieve(blobID string) (string, error)
+	Delete(blobID string) error
+	Exists(blobID string) (bool, error)
+}
+
+var _ BlobStorage = (*S3BlobStorage)(nil)
+
+// blobKeyPrefix is the key prefix under which all blobs are stored
+const blobKeyPrefix = "blobs/"
+
+// maxShardDepth bounds ShardDepth; each level already splits keys 256 ways
+const maxShardDepth = 4
+
+// S3Presigner defines the presign operations used by S3BlobStorage for testability
+type S3Presigner interface {
+	PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+	PresignPutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
 }
 
 // S3BlobStorage handles blob storage operations using S3-compatible storage
 type S3BlobStorage struct {
-	client  S3Api
-	bucket  string
-	enabled bool
-	ctx     context.Context
-	timeout time.Duration
+	client    S3Api
+	presigner S3Presigner
+	bucket    string
+	enabled   bool
+	ctx       context.Context
+	cancel    context.CancelFunc
+	timeout   time.Duration
+
+	// clock is time.Now outside of tests, used for expiry and retention
+	clock func() time.Time
+
+	// Per-kind overrides of timeout; zero uses timeout
+	uploadTimeout   time.Duration
+	downloadTimeout time.Duration
+	metadataTimeout time.Duration
+
+	// httpClient and closed support Close
+	httpClient *http.Client
+	closed     atomic.Bool
+
+	// endpoint, region, accessKey and profile identify the backend for Copy
+	// and diagnostics
+	endpoint  string
+	region    string
+	accessKey string
+	profile   string
+
+	// resolvesEndpoint is set when an EndpointResolver picks the endpoint,
+	// so its scheme isn't known up front
+	resolvesEndpoint bool
+
+	// availabilityZone is the zone of an ExpressOneZone directory bucket, or
+	// empty for a general purpose bucket
+	availabilityZone string
+
+	dedupThrottlePolicy   string
+	skipDedupCheck        bool
+	aead                  cipher.AEAD
+	encryptionKeyID       string
+	compression           string
+	verifyOnRetrieve      bool
+	verifyMultipart       string
+	multipartThreshold    int64
+	multipartPartSize     int64
+	maxBlobSize           int64
+	rejectEmpty           bool
+	uploadChecksum        string
+	sendContentMD5        bool
+	objectLockMode        string
+	objectLockDays        int
+	objectLockRetainUntil time.Time
+	breaker               *circuitBreaker
+	replicas              []replica
+	failoverTimeout       time.Duration
+	integrityRetries      int
+	resumeRetries         int
+	referenceCounting     bool
+	sse                   string
+	sseKMSKeyID           string
+	sseC                  *sseCustomerParams
+	storageClass          string
+	acl                   string
+	dryRun                bool
+	shardDepth            int
+	hashAlgorithm         string
+	keySuffix             string
+	keyPrefix             string
+	copyBufferSize        int
+	allowRootPurge        bool
+	autoDetectContentType bool
+	blockedContentTypes   []string
+	defaultMetadata       map[string]string
+	tenantID              string
+	limiter               RateLimiter
+	logger                Logger
+	events                chan<- Event
+	// opSlots limits concurrent operations when MaxConcurrentOps is set
+	opSlots chan struct{}
+	// dedupCache remembers stored blob IDs when DedupCacheSize is set
+	dedupCache *dedupCache
+	// copyBuffers pools the CopyBufferSize buffers used by streaming copies
+	copyBuffers sync.Pool
+	// conditionalPutUnsupported is set once the backend rejects If-None-Match
+	conditionalPutUnsupported atomic.Bool
+	// storeHits and storeMisses count stores of existing and new content
+	storeHits   atomic.Int64
+	storeMisses atomic.Int64
+
+	// bucketCheckTTL is how long a successful bucket check is trusted for;
+	// bucketVerifiedAt is when one last succeeded, in Unix nanoseconds, or
+	// zero after a failure
+	bucketCheckTTL   time.Duration
+	bucketVerifiedAt atomic.Int64
 }
 
 // Config holds S3 blob storage configuration
@@ -41,26 +173,319 @@ type Config struct {
 	Endpoint string `yaml:"endpoint"`
 	Region   string `yaml:"region"`
 	Bucket   string `yaml:"bucket"`
+	// SigningRegion overrides the region requests are signed for, leaving
+	// Region to pick the endpoint and bucket location. Most setups only need
+	// Region; set this when a gateway validates signatures against a fixed
+	// region that differs from it, e.g. Ceph RGW zonegroups or MinIO
+	// deployments with MINIO_REGION set, where a mismatch fails every request
+	// with SignatureDoesNotMatch. Empty signs for Region.
+	SigningRegion string `yaml:"signing_region"`
 	// #nosec G117 -- Configuration field name, not a hardcoded secret
 	AccessKey string `yaml:"access_key"`
 	// #nosec G117 -- Configuration field name, not a hardcoded secret
 	SecretKey string `yaml:"secret_key"`
-	Timeout   int `yaml:"timeout"` // seconds
+	Timeout   int    `yaml:"timeout"` // seconds
+	// UploadTimeout, DownloadTimeout and MetadataTimeout override Timeout for
+	// stores, reads of blob content, and small requests such as existence
+	// checks respectively. They are in seconds; zero falls back to Timeout.
+	UploadTimeout   int `yaml:"upload_timeout"`
+	DownloadTimeout int `yaml:"download_timeout"`
+	MetadataTimeout int `yaml:"metadata_timeout"`
+	// MaxAttempts is how many times the SDK tries each request, including the
+	// first; zero uses the SDK default of 3. RetryMaxBackoff caps the delay
+	// between attempts (SDK default 20s). Retries happen within the operation
+	// timeout above, so keep that longer than the worst case retry budget
+	// (roughly MaxAttempts-1 times RetryMaxBackoff plus the requests
+	// themselves) or slow requests fail with a deadline error before their
+	// retries run out.
+	MaxAttempts     int           `yaml:"max_attempts"`
+	RetryMaxBackoff time.Duration `yaml:"retry_max_backoff"`
+	// MaxRetryAfter caps how long a retry waits when a response's
+	// Retry-After header, e.g. on a 503 SlowDown, asks for longer than the
+	// backoff. Zero means the default of 30s; a negative value ignores
+	// Retry-After.
+	MaxRetryAfter time.Duration `yaml:"max_retry_after"`
+	// UseDefaultCredentials uses the AWS default credential chain (environment
+	// variables, shared config, SSO, instance profile) instead of static keys
+	UseDefaultCredentials bool `yaml:"use_default_credentials"`
+	// Profile loads credentials from this named profile in the shared AWS
+	// config and credentials files (~/.aws/config and ~/.aws/credentials)
+	// instead of static keys, which must then be left empty
+	Profile string `yaml:"profile"`
+	// DedupThrottlePolicy controls what Store and StoreMany do when the
+	// deduplication HeadObject is throttled: "fail" (default) or "upload"
+	DedupThrottlePolicy string `yaml:"dedup_throttle_policy"`
+	// SkipDedupCheck makes stores go straight to an unconditional PutObject,
+	// without the existence check or dedup cache, relying on overwrites of
+	// identical content being harmless. It saves a round trip where
+	// duplicates are rare or a fresh put is wanted, e.g. so a versioned
+	// bucket records every store. Blob IDs are still content hashes.
+	SkipDedupCheck bool `yaml:"skip_dedup_check"`
+	// EncryptionKey enables client-side AES-256-GCM encryption of blob
+	// contents when set. It must be exactly 32 bytes and is set
+	// programmatically (e.g. from a secrets manager) rather than from YAML.
+	// Blob IDs remain the SHA256 of the plaintext so deduplication still works.
+	EncryptionKey []byte `yaml:"-"`
+	// Compression is "none" (default) or "gzip". Compressed blobs are marked
+	// with x-amz-meta-encoding so they can be read alongside uncompressed ones.
+	Compression string `yaml:"compression"`
+	// VerifyMultipart checks each completed multipart upload against the
+	// bytes sent, guarding against a backend assembling parts incorrectly:
+	// "size" compares the object's size with HeadObject and "content" also
+	// downloads it to compare its SHA256. A mismatched object is deleted and
+	// the store fails with ErrMultipartMismatch. Empty disables the check.
+	VerifyMultipart string `yaml:"verify_multipart"`
+	// VerifyOnRetrieve re-hashes retrieved content and fails with
+	// ErrIntegrityMismatch if it doesn't match the blob ID
+	VerifyOnRetrieve bool `yaml:"verify_on_retrieve"`
+	// IntegrityRetries is how many more times a blob is downloaded when it
+	// fails verification, e.g. because a flaky gateway truncated the
+	// response, before giving up with ErrIntegrityMismatch. It applies to
+	// RetrieveVerified and to retrieves with VerifyOnRetrieve set. Zero
+	// means the default of 1; a negative value disables retries.
+	IntegrityRetries int `yaml:"integrity_retries"`
+	// ResumeRetries is how many times RetrieveReader resumes a download that
+	// fails mid-stream, continuing with a range GET from the last byte read
+	// instead of failing. Zero means the default of 3; a negative value
+	// disables resuming.
+	ResumeRetries int `yaml:"resume_retries"`
+	// MultipartThreshold is the stored size in bytes above which blobs are
+	// uploaded with a multipart upload (default 100MB)
+	MultipartThreshold int64 `yaml:"multipart_threshold"`
+	// MultipartPartSize is the size in bytes of each part of a multipart
+	// upload except the last (default 16MB, minimum 5MB). Uploads are
+	// limited to 10,000 parts, so this also caps the largest blob that can
+	// be stored.
+	MultipartPartSize int64 `yaml:"multipart_part_size"`
+	// CopyBufferSize is the buffer size in bytes used when streaming blobs
+	// to and from files and readers, e.g. by RetrieveToFile and StoreReader
+	// (default 64KB). Larger buffers mean fewer syscalls for big blobs.
+	CopyBufferSize int `yaml:"copy_buffer_size"`
+	// MaxBlobSize rejects content larger than this many bytes before it is
+	// uploaded; zero means no limit
+	MaxBlobSize int64 `yaml:"max_blob_size"`
+	// ReferenceCounting makes Store add a reference to the blob and Delete
+	// remove one, only deleting the blob when no references remain
+	ReferenceCounting bool `yaml:"reference_counting"`
+	// HTTPClient overrides the default HTTP client, e.g. to tune dial, TLS
+	// handshake and idle connection timeouts. It is set programmatically.
+	HTTPClient *http.Client `yaml:"-"`
+	// EndpointResolver resolves the endpoint of every request, for setups
+	// a fixed Endpoint can't express, such as per-request service mesh
+	// sidecars. It takes precedence over Endpoint, which it receives as
+	// EndpointParameters.Endpoint and may use or ignore. Nil uses the SDK
+	// resolver with Endpoint as the base endpoint. It is set programmatically.
+	EndpointResolver s3.EndpointResolverV2 `yaml:"-"`
+	// ServerSideEncryption asks S3 to encrypt stored objects: "AES256"
+	// (SSE-S3) or "aws:kms" (SSE-KMS). Empty leaves it to the bucket default.
+	ServerSideEncryption string `yaml:"server_side_encryption"`
+	// KMSKeyID is the KMS key used with "aws:kms"; empty uses the AWS-managed key
+	KMSKeyID string `yaml:"kms_key_id"`
+	// SSECustomerKey enables SSE-C: S3 encrypts objects with this 32-byte
+	// key, which is sent with every request and never stored by S3, so blobs
+	// are unreadable without it. It excludes ServerSideEncryption and
+	// presigned URLs, and like EncryptionKey is set programmatically.
+	SSECustomerKey []byte `yaml:"-"`
+	// StorageClass is the S3 storage class blobs are uploaded with (e.g.
+	// STANDARD_IA or INTELLIGENT_TIERING); empty uses the bucket default
+	StorageClass string `yaml:"storage_class"`
+	// ACL is the canned ACL blobs are uploaded with, e.g. "public-read" so a
+	// CDN can fetch them without presigning. Empty sends no ACL, leaving blobs
+	// private. Buckets with ACLs disabled (Object Ownership set to
+	// BucketOwnerEnforced, the default for new buckets) reject any ACL other
+	// than bucket-owner-full-control with AccessControlListNotSupported; use a
+	// bucket policy there instead.
+	ACL string `yaml:"acl"`
+	// BucketOwnerFullControl uploads blobs with the bucket-owner-full-control
+	// ACL so the owner of a bucket in another account can read them. It is
+	// shorthand for that ACL and can't be combined with a different one.
+	BucketOwnerFullControl bool `yaml:"bucket_owner_full_control"`
+	// ObjectLockMode retains blobs against deletion and overwrites for
+	// compliance (WORM): "GOVERNANCE" or "COMPLIANCE". Each blob is retained
+	// for ObjectLockDays after upload or, for a fixed date, until
+	// ObjectLockRetainUntil; exactly one must be set. The bucket must have
+	// object lock enabled, which NewS3BlobStorage requests when it creates
+	// the bucket. Delete fails with ErrBlobRetained while a blob is retained.
+	ObjectLockMode        string    `yaml:"object_lock_mode"`
+	ObjectLockDays        int       `yaml:"object_lock_days"`
+	ObjectLockRetainUntil time.Time `yaml:"object_lock_retain_until"`
+	// UploadChecksum has S3 verify single-request uploads against a checksum
+	// computed here, rejecting any corrupted in transit: "CRC32C", "SHA256"
+	// or empty for the SDK default. SHA256 reuses the blob ID when the
+	// stored bytes are the plain content. Streamed uploads that can't reuse
+	// the blob ID, and multipart uploads, keep the SDK default.
+	UploadChecksum string `yaml:"upload_checksum"`
+	// SendContentMD5 sends the MD5 of every upload whose bytes are in memory
+	// as Content-MD5, so S3 rejects a body altered in transit, e.g. by a
+	// misbehaving proxy, with ErrBadDigest. That covers all uploads except
+	// streamed single-request ones; multipart uploads send it per part.
+	SendContentMD5 bool `yaml:"send_content_md5"`
+	// AutoDetectContentType sniffs the content type of stored blobs with
+	// http.DetectContentType instead of using application/octet-stream
+	AutoDetectContentType bool `yaml:"auto_detect_content_type"`
+	// BlockedContentTypes rejects stores whose content is sniffed as one of
+	// these media types with ErrBlockedContentType before anything is
+	// uploaded, e.g. application/x-dosexec to refuse Windows executables.
+	BlockedContentTypes []string `yaml:"blocked_content_types"`
+	// DefaultMetadata is attached to every uploaded object, e.g.
+	// service=raven for auditing. Metadata passed to a store overrides it
+	// key by key. Like all metadata it does not affect blob IDs.
+	DefaultMetadata map[string]string `yaml:"default_metadata"`
+	// TenantID, when set, tags every uploaded object tenant=<id> alongside
+	// any explicit tags, e.g. for per-tenant cost allocation. It must be a
+	// valid S3 tag value.
+	TenantID string `yaml:"tenant_id"`
+	// Metrics observes every S3 operation; defaults to NoopMetrics
+	Metrics Metrics `yaml:"-"`
+	// Logger receives diagnostics such as retries and swallowed errors;
+	// defaults to NoopLogger
+	Logger Logger `yaml:"-"`
+	// RateLimiter is waited on once per operation, e.g. to cap each
+	// tenant's operation rate, with operations attributed to TenantID unless
+	// their context sets another with WithTenantID; defaults to
+	// NoopRateLimiter. Operations wait before taking one of
+	// MaxConcurrentOps' slots.
+	RateLimiter RateLimiter `yaml:"-"`
+	// Events, if set, receives an Event after every operation, e.g. for an
+	// audit log. Sends never block; events are dropped while the channel is
+	// full, so give it a buffer and drain it promptly.
+	Events chan<- Event `yaml:"-"`
+	// CircuitBreakerThreshold opens a circuit breaker after this many
+	// consecutive requests fail with network errors, timeouts or 5xx
+	// responses, so that while the backend is down operations fail fast with
+	// ErrCircuitOpen instead of each waiting out its timeout. After
+	// CircuitBreakerCooldown seconds (default 30) one request is let through
+	// as a probe; if it fails the cooldown doubles, up to 16 times the
+	// configured value. Zero disables the breaker. Each Failover replica
+	// gets a breaker of its own, and reads fail over past a primary whose
+	// breaker is open.
+	CircuitBreakerThreshold int `yaml:"circuit_breaker_threshold"`
+	CircuitBreakerCooldown  int `yaml:"circuit_breaker_cooldown"`
+	// Failover lists read replicas that reads fall back to when this bucket
+	// is unavailable
+	Failover FailoverConfig `yaml:"failover"`
+	// MaxConcurrentOps caps how many operations run against S3 at once;
+	// further callers wait for a slot. Zero means unbounded.
+	MaxConcurrentOps int `yaml:"max_concurrent_ops"`
+	// DedupCacheSize keeps an in-memory LRU of this many recently stored blob
+	// IDs so storing them again skips S3 entirely. Zero disables the cache.
+	DedupCacheSize int `yaml:"dedup_cache_size"`
+	// DryRun validates operations without writing anything, e.g. to audit a
+	// migration: stores check size limits and existence but skip the upload
+	// (see StoreResult.Deduplicated), and deletes only check the blob exists
+	DryRun bool `yaml:"dry_run"`
+	// ShardDepth nests blob keys under this many two-character prefixes of
+	// the blob ID to spread load across key ranges, e.g. 2 stores blobs at
+	// blobs/ab/cd/<sha256>. Zero keeps the flat blobs/<sha256> layout. Changing
+	// it on an existing bucket orphans stored blobs until they are copied to
+	// their new keys, e.g. by listing with the old depth and calling Copy into
+	// a storage configured with the new one.
+	ShardDepth int `yaml:"shard_depth"`
+	// HashAlgorithm is the hash blob IDs are derived from: "sha256" (default)
+	// or "sha512". Blobs stored under one algorithm aren't found under the
+	// other, so changing it on an existing bucket starts a fresh namespace.
+	HashAlgorithm string `yaml:"hash_algorithm"`
+	// KeySuffix is appended to every object key, e.g. ".bin" for pipelines
+	// that key off file extensions. Blob IDs returned to callers stay bare.
+	// Like ShardDepth, changing it on an existing bucket orphans stored blobs.
+	KeySuffix string `yaml:"key_suffix"`
+	// KeyPrefix is prepended to every object key, e.g. "tenant-42/" so each
+	// tenant's blobs sit under their own prefix and can be removed with
+	// Purge. A trailing "/" is added if missing.
+	KeyPrefix string `yaml:"key_prefix"`
+	// AllowRootPurge lets Purge run without a KeyPrefix, deleting every
+	// object in the bucket
+	AllowRootPurge bool `yaml:"allow_root_purge"`
+	// BucketCheckTTL lets WarmUp and HealthCheck reuse a successful bucket
+	// check for this long instead of sending HeadBucket every time, e.g. for
+	// high-frequency readiness probes. An outage is then noticed within the
+	// TTL; a failed check is never cached. Zero checks every time.
+	BucketCheckTTL time.Duration `yaml:"bucket_check_ttl"`
+	// SkipBucketCreation skips creating the bucket at startup, for
+	// deployments that pre-provision it and lack CreateBucket permission
+	SkipBucketCreation bool `yaml:"skip_bucket_creation"`
+	// ExpressOneZone stores blobs in an S3 Express One Zone directory bucket
+	// in AvailabilityZone, a zone ID such as "use1-az4", for low-latency
+	// access, e.g. a hot attachment cache. Bucket is given the directory
+	// bucket suffix "--<zone>--x-s3" if it lacks it. Directory buckets
+	// differ from general purpose buckets: they only list whole "/"
+	// prefixes, so List and Stats page through one prefix instead of
+	// sixteen concurrently and ListFunc's order is unspecified; and they
+	// keep a single copy in one zone, so losing the zone loses blobs that
+	// later stores skipped uploading as duplicates. Object lock, tags
+	// (including TenantID), ACLs and SSE-C are not supported.
+	ExpressOneZone   bool   `yaml:"express_one_zone"`
+	AvailabilityZone string `yaml:"availability_zone"`
+	// RejectEmpty makes stores of empty content fail with ErrEmptyContent.
+	// Otherwise empty content is stored like any other, as a zero-byte object
+	// under the hash of the empty string (e3b0c442...b855 for sha256).
+	RejectEmpty bool `yaml:"reject_empty"`
+	// UsePathStyle chooses path-style (endpoint/bucket/key) over
+	// virtual-hosted-style (bucket.endpoint/key) addressing. Unset, it
+	// defaults to path-style for custom endpoints such as MinIO and to the
+	// SDK default for AWS.
+	UsePathStyle *bool `yaml:"use_path_style"`
+	// Provider presets defaults for an S3-compatible provider so it works
+	// without tuning; settings made explicitly take precedence. Every
+	// provider other than "aws" requires Endpoint.
+	//   - "aws" (or empty): SDK defaults
+	//   - "minio": UsePathStyle true
+	//   - "r2" (Cloudflare R2): UsePathStyle true, Region "auto",
+	//     SkipBucketCreation, and request checksums only when required
+	//   - "spaces" (DigitalOcean Spaces): UsePathStyle false, and request
+	//     checksums only when required
+	Provider string `yaml:"provider"`
+}
+
+// s3ClientOptions applies the endpoint, endpoint resolver, addressing style,
+// signing region, provider checksum behavior and HTTP client from cfg to the
+// S3 client
+func s3ClientOptions(cfg Config) func(*s3.Options) {
+	return func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			o.UsePathStyle = true
+		}
+		if cfg.UsePathStyle != nil {
+			o.UsePathStyle = *cfg.UsePathStyle
+		}
+		if cfg.SigningRegion != "" && cfg.SigningRegion != cfg.Region {
+			s3.WithSigV4SigningRegion(cfg.SigningRegion)(o)
+		}
+		if cfg.EndpointResolver != nil {
+			o.EndpointResolverV2 = cfg.EndpointResolver
+		}
+		applyProviderChecksums(cfg.Provider, o)
+		o.HTTPClient = cfg.HTTPClient
+	}
 }
 
+const (
+	// ThrottlePolicyFail fails Store when the deduplication check is throttled
+	ThrottlePolicyFail = "fail"
+	// ThrottlePolicyUpload skips a throttled deduplication check and uploads
+	// anyway, which is safe because blob keys are content addressed
+	ThrottlePolicyUpload = "upload"
+)
+
 // NewS3BlobStorage creates a new S3 blob storage instance
 func NewS3BlobStorage(cfg Config) (*S3BlobStorage, error) {
 	if !cfg.Enabled {
 		return &S3BlobStorage{enabled: false}, nil
 	}
 
-	if cfg.AccessKey == "" || cfg.SecretKey == "" {
-		return nil, fmt.Errorf("S3 access key and secret key are required when blob storage is enabled")
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 
+	applyProviderDefaults(&cfg)
+
 	if cfg.Bucket == "" {
 		cfg.Bucket = "email-attachments"
 	}
+	if cfg.ExpressOneZone {
+		cfg.Bucket = expressBucketName(cfg.Bucket, cfg.AvailabilityZone)
+	}
 
 	if cfg.Region == "" {
 		cfg.Region = "us-east-1"
@@ -70,38 +495,212 @@ func NewS3BlobStorage(cfg Config) (*S3BlobStorage, error) {
 		cfg.Timeout = 30
 	}
 
-	ctx := context.Background()
+	if cfg.IntegrityRetries == 0 {
+		cfg.IntegrityRetries = defaultIntegrityRetries
+	}
 
-	awsCfg, err := config.LoadDefaultConfig(ctx,
+	if cfg.ResumeRetries == 0 {
+		cfg.ResumeRetries = defaultResumeRetries
+	}
+
+	if cfg.MultipartThreshold == 0 {
+		cfg.MultipartThreshold = defaultMultipartThreshold
+	}
+
+	if cfg.MultipartPartSize == 0 {
+		cfg.MultipartPartSize = defaultMultipartPartSize
+	}
+
+	if cfg.CopyBufferSize == 0 {
+		cfg.CopyBufferSize = defaultCopyBufferSize
+	}
+
+	if cfg.CircuitBreakerCooldown == 0 {
+		cfg.CircuitBreakerCooldown = defaultCircuitBreakerCooldown
+	}
+	breaker := newCircuitBreaker(cfg.CircuitBreakerThreshold, time.Duration(cfg.CircuitBreakerCooldown)*time.Second)
+
+	if cfg.DedupThrottlePolicy == "" {
+		cfg.DedupThrottlePolicy = ThrottlePolicyFail
+	}
+
+	if cfg.Compression == "" {
+		cfg.Compression = CompressionNone
+	}
+
+	if cfg.BucketOwnerFullControl {
+		cfg.ACL = string(types.ObjectCannedACLBucketOwnerFullControl)
+	}
+
+	if cfg.HashAlgorithm == "" {
+		cfg.HashAlgorithm = HashSHA256
+	}
+
+	var aead cipher.AEAD
+	if cfg.EncryptionKey != nil {
+		var err error
+		if aead, err = newBlobCipher(cfg.EncryptionKey); err != nil {
+			return nil, fmt.Errorf("invalid encryption key: %w", err)
+		}
+	}
+
+	if cfg.Metrics == nil {
+		cfg.Metrics = NoopMetrics{}
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = NoopLogger{}
+	}
+	if cfg.RateLimiter == nil {
+		cfg.RateLimiter = NoopRateLimiter{}
+	}
+
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = newDefaultHTTPClient()
+	}
+
+	loadOpts := []func(*config.LoadOptions) error{
 		config.WithRegion(cfg.Region),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+		config.WithRetryer(func() aws.Retryer {
+			return newRetryer(cfg)
+		}),
+	}
+	if cfg.MaxAttempts > 0 {
+		loadOpts = append(loadOpts, config.WithRetryMaxAttempts(cfg.MaxAttempts))
+	}
+	switch {
+	case cfg.Profile != "":
+		loadOpts = append(loadOpts, config.WithSharedConfigProfile(cfg.Profile))
+	case !cfg.UseDefaultCredentials:
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
 			cfg.AccessKey,
 			cfg.SecretKey,
 			"",
-		)),
-	)
+		)))
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(context.Background(), loadOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
-	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
-		if cfg.Endpoint != "" {
-			o.BaseEndpoint = aws.String(cfg.Endpoint)
+	client := s3.NewFromConfig(awsCfg, s3ClientOptions(cfg))
+
+	if cfg.Failover.Timeout == 0 {
+		cfg.Failover.Timeout = defaultFailoverTimeout
+	}
+	replicas := make([]replica, len(cfg.Failover.Replicas))
+	for i, r := range cfg.Failover.Replicas {
+		replicaCfg := cfg
+		replicaCfg.Endpoint = r.Endpoint
+		if r.Region != "" {
+			replicaCfg.Region = r.Region
 		}
-		o.UsePathStyle = true
-	})
+		if r.Bucket == "" {
+			r.Bucket = cfg.Bucket
+		}
+		name := r.Endpoint
+		if name == "" {
+			name = replicaCfg.Region
+		}
+
+		// Each replica has its own circuit breaker, so failover can skip a
+		// location that is down without giving up on the others
+		replicaClient := s3.NewFromConfig(awsCfg, s3ClientOptions(replicaCfg), func(o *s3.Options) {
+			o.Region = replicaCfg.Region
+		})
+		replicaBreaker := newCircuitBreaker(cfg.CircuitBreakerThreshold, time.Duration(cfg.CircuitBreakerCooldown)*time.Second)
+		replicas[i] = replica{
+			client:  &instrumentedClient{next: replicaClient, metrics: cfg.Metrics, breaker: replicaBreaker},
+			bucket:  r.Bucket,
+			name:    name,
+			breaker: replicaBreaker,
+		}
+	}
+
+	// Close cancels ctx to stop in-flight operations
+	ctx, cancel := context.WithCancel(context.Background())
 
 	storage := &S3BlobStorage{
-		client:  client,
-		bucket:  cfg.Bucket,
-		enabled: true,
-		ctx:     ctx,
-		timeout: time.Duration(cfg.Timeout) * time.Second,
+		client:    &instrumentedClient{next: client, metrics: cfg.Metrics, breaker: breaker},
+		presigner: s3.NewPresignClient(client),
+		bucket:    cfg.Bucket,
+		enabled:   true,
+		ctx:       ctx,
+		cancel:    cancel,
+		timeout:   time.Duration(cfg.Timeout) * time.Second,
+		clock:     time.Now,
+
+		bucketCheckTTL: cfg.BucketCheckTTL,
+
+		uploadTimeout:   time.Duration(cfg.UploadTimeout) * time.Second,
+		downloadTimeout: time.Duration(cfg.DownloadTimeout) * time.Second,
+		metadataTimeout: time.Duration(cfg.MetadataTimeout) * time.Second,
+
+		httpClient: cfg.HTTPClient,
+
+		endpoint:  cfg.Endpoint,
+		region:    cfg.Region,
+		accessKey: cfg.AccessKey,
+		profile:   cfg.Profile,
+
+		resolvesEndpoint: cfg.EndpointResolver != nil,
+
+		availabilityZone: cfg.AvailabilityZone,
+
+		dedupThrottlePolicy:   cfg.DedupThrottlePolicy,
+		skipDedupCheck:        cfg.SkipDedupCheck,
+		events:                cfg.Events,
+		aead:                  aead,
+		encryptionKeyID:       encryptionKeyID(cfg.EncryptionKey),
+		compression:           cfg.Compression,
+		verifyOnRetrieve:      cfg.VerifyOnRetrieve,
+		verifyMultipart:       cfg.VerifyMultipart,
+		multipartThreshold:    cfg.MultipartThreshold,
+		multipartPartSize:     cfg.MultipartPartSize,
+		maxBlobSize:           cfg.MaxBlobSize,
+		rejectEmpty:           cfg.RejectEmpty,
+		uploadChecksum:        cfg.UploadChecksum,
+		sendContentMD5:        cfg.SendContentMD5,
+		objectLockMode:        cfg.ObjectLockMode,
+		objectLockDays:        cfg.ObjectLockDays,
+		objectLockRetainUntil: cfg.ObjectLockRetainUntil,
+		breaker:               breaker,
+		replicas:              replicas,
+		failoverTimeout:       time.Duration(cfg.Failover.Timeout) * time.Second,
+		integrityRetries:      max(cfg.IntegrityRetries, 0),
+		resumeRetries:         max(cfg.ResumeRetries, 0),
+		referenceCounting:     cfg.ReferenceCounting,
+		sse:                   cfg.ServerSideEncryption,
+		sseKMSKeyID:           cfg.KMSKeyID,
+		sseC:                  newSSECustomerParams(cfg.SSECustomerKey),
+		storageClass:          cfg.StorageClass,
+		acl:                   cfg.ACL,
+		dryRun:                cfg.DryRun,
+		shardDepth:            cfg.ShardDepth,
+		hashAlgorithm:         cfg.HashAlgorithm,
+		keySuffix:             cfg.KeySuffix,
+		keyPrefix:             normalizeKeyPrefix(cfg.KeyPrefix),
+		copyBufferSize:        cfg.CopyBufferSize,
+		allowRootPurge:        cfg.AllowRootPurge,
+		autoDetectContentType: cfg.AutoDetectContentType,
+		blockedContentTypes:   slices.Clone(cfg.BlockedContentTypes),
+		defaultMetadata:       maps.Clone(cfg.DefaultMetadata),
+		tenantID:              cfg.TenantID,
+		limiter:               cfg.RateLimiter,
+		logger:                cfg.Logger,
+	}
+	if cfg.MaxConcurrentOps > 0 {
+		storage.opSlots = make(chan struct{}, cfg.MaxConcurrentOps)
+	}
+	if cfg.DedupCacheSize > 0 {
+		storage.dedupCache = newDedupCache(cfg.DedupCacheSize)
 	}
 
 	// Ensure bucket exists
-	if err := storage.ensureBucket(); err != nil {
-		return nil, fmt.Errorf("failed to ensure bucket exists: %w", err)
+	if !cfg.SkipBucketCreation {
+		if err := storage.ensureBucket(); err != nil {
+			return nil, fmt.Errorf("failed to ensure bucket exists: %w", err)
+		}
 	}
 
 	return storage, nil
@@ -112,143 +711,687 @@ func (s *S3BlobStorage) IsEnabled() bool {
 	return s.enabled
 }
 
+// Bucket returns the name of the bucket blobs are stored in
+func (s *S3BlobStorage) Bucket() string {
+	return s.bucket
+}
+
+// Endpoint returns the configured S3 endpoint, or "" when using AWS's
+// default endpoint for the region
+func (s *S3BlobStorage) Endpoint() string {
+	return s.endpoint
+}
+
+// blobKey returns the object key for a blob ID
+func (s *S3BlobStorage) blobKey(blobID string) string {
+	if s.shardDepth == 0 || len(blobID) < 2*s.shardDepth {
+		return s.keyPrefix + blobKeyPrefix + blobID + s.keySuffix
+	}
+
+	var b strings.Builder
+	b.WriteString(s.keyPrefix)
+	b.WriteString(blobKeyPrefix)
+	for i := 0; i < s.shardDepth; i++ {
+		b.WriteString(blobID[2*i : 2*i+2])
+		b.WriteByte('/')
+	}
+	b.WriteString(blobID)
+	b.WriteString(s.keySuffix)
+	return b.String()
+}
+
+// copySource returns the CopySource naming key in bucket, with the key
+// URL-encoded as S3 requires
+func copySource(bucket, key string) *string {
+	return aws.String(bucket + "/" + url.PathEscape(key))
+}
+
+// normalizeKeyPrefix ends a non-empty key prefix with "/"
+func normalizeKeyPrefix(prefix string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	return prefix + "/"
+}
+
+// blobIDFromKey returns the blob ID stored at key, ignoring any shard
+// prefixes and key suffix
+func (s *S3BlobStorage) blobIDFromKey(key string) string {
+	return strings.TrimSuffix(path.Base(key), s.keySuffix)
+}
+
+// encodesContent reports whether stored bytes differ from the original
+// content, in which case streaming uploads must buffer the content first
+func (s *S3BlobStorage) encodesContent() bool {
+	return s.aead != nil || s.compression == CompressionGzip
+}
+
+// encodeContent transforms content into the bytes stored in S3, compressing
+// and then encrypting it when configured. The returned metadata records the
+// encoding so that decodeContent can reverse it.
+func (s *S3BlobStorage) encodeContent(content []byte) ([]byte, map[string]string, error) {
+	var metadata map[string]string
+	data := content
+
+	if s.compression == CompressionGzip {
+		compressed, err := gzipBlob(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = compressed
+		metadata = map[string]string{metaEncoding: CompressionGzip}
+	}
+
+	if s.aead != nil {
+		ciphertext, err := encryptBlob(s.aead, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encrypt blob: %w", err)
+		}
+		data = ciphertext
+		if metadata == nil {
+			metadata = make(map[string]string, 1)
+		}
+		metadata[metaEncryption] = encryptionAESGCM
+	}
+
+	return data, metadata, nil
+}
+
+// decodeContent reverses encodeContent on the bytes of blobID read from S3,
+// following the encoding recorded on the object rather than this client's
+// configuration, so blobs written with any combination of compression and
+// encryption can be read back. Blobs without an encoding marker are treated
+// as uncompressed.
+func (s *S3BlobStorage) decodeContent(blobID string, data []byte, metadata map[string]string) ([]byte, error) {
+	var legacyErr error
+
+	switch {
+	case metadata[metaEncryption] == encryptionAESGCM:
+		if s.aead == nil {
+			return nil, fmt.Errorf("%w: blob is encrypted but no encryption key is configured", ErrDecryptionFailed)
+		}
+		plaintext, err := decryptBlob(s.aead, data)
+		if err != nil {
+			return nil, err
+		}
+		data = plaintext
+	case metadata[metaEncryption] != "":
+		return nil, fmt.Errorf("unsupported blob encryption %q", metadata[metaEncryption])
+	case s.aead != nil:
+		// Blobs encrypted before the marker was recorded carry none, so try
+		// the key and otherwise accept the bytes only if they are the blob
+		plaintext, err := decryptBlob(s.aead, data)
+		if err != nil {
+			legacyErr = err
+		} else {
+			data = plaintext
+		}
+	}
+
+	if metadata[metaEncoding] == CompressionGzip {
+		decompressed, err := gunzipBlob(data)
+		if err != nil {
+			return nil, errors.Join(legacyErr, err)
+		}
+		data = decompressed
+	}
+
+	if legacyErr != nil && s.computeBlobID(data) != blobID {
+		return nil, legacyErr
+	}
+	return data, nil
+}
+
+// isEncrypted reports whether a stored object may be client-side encrypted,
+// from its metadata or, for blobs that predate the marker, this client's key
+func (s *S3BlobStorage) isEncrypted(metadata map[string]string) bool {
+	return metadata[metaEncryption] != "" || s.aead != nil
+}
+
+// verifyContent checks that content hashes to blobID
+func (s *S3BlobStorage) verifyContent(blobID string, content []byte) error {
+	if actual := s.computeBlobID(content); actual != blobID {
+		return fmt.Errorf("%w: blob %s hashes to %s", ErrIntegrityMismatch, blobID, actual)
+	}
+	return nil
+}
+
 // ensureBucket creates the bucket if it doesn't exist
 func (s *S3BlobStorage) ensureBucket() error {
-	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	ctx, cancel := context.WithTimeout(s.ctx, s.opTimeout(s.metadataTimeout))
 	defer cancel()
 
-	_, err := s.client.CreateBucket(ctx, &s3.CreateBucketInput{
+	input := &s3.CreateBucketInput{
 		Bucket: aws.String(s.bucket),
-	})
+		// Object lock can only be enabled when a bucket is created
+		ObjectLockEnabledForBucket: aws.Bool(s.objectLockMode != ""),
+	}
+	switch {
+	case s.availabilityZone != "":
+		// Directory buckets are located by zone and can't have object lock
+		input.ObjectLockEnabledForBucket = nil
+		input.CreateBucketConfiguration = expressBucketConfiguration(s.availabilityZone)
+	case s.region != "" && s.region != "us-east-1":
+		// us-east-1 is the default location and must not be sent as a
+		// constraint; any other region must be, or the create is rejected
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(s.region),
+		}
+	}
+
+	_, err := s.client.CreateBucket(ctx, input)
 	if err != nil {
-		// Bucket might already exist, which is fine
-		// We can't easily distinguish without more complex error checking
-		// So we'll just log and continue
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "BucketAlreadyOwnedByYou" || apiErr.ErrorCode() == "BucketAlreadyExists") {
+			s.logger.Debugf("blobstorage: bucket %s already exists", s.bucket)
+			return nil
+		}
+		// Creation may be denied to credentials that can still use an
+		// existing bucket, so carry on and let operations surface errors
+		s.logger.Warnf("blobstorage: failed to create bucket %s: %v", s.bucket, err)
 		return nil
 	}
+	s.logger.Debugf("blobstorage: created bucket %s", s.bucket)
 	return nil
 }
 
 // Store stores content in S3 and returns the blob ID (SHA256 hash)
-func (s *S3BlobStorage) Store(content string) (string, error) {
+func (s *S3BlobStorage) Store(content string) (_ string, err error) {
+	var result StoreResult
+	defer s.wrapStoreError("Store", &result, &err)
+	result, err = s.storeResult(s.ctx, []byte(content), putOptions{})
+	return result.BlobID, err
+}
+
+// StoreBytes stores content in S3 and returns the blob ID (SHA256 hash)
+func (s *S3BlobStorage) StoreBytes(content []byte) (_ string, err error) {
+	var result StoreResult
+	defer s.wrapStoreError("StoreBytes", &result, &err)
+	result, err = s.storeResult(s.ctx, content, putOptions{})
+	return result.BlobID, err
+}
+
+// StoreResult describes a stored blob
+type StoreResult struct {
+	BlobID string
+	// Size is the content length in bytes, before any compression or encryption
+	Size int64
+	// Deduplicated is true when identical content was already stored and the
+	// upload was skipped. In dry-run mode, false means the content would have
+	// been uploaded.
+	Deduplicated bool
+}
+
+// StoreV2 stores content like Store, also reporting its size and whether it
+// was deduplicated
+func (s *S3BlobStorage) StoreV2(content string) (_ StoreResult, err error) {
+	var result StoreResult
+	defer s.wrapStoreError("StoreV2", &result, &err)
+	result, err = s.storeResult(s.ctx, []byte(content), putOptions{})
+	return result, err
+}
+
+// StoreDedup stores content like Store, also reporting whether it was
+// uploaded; uploaded is false when identical content was already stored. In
+// dry-run mode, true means the content would have been uploaded.
+func (s *S3BlobStorage) StoreDedup(content string) (blobID string, uploaded bool, err error) {
+	var result StoreResult
+	defer s.wrapStoreError("StoreDedup", &result, &err)
+	result, err = s.storeResult(s.ctx, []byte(content), putOptions{})
+	return result.BlobID, !result.Deduplicated && err == nil, err
+}
+
+// putOptions carries per-call settings applied when a blob is uploaded.
+// None of them affect the blob ID. contentType is resolved with
+// contentTypeFor before uploading.
+type putOptions struct {
+	metadata     map[string]string
+	tags         map[string]string
+	storageClass string
+	contentType  string
+	// checksum is the precomputed UploadChecksum of the stored bytes
+	checksum string
+	// contentMD5 is the base64 MD5 of the stored bytes with SendContentMD5
+	contentMD5 string
+	// expiresAt is set by StoreWithExpiry
+	expiresAt time.Time
+	// progress is set by StoreReaderProgress
+	progress func(written int64)
+}
+
+// store uploads content under its content hash unless it already exists,
+// returning the blob ID. The operation's timeout is applied to ctx.
+func (s *S3BlobStorage) store(ctx context.Context, content []byte, opts putOptions) (string, error) {
+	result, err := s.storeResult(ctx, content, opts)
+	return result.BlobID, err
+}
+
+// storeResult is store reporting the size and whether the upload was skipped
+func (s *S3BlobStorage) storeResult(ctx context.Context, content []byte, opts putOptions) (StoreResult, error) {
 	if !s.enabled {
-		return "", fmt.Errorf("blob storage is not enabled")
+		return StoreResult{}, ErrStorageDisabled
 	}
 
-	// Calculate SHA256 hash to use as blob ID
-	hash := sha256.Sum256([]byte(content))
-	blobID := hex.EncodeToString(hash[:])
+	if err := s.checkSize(int64(len(content))); err != nil {
+		return StoreResult{}, err
+	}
+	if err := s.checkEmpty(int64(len(content))); err != nil {
+		return StoreResult{}, err
+	}
+	if err := s.checkContentType(content); err != nil {
+		return StoreResult{}, err
+	}
 
-	// Use hash as the key for deduplication
-	key := fmt.Sprintf("blobs/%s", blobID)
+	// Hash the content to use as blob ID
+	blobID := s.computeBlobID(content)
+	result := StoreResult{BlobID: blobID, Size: int64(len(content))}
 
-	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	ctx, cancel := context.WithTimeout(ctx, s.opTimeout(s.uploadTimeout))
 	defer cancel()
 
+	if err := s.acquireOp(ctx, "Store"); err != nil {
+		return StoreResult{}, err
+	}
+	defer s.releaseOp()
+
+	if s.referenceCounting && !s.dryRun {
+		if _, _, err := s.updateReferenceCount(ctx, blobID, 1); err != nil {
+			return StoreResult{}, err
+		}
+	}
+
 	// Check if blob already exists
-	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
-	})
-	if err == nil {
+	exists, err := s.dedupExists(ctx, blobID)
+	if err != nil {
+		return StoreResult{}, fmt.Errorf("failed to check blob existence: %w", err)
+	}
+	s.recordStore(exists)
+	if exists {
 		// Blob already exists, return the ID
-		return blobID, nil
+		s.logger.Debugf("blobstorage: blob %s already stored, skipping upload", blobID)
+		result.Deduplicated = true
+		return result, nil
 	}
 
-	var apiErr smithy.APIError
-	if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "NotFound" {
-		return "", fmt.Errorf("failed to check blob existence: %w", err) // Not a "NotFound" error
+	deduplicated, err := s.uploadBlob(ctx, blobID, content, opts)
+	if err != nil {
+		return StoreResult{}, err
 	}
+	result.Deduplicated = deduplicated
+	return result, nil
+}
 
-	// Upload the blob
-	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(s.bucket),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader([]byte(content)),
-		ContentType: aws.String("application/octet-stream"),
-	})
+// uploadBlob encodes and uploads content that the existence check found
+// missing, reporting whether it turned out to be stored concurrently
+func (s *S3BlobStorage) uploadBlob(ctx context.Context, blobID string, content []byte, opts putOptions) (bool, error) {
+	if s.dryRun {
+		s.logger.Debugf("blobstorage: dry run, would store blob %s (%d bytes)", blobID, len(content))
+		return false, nil
+	}
+
+	opts.contentType = s.contentTypeFor(opts, content)
+
+	body, encodingMetadata, err := s.encodeContent(content)
 	if err != nil {
-		return "", fmt.Errorf("failed to upload blob: %w", err)
+		return false, err
+	}
+
+	plainBlobID := ""
+	if !s.encodesContent() {
+		plainBlobID = blobID
+	}
+	opts.checksum = s.uploadChecksumFor(body, plainBlobID)
+	opts.contentMD5 = s.contentMD5For(body)
+
+	// Upload the blob
+	if err := s.upload(ctx, s.blobKey(blobID), bytes.NewReader(body), int64(len(body)), opts, encodingMetadata); err != nil {
+		if errors.Is(err, errConcurrentlyStored) {
+			s.logger.Debugf("blobstorage: blob %s stored concurrently, skipping upload", blobID)
+			s.dedupCache.add(blobID)
+			return true, nil
+		}
+		return false, err
 	}
+	s.dedupCache.add(blobID)
+	s.logger.Debugf("blobstorage: stored blob %s (%d bytes)", blobID, len(body))
 
-	return blobID, nil
+	return false, nil
+}
+
+// checkSize enforces the configured maximum blob size
+func (s *S3BlobStorage) checkSize(size int64) error {
+	if s.maxBlobSize > 0 && size > s.maxBlobSize {
+		return &ErrBlobTooLarge{Size: size, Max: s.maxBlobSize}
+	}
+	return nil
+}
+
+// checkEmpty rejects empty content when RejectEmpty is set
+func (s *S3BlobStorage) checkEmpty(size int64) error {
+	if s.rejectEmpty && size == 0 {
+		return ErrEmptyContent
+	}
+	return nil
+}
+
+// upload writes size bytes of already encoded content to key, using a
+// multipart upload when the size exceeds the multipart threshold
+func (s *S3BlobStorage) upload(ctx context.Context, key string, body io.Reader, size int64, opts putOptions, encodingMetadata map[string]string) error {
+	if s.usesMultipart(size) {
+		if err := s.checkPartCount(size); err != nil {
+			return err
+		}
+		if err := s.uploadMultipart(ctx, key, body, opts, encodingMetadata); err != nil {
+			return uploadError(err)
+		}
+		return nil
+	}
+
+	if opts.progress != nil {
+		body = newProgressReader(body, opts.progress)
+	}
+	input := s.newPutObjectInput(key, body, opts, encodingMetadata)
+	input.ContentLength = aws.Int64(size)
+	s.setUploadChecksum(input, opts.checksum)
+	if opts.contentMD5 != "" {
+		input.ContentMD5 = aws.String(opts.contentMD5)
+	}
+
+	if err := s.putIfAbsent(ctx, input); err != nil {
+		if errors.Is(err, errConcurrentlyStored) {
+			return err
+		}
+		return uploadError(err)
+	}
+	return nil
+}
+
+// usesMultipart reports whether an upload of size bytes is sent as a
+// multipart upload rather than a single PutObject
+func (s *S3BlobStorage) usesMultipart(size int64) bool {
+	return s.multipartThreshold > 0 && size > s.multipartThreshold
+}
+
+// uploadError wraps a failed upload, marking a Content-MD5 mismatch with
+// ErrBadDigest
+func uploadError(err error) error {
+	if isBadDigest(err) {
+		return fmt.Errorf("failed to upload blob: %w: %w", ErrBadDigest, err)
+	}
+	return fmt.Errorf("failed to upload blob: %w", err)
+}
+
+// newPutObjectInput builds the PutObject request for uploading a blob
+func (s *S3BlobStorage) newPutObjectInput(key string, body io.Reader, opts putOptions, encodingMetadata map[string]string) *s3.PutObjectInput {
+	sse, kmsKeyID := s.serverSideEncryption()
+	input := &s3.PutObjectInput{
+		Bucket:                    aws.String(s.bucket),
+		Key:                       aws.String(key),
+		Body:                      body,
+		ContentType:               aws.String(opts.contentType),
+		Metadata:                  s.objectMetadata(opts, encodingMetadata),
+		Expires:                   expiresHeader(opts),
+		Tagging:                   encodeTags(s.objectTags(opts.tags)),
+		ServerSideEncryption:      sse,
+		SSEKMSKeyId:               kmsKeyID,
+		StorageClass:              s.storageClassFor(opts),
+		ACL:                       types.ObjectCannedACL(s.acl),
+		ObjectLockMode:            types.ObjectLockMode(s.objectLockMode),
+		ObjectLockRetainUntilDate: s.retainUntilFor(),
+	}
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = s.sseCustomer()
+	return input
 }
 
 // Retrieve retrieves content from S3 by blob ID
-func (s *S3BlobStorage) Retrieve(blobID string) (string, error) {
+func (s *S3BlobStorage) Retrieve(blobID string) (_ string, err error) {
+	defer s.wrapError("Retrieve", blobID, &err)
 	if !s.enabled {
-		return "", fmt.Errorf("blob storage is not enabled")
+		return "", ErrStorageDisabled
 	}
 
-	key := fmt.Sprintf("blobs/%s", blobID)
+	if err := s.validateBlobID(blobID); err != nil {
+		return "", err
+	}
 
-	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	ctx, cancel := context.WithTimeout(s.ctx, s.opTimeout(s.downloadTimeout))
 	defer cancel()
 
-	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
-	})
+	if err := s.acquireReadOp(ctx, "Retrieve"); err != nil {
+		return "", err
+	}
+	defer s.releaseOp()
+
+	data, err := s.retrieve(ctx, blobID)
 	if err != nil {
-		return "", fmt.Errorf("failed to retrieve blob: %w", err)
+		return "", err
 	}
-	defer func() {
-		if closeErr := result.Body.Close(); closeErr != nil {
-			// Log error but don't override the main return error
-			_ = closeErr
-		}
-	}()
+	return string(data), nil
+}
+
+// retrieve downloads and decodes a blob, verifying it when configured
+func (s *S3BlobStorage) retrieve(ctx context.Context, blobID string) ([]byte, error) {
+	if s.verifyOnRetrieve {
+		return s.retrieveVerified(ctx, blobID)
+	}
+	return s.download(ctx, blobID)
+}
 
-	data, err := io.ReadAll(result.Body)
+// download makes a single attempt at downloading and decoding a blob
+func (s *S3BlobStorage) download(ctx context.Context, blobID string) ([]byte, error) {
+	result, _, err := s.getObject(ctx, blobID)
 	if err != nil {
-		return "", fmt.Errorf("failed to read blob data: %w", err)
+		return nil, err
 	}
+	defer s.closeBody(result.Body, s.blobKey(blobID))
 
-	return string(data), nil
+	return s.readBlob(ctx, blobID, result)
+}
+
+// readBlob reads a downloaded blob body in full, stopping early if ctx is
+// done, then decodes and optionally verifies it
+func (s *S3BlobStorage) readBlob(ctx context.Context, blobID string, result *s3.GetObjectOutput) ([]byte, error) {
+	data, err := io.ReadAll(&contextReader{ctx: ctx, r: result.Body})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob data: %w", err)
+	}
+
+	data, err = s.decodeContent(blobID, data, result.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.verifyOnRetrieve {
+		if err := s.verifyContent(blobID, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
 }
 
-// Delete deletes a blob from S3 (optional, for cleanup)
-func (s *S3BlobStorage) Delete(blobID string) error {
+// Delete deletes a blob from S3 (optional, for cleanup). Deleting a blob that
+// is already gone succeeds, so cleanups can safely be retried; use
+// DeleteStrict to be told about missing blobs. With reference counting
+// enabled it removes one reference and only deletes the blob once no
+// references remain. With ObjectLockMode set it fails with ErrBlobRetained
+// while the blob's retention period lasts.
+func (s *S3BlobStorage) Delete(blobID string) (err error) {
+	defer s.wrapError("Delete", blobID, &err)
 	if !s.enabled {
-		return fmt.Errorf("blob storage is not enabled")
+		return ErrStorageDisabled
+	}
+
+	return s.delete(s.ctx, blobID)
+}
+
+// delete is Delete without the Event, for operations built on it. The
+// operation's timeout is applied to ctx.
+func (s *S3BlobStorage) delete(ctx context.Context, blobID string) error {
+	if err := s.validateBlobID(blobID); err != nil {
+		return err
 	}
 
-	key := fmt.Sprintf("blobs/%s", blobID)
+	if s.dryRun {
+		return s.dryRunDelete(ctx, blobID, false)
+	}
+
+	s.dedupCache.remove(blobID)
+
+	if s.referenceCounting {
+		_, err := s.removeReference(ctx, blobID)
+		return err
+	}
+
+	key := s.blobKey(blobID)
 
-	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	ctx, cancel := context.WithTimeout(ctx, s.opTimeout(s.metadataTimeout))
 	defer cancel()
 
+	if err := s.acquireOp(ctx, "Delete"); err != nil {
+		return err
+	}
+	defer s.releaseOp()
+
+	if s.objectLockMode != "" {
+		// A plain delete of a locked object only adds a delete marker, so
+		// check the retention to fail clearly instead
+		if err := s.checkRetention(ctx, blobID, key); err != nil {
+			return err
+		}
+	}
+
 	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 	})
 	if err != nil {
+		// S3 itself reports success for missing keys, but some compatible
+		// backends answer NoSuchKey
+		if isNotFound(err) {
+			return nil
+		}
 		return fmt.Errorf("failed to delete blob: %w", err)
 	}
 
 	return nil
 }
 
+// DeleteStrict deletes a blob like Delete, but fails with ErrBlobNotFound if
+// the blob does not exist. The existence check and the delete are separate
+// requests, so a concurrent delete between them goes unreported.
+func (s *S3BlobStorage) DeleteStrict(blobID string) (err error) {
+	defer s.wrapError("DeleteStrict", blobID, &err)
+	if !s.enabled {
+		return ErrStorageDisabled
+	}
+
+	if s.dryRun {
+		if err := s.validateBlobID(blobID); err != nil {
+			return err
+		}
+		return s.dryRunDelete(s.ctx, blobID, true)
+	}
+
+	exists, err := s.exists(s.ctx, blobID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("failed to delete blob %s: %w", blobID, ErrBlobNotFound)
+	}
+	return s.delete(s.ctx, blobID)
+}
+
+// dryRunDelete checks whether blobID exists in place of deleting it. A missing
+// blob is only an error when strict.
+func (s *S3BlobStorage) dryRunDelete(ctx context.Context, blobID string, strict bool) error {
+	exists, err := s.exists(ctx, blobID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if strict {
+			return fmt.Errorf("failed to delete blob %s: %w", blobID, ErrBlobNotFound)
+		}
+		s.logger.Debugf("blobstorage: dry run, blob %s is already absent", blobID)
+		return nil
+	}
+
+	s.logger.Debugf("blobstorage: dry run, would delete blob %s", blobID)
+	return nil
+}
+
 // Exists checks if a blob exists in S3
-func (s *S3BlobStorage) Exists(blobID string) (bool, error) {
+func (s *S3BlobStorage) Exists(blobID string) (_ bool, err error) {
+	defer s.wrapError("Exists", blobID, &err)
 	if !s.enabled {
-		return false, fmt.Errorf("blob storage is not enabled")
+		return false, ErrStorageDisabled
 	}
 
-	key := fmt.Sprintf("blobs/%s", blobID)
+	return s.exists(s.ctx, blobID)
+}
 
-	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+// exists is Exists without the Event, for operations built on it. The
+// operation's timeout is applied to ctx.
+func (s *S3BlobStorage) exists(ctx context.Context, blobID string) (bool, error) {
+	if err := s.validateBlobID(blobID); err != nil {
+		return false, err
+	}
+
+	key := s.blobKey(blobID)
+
+	ctx, cancel := context.WithTimeout(ctx, s.opTimeout(s.metadataTimeout))
 	defer cancel()
 
-	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
-	})
+	if err := s.acquireOp(ctx, "Exists"); err != nil {
+		return false, err
+	}
+	defer s.releaseOp()
+
+	return s.objectExists(ctx, key)
+}
+
+// dedupExists runs the existence check that lets Store skip uploading content
+// that is already stored, consulting the dedup cache first and applying the
+// configured throttle policy. With SkipDedupCheck every blob is reported
+// missing so it is uploaded again.
+func (s *S3BlobStorage) dedupExists(ctx context.Context, blobID string) (bool, error) {
+	if s.skipDedupCheck {
+		return false, nil
+	}
+	if s.dedupCache.contains(blobID) {
+		return true, nil
+	}
+
+	exists, err := s.objectExists(ctx, s.blobKey(blobID))
+	if s.uploadsThrottled(err) {
+		return false, nil
+	}
+	if exists {
+		s.dedupCache.add(blobID)
+	}
+	return exists, err
+}
+
+// uploadsThrottled reports whether a failed deduplication check should be
+// treated as the blob being missing, which DedupThrottlePolicy "upload" does
+// for throttling since uploading identical content to the same key is
+// idempotent
+func (s *S3BlobStorage) uploadsThrottled(err error) bool {
+	return err != nil && s.dedupThrottlePolicy == ThrottlePolicyUpload && isThrottleError(err)
+}
+
+// objectExists checks if an object exists via HeadObject
+func (s *S3BlobStorage) objectExists(ctx context.Context, key string) (bool, error) {
+	_, err := s.client.HeadObject(ctx, s.headObjectInput(key))
 	if err != nil {
-		var apiErr smithy.APIError
-		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotFound" {
+		if isNotFound(err) {
 			return false, nil // Not found is not an error in this context
 		}
+		if s.isSSECustomerKeyError(err) {
+			return false, fmt.Errorf("%w: %w", ErrSSECustomerKey, err)
+		}
 		return false, err // Propagate other errors
 	}
 	return true, nil
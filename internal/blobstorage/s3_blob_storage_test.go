@@ -6,22 +6,45 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
+	smithyendpoints "github.com/aws/smithy-go/endpoints"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 )
 
 // mockS3Client is a mock implementation of the S3 client for testing
 type mockS3Client struct {
-	createBucketFunc func(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error)
-	putObjectFunc    func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
-	getObjectFunc    func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
-	headObjectFunc   func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
-	deleteObjectFunc func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	createBucketFunc  func(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error)
+	headBucketFunc    func(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+	putObjectFunc     func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	getObjectFunc     func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	headObjectFunc    func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	deleteObjectFunc  func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	deleteObjectsFunc func(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+	listObjectsFunc   func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+
+	createMultipartUploadFunc   func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	uploadPartFunc              func(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	completeMultipartUploadFunc func(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	abortMultipartUploadFunc    func(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+
+	getObjectTaggingFunc func(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
+	putObjectTaggingFunc func(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error)
+
+	copyObjectFunc    func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	restoreObjectFunc func(ctx context.Context, params *s3.RestoreObjectInput, optFns ...func(*s3.Options)) (*s3.RestoreObjectOutput, error)
 }
 
 func (m *mockS3Client) CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
@@ -31,6 +54,13 @@ func (m *mockS3Client) CreateBucket(ctx context.Context, params *s3.CreateBucket
 	return &s3.CreateBucketOutput{}, nil
 }
 
+func (m *mockS3Client) HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	if m.headBucketFunc != nil {
+		return m.headBucketFunc(ctx, params, optFns...)
+	}
+	return &s3.HeadBucketOutput{}, nil
+}
+
 func (m *mockS3Client) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
 	if m.putObjectFunc != nil {
 		return m.putObjectFunc(ctx, params, optFns...)
@@ -59,14 +89,203 @@ func (m *mockS3Client) DeleteObject(ctx context.Context, params *s3.DeleteObject
 	return &s3.DeleteObjectOutput{}, nil
 }
 
+func (m *mockS3Client) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	if m.listObjectsFunc != nil {
+		return m.listObjectsFunc(ctx, params, optFns...)
+	}
+	return &s3.ListObjectsV2Output{}, nil
+}
+
+func (m *mockS3Client) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	if m.deleteObjectsFunc != nil {
+		return m.deleteObjectsFunc(ctx, params, optFns...)
+	}
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+func (m *mockS3Client) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	if m.createMultipartUploadFunc != nil {
+		return m.createMultipartUploadFunc(ctx, params, optFns...)
+	}
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-id")}, nil
+}
+
+func (m *mockS3Client) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	if m.uploadPartFunc != nil {
+		return m.uploadPartFunc(ctx, params, optFns...)
+	}
+	return &s3.UploadPartOutput{}, nil
+}
+
+func (m *mockS3Client) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	if m.completeMultipartUploadFunc != nil {
+		return m.completeMultipartUploadFunc(ctx, params, optFns...)
+	}
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (m *mockS3Client) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	if m.abortMultipartUploadFunc != nil {
+		return m.abortMultipartUploadFunc(ctx, params, optFns...)
+	}
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (m *mockS3Client) GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	if m.getObjectTaggingFunc != nil {
+		return m.getObjectTaggingFunc(ctx, params, optFns...)
+	}
+	return &s3.GetObjectTaggingOutput{}, nil
+}
+
+func (m *mockS3Client) PutObjectTagging(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+	if m.putObjectTaggingFunc != nil {
+		return m.putObjectTaggingFunc(ctx, params, optFns...)
+	}
+	return &s3.PutObjectTaggingOutput{}, nil
+}
+
+func (m *mockS3Client) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	if m.copyObjectFunc != nil {
+		return m.copyObjectFunc(ctx, params, optFns...)
+	}
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (m *mockS3Client) RestoreObject(ctx context.Context, params *s3.RestoreObjectInput, optFns ...func(*s3.Options)) (*s3.RestoreObjectOutput, error) {
+	if m.restoreObjectFunc != nil {
+		return m.restoreObjectFunc(ctx, params, optFns...)
+	}
+	return &s3.RestoreObjectOutput{}, nil
+}
+
+// storedObject is an object held by the in-memory bucket mock
+type storedObject struct {
+	body         []byte
+	metadata     map[string]string
+	etag         string
+	contentType  string
+	lastModified time.Time
+}
+
+// newBucketMock returns a mock backed by an in-memory bucket, for tests that
+// need Store and Retrieve to round-trip
+func newBucketMock() (*mockS3Client, map[string]*storedObject) {
+	objects := make(map[string]*storedObject)
+	writes := 0
+	nextETag := func() string {
+		writes++
+		return fmt.Sprintf("\"etag-%d\"", writes)
+	}
+	mock := &mockS3Client{
+		headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			obj, ok := objects[*params.Key]
+			if !ok {
+				return nil, &smithy.GenericAPIError{Code: "NotFound"}
+			}
+			return &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(obj.body))), Metadata: obj.metadata, ETag: aws.String(obj.etag), ContentType: aws.String(obj.contentType), LastModified: aws.Time(obj.lastModified)}, nil
+		},
+		putObjectFunc: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			existing, ok := objects[*params.Key]
+			if aws.ToString(params.IfNoneMatch) == "*" && ok {
+				return nil, &smithy.GenericAPIError{Code: "PreconditionFailed"}
+			}
+			if params.IfMatch != nil && (!ok || existing.etag != *params.IfMatch) {
+				return nil, &smithy.GenericAPIError{Code: "PreconditionFailed"}
+			}
+			data, err := io.ReadAll(params.Body)
+			if err != nil {
+				return nil, err
+			}
+			obj := &storedObject{body: data, metadata: params.Metadata, etag: nextETag(), contentType: aws.ToString(params.ContentType), lastModified: time.Now()}
+			objects[*params.Key] = obj
+			return &s3.PutObjectOutput{ETag: aws.String(obj.etag)}, nil
+		},
+		getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			obj, ok := objects[*params.Key]
+			if !ok {
+				return nil, &smithy.GenericAPIError{Code: "NoSuchKey"}
+			}
+			body := obj.body
+			if params.Range != nil {
+				var start, end int
+				if _, err := fmt.Sscanf(*params.Range, "bytes=%d-%d", &start, &end); err != nil {
+					return nil, err
+				}
+				if start >= len(body) {
+					return nil, &smithy.GenericAPIError{Code: "InvalidRange"}
+				}
+				body = body[start:min(end+1, len(body))]
+			}
+			return &s3.GetObjectOutput{
+				Body:          io.NopCloser(bytes.NewReader(body)),
+				ContentLength: aws.Int64(int64(len(body))),
+				Metadata:      obj.metadata,
+				ETag:          aws.String(obj.etag),
+				ContentType:   aws.String(obj.contentType),
+			}, nil
+		},
+		deleteObjectFunc: func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+			if obj, ok := objects[*params.Key]; ok && params.IfMatch != nil && obj.etag != *params.IfMatch {
+				return nil, &smithy.GenericAPIError{Code: "PreconditionFailed"}
+			}
+			delete(objects, *params.Key)
+			return &s3.DeleteObjectOutput{}, nil
+		},
+	}
+
+	// Multipart uploads are assembled from their parts on completion
+	uploads := make(map[string]map[int32][]byte)
+	uploadMetadata := make(map[string]map[string]string)
+	mock.createMultipartUploadFunc = func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+		uploadID := fmt.Sprintf("upload-%d", len(uploads)+1)
+		uploads[uploadID] = make(map[int32][]byte)
+		uploadMetadata[uploadID] = params.Metadata
+		return &s3.CreateMultipartUploadOutput{UploadId: aws.String(uploadID)}, nil
+	}
+	mock.uploadPartFunc = func(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+		data, err := io.ReadAll(params.Body)
+		if err != nil {
+			return nil, err
+		}
+		uploads[*params.UploadId][*params.PartNumber] = data
+		return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf("etag-%d", *params.PartNumber))}, nil
+	}
+	mock.completeMultipartUploadFunc = func(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+		var body []byte
+		for _, part := range params.MultipartUpload.Parts {
+			body = append(body, uploads[*params.UploadId][*part.PartNumber]...)
+		}
+		objects[*params.Key] = &storedObject{body: body, metadata: uploadMetadata[*params.UploadId], etag: nextETag()}
+		delete(uploads, *params.UploadId)
+		return &s3.CompleteMultipartUploadOutput{}, nil
+	}
+	mock.abortMultipartUploadFunc = func(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+		delete(uploads, *params.UploadId)
+		return &s3.AbortMultipartUploadOutput{}, nil
+	}
+
+	return mock, objects
+}
+
+// testBlobID returns the expected blob ID for content
+func testBlobID(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
+
 // Helper function to create a mock S3BlobStorage for testing
 func newMockS3BlobStorage(mock S3Api, bucket string, enabled bool) *S3BlobStorage {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &S3BlobStorage{
 		client:  mock,
 		bucket:  bucket,
 		enabled: enabled,
-		ctx:     context.Background(),
+		ctx:     ctx,
+		cancel:  cancel,
 		timeout: 30 * time.Second,
+		clock:   time.Now,
+		logger:  NoopLogger{},
 	}
 }
 
@@ -102,6 +321,36 @@ func TestNewS3BlobStorage(t *testing.T) {
 			expectError: true,
 			errorMsg:    "S3 access key and secret key are required",
 		},
+		{
+			name: "default credential chain without static keys",
+			config: Config{
+				Enabled:               true,
+				UseDefaultCredentials: true,
+			},
+			expectError: false,
+		},
+		{
+			name: "invalid dedup throttle policy",
+			config: Config{
+				Enabled:             true,
+				AccessKey:           "access",
+				SecretKey:           "secret",
+				DedupThrottlePolicy: "retry",
+			},
+			expectError: true,
+			errorMsg:    "invalid dedup throttle policy",
+		},
+		{
+			name: "negative operation timeout",
+			config: Config{
+				Enabled:         true,
+				AccessKey:       "access",
+				SecretKey:       "secret",
+				MetadataTimeout: -1,
+			},
+			expectError: true,
+			errorMsg:    "operation timeouts must not be negative",
+		},
 		{
 			name: "valid config with defaults",
 			config: Config{
@@ -167,6 +416,233 @@ func TestNewS3BlobStorage(t *testing.T) {
 	}
 }
 
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestNewS3BlobStorageCustomHTTPClient(t *testing.T) {
+	var requests []string
+	httpClient := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			requests = append(requests, req.Method+" "+req.URL.Path)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}, nil
+		}),
+	}
+
+	_, err := NewS3BlobStorage(Config{
+		Enabled:    true,
+		Endpoint:   "http://localhost:9000",
+		AccessKey:  "test-key",
+		SecretKey:  "test-secret",
+		Bucket:     "test-bucket",
+		HTTPClient: httpClient,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(requests) != 1 || requests[0] != "PUT /test-bucket" {
+		t.Errorf("expected the bucket creation to go through the custom client, got %v", requests)
+	}
+}
+
+func TestNewS3BlobStorageProfile(t *testing.T) {
+	dir := t.TempDir()
+	credentialsFile := filepath.Join(dir, "credentials")
+	profiles := "[default]\naws_access_key_id = default-key\naws_secret_access_key = default-secret\n\n" +
+		"[ci]\naws_access_key_id = ci-key\naws_secret_access_key = ci-secret\n"
+	if err := os.WriteFile(credentialsFile, []byte(profiles), 0o600); err != nil {
+		t.Fatalf("failed to write credentials file: %v", err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+
+	var authorization string
+	httpClient := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			authorization = req.Header.Get("Authorization")
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+		}),
+	}
+
+	_, err := NewS3BlobStorage(Config{
+		Enabled:    true,
+		Endpoint:   "http://localhost:9000",
+		Profile:    "ci",
+		HTTPClient: httpClient,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(authorization, "Credential=ci-key/") {
+		t.Errorf("expected requests to be signed with the profile's key, got Authorization %q", authorization)
+	}
+
+	_, err = NewS3BlobStorage(Config{
+		Enabled:   true,
+		Profile:   "ci",
+		AccessKey: "test-key",
+		SecretKey: "test-secret",
+	})
+	if err == nil || !strings.Contains(err.Error(), "conflicts with static access and secret keys") {
+		t.Errorf("expected a conflict error, got %v", err)
+	}
+}
+
+func TestS3ClientOptionsUsePathStyle(t *testing.T) {
+	tests := []struct {
+		name         string
+		endpoint     string
+		usePathStyle *bool
+		expected     bool
+	}{
+		{name: "AWS default", expected: false},
+		{name: "custom endpoint default", endpoint: "http://localhost:9000", expected: true},
+		{name: "AWS path-style", usePathStyle: aws.Bool(true), expected: true},
+		{name: "custom endpoint virtual-hosted", endpoint: "https://gateway.example.com", usePathStyle: aws.Bool(false), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var o s3.Options
+			s3ClientOptions(Config{Endpoint: tt.endpoint, UsePathStyle: tt.usePathStyle})(&o)
+			if o.UsePathStyle != tt.expected {
+				t.Errorf("expected UsePathStyle=%v, got %v", tt.expected, o.UsePathStyle)
+			}
+		})
+	}
+}
+
+func TestSigningRegion(t *testing.T) {
+	tests := []struct {
+		name          string
+		signingRegion string
+		expected      string
+	}{
+		{name: "defaults to Region", expected: "/eu-central-1/s3/"},
+		{name: "override", signingRegion: "us-east-1", expected: "/us-east-1/s3/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var authorization string
+			httpClient := &http.Client{
+				Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					authorization = req.Header.Get("Authorization")
+					return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+				}),
+			}
+
+			_, err := NewS3BlobStorage(Config{
+				Enabled:       true,
+				Endpoint:      "http://localhost:9000",
+				Region:        "eu-central-1",
+				SigningRegion: tt.signingRegion,
+				AccessKey:     "test-key",
+				SecretKey:     "test-secret",
+				HTTPClient:    httpClient,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !strings.Contains(authorization, tt.expected) {
+				t.Errorf("expected the request to be signed for %q, got Authorization %q", tt.expected, authorization)
+			}
+		})
+	}
+}
+
+// sidecarResolver resolves every request to a local sidecar, keyed by bucket
+type sidecarResolver struct {
+	params []s3.EndpointParameters
+}
+
+func (r *sidecarResolver) ResolveEndpoint(ctx context.Context, params s3.EndpointParameters) (smithyendpoints.Endpoint, error) {
+	r.params = append(r.params, params)
+	u, err := url.Parse("http://127.0.0.1:15001/" + aws.ToString(params.Bucket))
+	if err != nil {
+		return smithyendpoints.Endpoint{}, err
+	}
+	return smithyendpoints.Endpoint{URI: *u}, nil
+}
+
+func TestEndpointResolver(t *testing.T) {
+	var requested []string
+	httpClient := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			requested = append(requested, req.URL.Host+req.URL.Path)
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+		}),
+	}
+	resolver := &sidecarResolver{}
+
+	_, err := NewS3BlobStorage(Config{
+		Enabled:          true,
+		Endpoint:         "http://minio:9000",
+		Bucket:           "raven-blobs",
+		AccessKey:        "test-key",
+		SecretKey:        "test-secret",
+		HTTPClient:       httpClient,
+		EndpointResolver: resolver,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(requested) == 0 || requested[0] != "127.0.0.1:15001/raven-blobs" {
+		t.Errorf("expected requests to go to the resolved endpoint, got %v", requested)
+	}
+	if len(resolver.params) == 0 || aws.ToString(resolver.params[0].Endpoint) != "http://minio:9000" {
+		t.Errorf("expected the resolver to receive Endpoint as a parameter, got %+v", resolver.params)
+	}
+}
+
+func TestEnsureBucketLocationConstraint(t *testing.T) {
+	tests := []struct {
+		region     string
+		constraint types.BucketLocationConstraint
+	}{
+		{region: "us-east-1", constraint: ""},
+		{region: "eu-west-1", constraint: types.BucketLocationConstraintEuWest1},
+		{region: "ap-southeast-2", constraint: types.BucketLocationConstraintApSoutheast2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.region, func(t *testing.T) {
+			var input *s3.CreateBucketInput
+			mock := &mockS3Client{
+				createBucketFunc: func(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+					input = params
+					return &s3.CreateBucketOutput{}, nil
+				},
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			storage.region = tt.region
+
+			if err := storage.ensureBucket(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var constraint types.BucketLocationConstraint
+			if input.CreateBucketConfiguration != nil {
+				constraint = input.CreateBucketConfiguration.LocationConstraint
+			}
+			if constraint != tt.constraint {
+				t.Errorf("expected LocationConstraint=%q, got %q", tt.constraint, constraint)
+			}
+		})
+	}
+}
+
 func TestIsEnabled(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -194,6 +670,33 @@ func TestIsEnabled(t *testing.T) {
 	}
 }
 
+func TestBucketAndEndpoint(t *testing.T) {
+	httpClient := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+		}),
+	}
+
+	storage, err := NewS3BlobStorage(Config{
+		Enabled:    true,
+		Endpoint:   "http://localhost:9000",
+		AccessKey:  "test-key",
+		SecretKey:  "test-secret",
+		Bucket:     "attachments",
+		HTTPClient: httpClient,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if storage.Bucket() != "attachments" {
+		t.Errorf("expected Bucket()=%q, got %q", "attachments", storage.Bucket())
+	}
+	if storage.Endpoint() != "http://localhost:9000" {
+		t.Errorf("expected Endpoint()=%q, got %q", "http://localhost:9000", storage.Endpoint())
+	}
+}
+
 func TestStore(t *testing.T) {
 	testContent := "test content for blob storage"
 	hash := sha256.Sum256([]byte(testContent))
@@ -312,8 +815,144 @@ func TestStore(t *testing.T) {
 	}
 }
 
+func TestStoreV2(t *testing.T) {
+	content := "attachment with a known size"
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.compression = CompressionGzip
+
+	first, err := storage.StoreV2(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := StoreResult{BlobID: testBlobID(content), Size: int64(len(content))}
+	if first != expected {
+		t.Errorf("expected %+v, got %+v", expected, first)
+	}
+
+	second, err := storage.StoreV2(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected.Deduplicated = true
+	if second != expected {
+		t.Errorf("expected %+v, got %+v", expected, second)
+	}
+
+	disabled := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", false)
+	if _, err := disabled.StoreV2(content); !errors.Is(err, ErrStorageDisabled) {
+		t.Errorf("expected ErrStorageDisabled, got %v", err)
+	}
+}
+
+func TestStoreDedup(t *testing.T) {
+	content := "counted attachment"
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	for i, expectUploaded := range []bool{true, false} {
+		blobID, uploaded, err := storage.StoreDedup(content)
+		if err != nil {
+			t.Fatalf("store %d: unexpected error: %v", i, err)
+		}
+		if blobID != testBlobID(content) {
+			t.Errorf("store %d: expected blobID=%q, got %q", i, testBlobID(content), blobID)
+		}
+		if uploaded != expectUploaded {
+			t.Errorf("store %d: expected uploaded=%v, got %v", i, expectUploaded, uploaded)
+		}
+	}
+	if len(objects) != 1 {
+		t.Errorf("expected 1 stored object, got %d", len(objects))
+	}
+
+	disabled := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", false)
+	if _, uploaded, err := disabled.StoreDedup(content); !errors.Is(err, ErrStorageDisabled) || uploaded {
+		t.Errorf("expected ErrStorageDisabled and no upload, got uploaded=%v, err=%v", uploaded, err)
+	}
+}
+
+func TestStoreDedupThrottlePolicy(t *testing.T) {
+	throttleErrors := map[string]error{
+		"SlowDown error code": &smithy.GenericAPIError{Code: "SlowDown"},
+		"503 response": &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}},
+			Err:      errors.New("service unavailable"),
+		},
+	}
+
+	for errName, throttleErr := range throttleErrors {
+		tests := []struct {
+			name         string
+			policy       string
+			expectError  bool
+			expectUpload bool
+		}{
+			{
+				name:        "fail policy",
+				policy:      ThrottlePolicyFail,
+				expectError: true,
+			},
+			{
+				name:         "upload policy",
+				policy:       ThrottlePolicyUpload,
+				expectUpload: true,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(errName+"/"+tt.name, func(t *testing.T) {
+				uploaded := false
+				mock := &mockS3Client{
+					headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+						return nil, throttleErr
+					},
+					putObjectFunc: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+						uploaded = true
+						return &s3.PutObjectOutput{}, nil
+					},
+				}
+				storage := newMockS3BlobStorage(mock, "test-bucket", true)
+				storage.dedupThrottlePolicy = tt.policy
+
+				_, err := storage.Store("throttled content")
+
+				if tt.expectError {
+					if err == nil || !strings.Contains(err.Error(), "failed to check blob existence") {
+						t.Errorf("expected existence check error, got %v", err)
+					}
+				} else if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				if uploaded != tt.expectUpload {
+					t.Errorf("expected upload=%v, got %v", tt.expectUpload, uploaded)
+				}
+			})
+		}
+	}
+
+	t.Run("non-throttle errors still fail under upload policy", func(t *testing.T) {
+		mock := &mockS3Client{
+			headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				return nil, &smithy.GenericAPIError{Code: "AccessDenied"}
+			},
+			putObjectFunc: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				t.Error("PutObject should not be called for non-throttle errors")
+				return &s3.PutObjectOutput{}, nil
+			},
+		}
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+		storage.dedupThrottlePolicy = ThrottlePolicyUpload
+
+		if _, err := storage.Store("denied content"); err == nil {
+			t.Error("expected error but got none")
+		}
+	})
+}
+
 func TestRetrieve(t *testing.T) {
-	testBlobID := "abc123def456"
+	testBlobID := "abc123def456abc123def456abc123def456abc123def456abc123def456abcd"
 	testContent := "retrieved content"
 
 	tests := []struct {
@@ -322,6 +961,7 @@ func TestRetrieve(t *testing.T) {
 		enabled         bool
 		setupMock       func(*mockS3Client)
 		expectError     bool
+		errorIs         error
 		errorContains   string
 		expectedContent string
 	}{
@@ -361,6 +1001,7 @@ func TestRetrieve(t *testing.T) {
 				}
 			},
 			expectError:   true,
+			errorIs:       ErrBlobNotFound,
 			errorContains: "failed to retrieve blob",
 		},
 		{
@@ -390,6 +1031,8 @@ func TestRetrieve(t *testing.T) {
 			if tt.expectError {
 				if err == nil {
 					t.Errorf("expected error but got none")
+				} else if tt.errorIs != nil && !errors.Is(err, tt.errorIs) {
+					t.Errorf("expected error %v, got %v", tt.errorIs, err)
 				} else if tt.errorContains != "" && !strings.Contains(err.Error(), tt.errorContains) {
 					t.Errorf("expected error containing %q, got %q", tt.errorContains, err.Error())
 				}
@@ -409,7 +1052,7 @@ func TestRetrieve(t *testing.T) {
 }
 
 func TestDelete(t *testing.T) {
-	testBlobID := "abc123def456"
+	testBlobID := "abc123def456abc123def456abc123def456abc123def456abc123def456abcd"
 
 	tests := []struct {
 		name          string
@@ -454,6 +1097,17 @@ func TestDelete(t *testing.T) {
 			expectError:   true,
 			errorContains: "failed to delete blob",
 		},
+		{
+			name:    "missing blob on a backend answering NoSuchKey",
+			blobID:  testBlobID,
+			enabled: true,
+			setupMock: func(m *mockS3Client) {
+				m.deleteObjectFunc = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+					return nil, &smithy.GenericAPIError{Code: "NoSuchKey"}
+				}
+			},
+			expectError: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -480,8 +1134,46 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestDeleteStrict(t *testing.T) {
+	mock, objects := newBucketMock()
+	deletes := 0
+	deleteObject := mock.deleteObjectFunc
+	mock.deleteObjectFunc = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		deletes++
+		if _, ok := objects[*params.Key]; !ok {
+			return nil, &smithy.GenericAPIError{Code: "NoSuchKey"}
+		}
+		return deleteObject(ctx, params, optFns...)
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	blobID, err := storage.Store("garbage collected")
+	if err != nil {
+		t.Fatalf("unexpected store error: %v", err)
+	}
+
+	if err := storage.DeleteStrict(blobID); err != nil {
+		t.Fatalf("unexpected error deleting stored blob: %v", err)
+	}
+	if len(objects) != 0 {
+		t.Error("expected the blob to be deleted")
+	}
+
+	if err := storage.DeleteStrict(blobID); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+	if deletes != 1 {
+		t.Errorf("expected no DeleteObject for the missing blob, got %d deletes", deletes)
+	}
+
+	// Delete stays idempotent
+	if err := storage.Delete(blobID); err != nil {
+		t.Errorf("unexpected error deleting missing blob: %v", err)
+	}
+}
+
 func TestExists(t *testing.T) {
-	testBlobID := "abc123def456"
+	testBlobID := "abc123def456abc123def456abc123def456abc123def456abc123def456abcd"
 
 	tests := []struct {
 		name          string
@@ -579,3 +1271,393 @@ type errorReader struct {
 func (e *errorReader) Read(p []byte) (n int, err error) {
 	return 0, e.err
 }
+
+func TestRetrieveVerifyOnRetrieve(t *testing.T) {
+	content := "content that must not be truncated"
+	blobID := testBlobID(content)
+
+	tests := []struct {
+		name        string
+		verify      bool
+		served      string
+		expectError bool
+	}{
+		{
+			name:   "verification passes for intact content",
+			verify: true,
+			served: content,
+		},
+		{
+			name:        "verification detects truncated content",
+			verify:      true,
+			served:      content[:10],
+			expectError: true,
+		},
+		{
+			name:   "verification disabled returns truncated content",
+			verify: false,
+			served: content[:10],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockS3Client{
+				getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(tt.served))}, nil
+				},
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			storage.verifyOnRetrieve = tt.verify
+
+			retrieved, err := storage.Retrieve(blobID)
+
+			if tt.expectError {
+				if !errors.Is(err, ErrIntegrityMismatch) {
+					t.Errorf("expected ErrIntegrityMismatch, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if retrieved != tt.served {
+				t.Errorf("expected content=%q, got %q", tt.served, retrieved)
+			}
+		})
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func TestMaxBlobSize(t *testing.T) {
+	const maxSize = 16
+	small := "fits in limit"
+	large := "this content is over the limit"
+
+	// noCallsMock fails the test if any S3 call is made
+	noCallsMock := func(t *testing.T) *mockS3Client {
+		return &mockS3Client{
+			headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				t.Error("HeadObject should not be called for oversized content")
+				return nil, errors.New("unexpected call")
+			},
+			putObjectFunc: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				t.Error("PutObject should not be called for oversized content")
+				return nil, errors.New("unexpected call")
+			},
+		}
+	}
+
+	assertTooLarge := func(t *testing.T, err error, size int64) {
+		t.Helper()
+		var tooLarge *ErrBlobTooLarge
+		if !errors.As(err, &tooLarge) {
+			t.Fatalf("expected ErrBlobTooLarge, got %v", err)
+		}
+		if tooLarge.Size != size || tooLarge.Max != maxSize {
+			t.Errorf("expected size=%d max=%d, got size=%d max=%d", size, maxSize, tooLarge.Size, tooLarge.Max)
+		}
+	}
+
+	t.Run("Store rejects oversized content before any S3 call", func(t *testing.T) {
+		storage := newMockS3BlobStorage(noCallsMock(t), "test-bucket", true)
+		storage.maxBlobSize = maxSize
+
+		_, err := storage.Store(large)
+		assertTooLarge(t, err, int64(len(large)))
+	})
+
+	t.Run("StoreBytes rejects oversized content", func(t *testing.T) {
+		storage := newMockS3BlobStorage(noCallsMock(t), "test-bucket", true)
+		storage.maxBlobSize = maxSize
+
+		_, err := storage.StoreBytes([]byte(large))
+		assertTooLarge(t, err, int64(len(large)))
+	})
+
+	t.Run("StoreReaderWithSizeAndHash rejects oversized size", func(t *testing.T) {
+		storage := newMockS3BlobStorage(noCallsMock(t), "test-bucket", true)
+		storage.maxBlobSize = maxSize
+
+		_, err := storage.StoreReaderWithSizeAndHash(strings.NewReader(large), int64(len(large)), testBlobID(large))
+		assertTooLarge(t, err, int64(len(large)))
+	})
+
+	t.Run("StoreReader stops reading once over the limit", func(t *testing.T) {
+		storage := newMockS3BlobStorage(noCallsMock(t), "test-bucket", true)
+		storage.maxBlobSize = maxSize
+
+		src := &countingReader{r: strings.NewReader(strings.Repeat("x", 1<<20))}
+		_, err := storage.StoreReader(src)
+		assertTooLarge(t, err, maxSize+1)
+
+		if src.n > maxSize+1 {
+			t.Errorf("expected at most %d bytes read, got %d", maxSize+1, src.n)
+		}
+	})
+
+	t.Run("content within the limit is stored", func(t *testing.T) {
+		mock, objects := newBucketMock()
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+		storage.maxBlobSize = maxSize
+
+		if _, err := storage.Store(small); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := storage.StoreReader(strings.NewReader(strings.Repeat("y", maxSize))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(objects) != 2 {
+			t.Errorf("expected 2 stored objects, got %d", len(objects))
+		}
+	})
+}
+
+// notFoundErrors are the shapes a missing object takes across S3 providers
+var notFoundErrors = map[string]error{
+	"AWS HeadObject NotFound": &smithy.GenericAPIError{Code: "NotFound"},
+	"NoSuchKey":               &smithy.GenericAPIError{Code: "NoSuchKey"},
+	"bare 404 response": &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusNotFound}},
+		Err:      errors.New("not found"),
+	},
+}
+
+func TestStoreEmptyContent(t *testing.T) {
+	// The SHA256 of the empty string; empty content is stored under it
+	const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	t.Run("stored by default", func(t *testing.T) {
+		mock, objects := newBucketMock()
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+		blobID, err := storage.Store("")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if blobID != emptySHA256 {
+			t.Errorf("expected blobID=%q, got %q", emptySHA256, blobID)
+		}
+		if obj, ok := objects["blobs/"+emptySHA256]; !ok || len(obj.body) != 0 {
+			t.Error("expected a zero-byte object to be stored")
+		}
+	})
+
+	t.Run("rejected with RejectEmpty", func(t *testing.T) {
+		mock, objects := newBucketMock()
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+		storage.rejectEmpty = true
+
+		if _, err := storage.Store(""); !errors.Is(err, ErrEmptyContent) {
+			t.Errorf("Store: expected ErrEmptyContent, got %v", err)
+		}
+		if _, err := storage.StoreReader(strings.NewReader("")); !errors.Is(err, ErrEmptyContent) {
+			t.Errorf("StoreReader: expected ErrEmptyContent, got %v", err)
+		}
+		if len(objects) != 0 {
+			t.Errorf("expected nothing to be stored, got %d objects", len(objects))
+		}
+
+		if _, err := storage.Store("not empty"); err != nil {
+			t.Errorf("unexpected error storing non-empty content: %v", err)
+		}
+	})
+}
+
+func TestBlobError(t *testing.T) {
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	blobID := testBlobID("missing")
+
+	_, err := storage.Retrieve(blobID)
+
+	var blobErr *BlobError
+	if !errors.As(err, &blobErr) {
+		t.Fatalf("expected a *BlobError, got %T: %v", err, err)
+	}
+	if blobErr.Op != "Retrieve" || blobErr.BlobID != blobID || blobErr.Bucket != "test-bucket" {
+		t.Errorf("expected Retrieve of %s in test-bucket, got %+v", blobID, blobErr)
+	}
+	if !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected the error to wrap ErrBlobNotFound, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "Retrieve test-bucket/"+blobID+": ") {
+		t.Errorf("expected the message to name the operation and blob, got %q", err.Error())
+	}
+
+	_, err = newMockS3BlobStorage(mock, "test-bucket", false).Store("content")
+	if !errors.As(err, &blobErr) || blobErr.Op != "Store" || !errors.Is(err, ErrStorageDisabled) {
+		t.Errorf("expected a Store BlobError wrapping ErrStorageDisabled, got %v", err)
+	}
+
+	if _, err := storage.Exists(blobID); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Disabled storage has no bucket to name
+	_, err = (&S3BlobStorage{}).Retrieve(blobID)
+	if want := "Retrieve " + blobID + ": "; err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("expected the message to start with %q, got %v", want, err)
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	for name, err := range notFoundErrors {
+		if !isNotFound(err) {
+			t.Errorf("%s: expected not found", name)
+		}
+		if !isNotFound(fmt.Errorf("wrapped: %w", err)) {
+			t.Errorf("%s: expected wrapped error to be not found", name)
+		}
+	}
+
+	others := map[string]error{
+		"nil":           nil,
+		"access denied": &smithy.GenericAPIError{Code: "AccessDenied"},
+		"403 response": &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusForbidden}},
+			Err:      errors.New("forbidden"),
+		},
+	}
+	for name, err := range others {
+		if isNotFound(err) {
+			t.Errorf("%s: expected not to be treated as not found", name)
+		}
+	}
+}
+
+func TestNotFoundAcrossProviders(t *testing.T) {
+	for name, notFoundErr := range notFoundErrors {
+		t.Run(name, func(t *testing.T) {
+			putCalled := false
+			mock := &mockS3Client{
+				headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+					return nil, notFoundErr
+				},
+				getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					return nil, notFoundErr
+				},
+				putObjectFunc: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+					putCalled = true
+					return &s3.PutObjectOutput{}, nil
+				},
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+			if _, err := storage.Store("content"); err != nil {
+				t.Errorf("Store: unexpected error: %v", err)
+			}
+			if !putCalled {
+				t.Error("Store: expected a missing blob to be uploaded")
+			}
+
+			exists, err := storage.Exists(testBlobID("content"))
+			if err != nil || exists {
+				t.Errorf("Exists: expected exists=false with no error, got exists=%v err=%v", exists, err)
+			}
+
+			if _, err := storage.Retrieve(testBlobID("content")); !errors.Is(err, ErrBlobNotFound) {
+				t.Errorf("Retrieve: expected ErrBlobNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestInvalidBlobIDRejectedBeforeS3(t *testing.T) {
+	tests := []struct {
+		name   string
+		blobID string
+	}{
+		{name: "empty", blobID: ""},
+		{name: "too short", blobID: "abc123def456"},
+		{name: "too long", blobID: testBlobID("attachment") + "0"},
+		{name: "non-hex", blobID: strings.Repeat("g", 64)},
+		{name: "uppercase", blobID: strings.ToUpper(testBlobID("attachment"))},
+		{name: "filename", blobID: "invoice.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockS3Client{
+				getObjectFunc: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					t.Error("unexpected GetObject call")
+					return nil, errors.New("unexpected call")
+				},
+				headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+					t.Error("unexpected HeadObject call")
+					return nil, errors.New("unexpected call")
+				},
+				deleteObjectFunc: func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+					t.Error("unexpected DeleteObject call")
+					return nil, errors.New("unexpected call")
+				},
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+			if _, err := storage.Retrieve(tt.blobID); !errors.Is(err, ErrInvalidBlobID) {
+				t.Errorf("Retrieve: expected ErrInvalidBlobID, got %v", err)
+			}
+			if err := storage.Delete(tt.blobID); !errors.Is(err, ErrInvalidBlobID) {
+				t.Errorf("Delete: expected ErrInvalidBlobID, got %v", err)
+			}
+			if _, err := storage.Exists(tt.blobID); !errors.Is(err, ErrInvalidBlobID) {
+				t.Errorf("Exists: expected ErrInvalidBlobID, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSkipDedupCheck(t *testing.T) {
+	content := "audited content"
+
+	mock, objects := newBucketMock()
+	mock.headObjectFunc = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		t.Error("HeadObject should not be called")
+		return nil, errors.New("unexpected HeadObject")
+	}
+	putObject := mock.putObjectFunc
+	puts := 0
+	mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		puts++
+		if params.IfNoneMatch != nil {
+			t.Errorf("expected an unconditional put, got If-None-Match %q", *params.IfNoneMatch)
+		}
+		return putObject(ctx, params, optFns...)
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.skipDedupCheck = true
+	storage.dedupCache = newDedupCache(10)
+
+	for range 2 {
+		blobID, err := storage.Store(content)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if blobID != testBlobID(content) {
+			t.Errorf("expected blob ID %s, got %s", testBlobID(content), blobID)
+		}
+	}
+	if _, err := storage.StoreMany(context.Background(), [][]byte{[]byte(content)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if puts != 3 {
+		t.Errorf("expected every store to put, got %d puts", puts)
+	}
+	if string(objects["blobs/"+testBlobID(content)].body) != content {
+		t.Error("expected the blob to be stored")
+	}
+}
@@ -0,0 +1,93 @@
+package blobstorage
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestRetrieveSeeker(t *testing.T) {
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	content := "0123456789abcdefghij"
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("failed to store blob: %v", err)
+	}
+
+	r, err := storage.RetrieveSeeker(blobID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil || size != int64(len(content)) {
+		t.Fatalf("expected size=%d, got %d (err=%v)", len(content), size, err)
+	}
+
+	tests := []struct {
+		name     string
+		offset   int64
+		whence   int
+		expected string
+	}{
+		{name: "from start", offset: 5, whence: io.SeekStart, expected: "56789abcdefghij"},
+		{name: "from end", offset: -4, whence: io.SeekEnd, expected: "ghij"},
+		{name: "at end", offset: 0, whence: io.SeekEnd, expected: ""},
+		{name: "past end", offset: 100, whence: io.SeekStart, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := r.Seek(tt.offset, tt.whence); err != nil {
+				t.Fatalf("unexpected seek error: %v", err)
+			}
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("unexpected read error: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, string(data))
+			}
+		})
+	}
+
+	// Seeking mid-read drops the open range and continues from the new offset
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("unexpected seek error: %v", err)
+	}
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(r, buf); err != nil || string(buf) != "012" {
+		t.Fatalf("expected %q, got %q (err=%v)", "012", string(buf), err)
+	}
+	if pos, _ := r.Seek(2, io.SeekCurrent); pos != 5 {
+		t.Errorf("expected position 5, got %d", pos)
+	}
+	if _, err := io.ReadFull(r, buf); err != nil || string(buf) != "567" {
+		t.Errorf("expected %q, got %q (err=%v)", "567", string(buf), err)
+	}
+
+	if _, err := r.Seek(-1, io.SeekStart); err == nil {
+		t.Error("expected an error seeking before the start")
+	}
+}
+
+func TestRetrieveSeekerErrors(t *testing.T) {
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	if _, err := storage.RetrieveSeeker(testBlobID("missing")); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+
+	storage.compression = CompressionGzip
+	blobID, err := storage.Store("compressible compressible compressible")
+	if err != nil {
+		t.Fatalf("failed to store blob: %v", err)
+	}
+	if _, err := storage.RetrieveSeeker(blobID); err == nil {
+		t.Error("expected an error for a compressed blob")
+	}
+}
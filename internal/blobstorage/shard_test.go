@@ -0,0 +1,87 @@
+package blobstorage
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBlobKeySharding(t *testing.T) {
+	blobID := testBlobID("attachment")
+
+	tests := []struct {
+		depth    int
+		expected string
+	}{
+		{depth: 0, expected: "blobs/" + blobID},
+		{depth: 1, expected: "blobs/" + blobID[:2] + "/" + blobID},
+		{depth: 2, expected: "blobs/" + blobID[:2] + "/" + blobID[2:4] + "/" + blobID},
+	}
+
+	for _, tt := range tests {
+		storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+		storage.shardDepth = tt.depth
+
+		key := storage.blobKey(blobID)
+		if key != tt.expected {
+			t.Errorf("depth %d: expected key=%q, got %q", tt.depth, tt.expected, key)
+		}
+		if got := storage.blobIDFromKey(key); got != blobID {
+			t.Errorf("depth %d: expected blobIDFromKey=%q, got %q", tt.depth, blobID, got)
+		}
+	}
+}
+
+func TestNewS3BlobStorageInvalidShardDepth(t *testing.T) {
+	for _, depth := range []int{-1, maxShardDepth + 1} {
+		_, err := NewS3BlobStorage(Config{
+			Enabled:    true,
+			AccessKey:  "access",
+			SecretKey:  "secret",
+			ShardDepth: depth,
+		})
+		if err == nil || !strings.Contains(err.Error(), "invalid shard depth") {
+			t.Errorf("depth %d: expected invalid shard depth error, got %v", depth, err)
+		}
+	}
+}
+
+func TestShardedStorageRoundTrip(t *testing.T) {
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.shardDepth = 2
+
+	blobID, err := storage.Store("sharded attachment")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := objects["blobs/"+blobID[:2]+"/"+blobID[2:4]+"/"+blobID]; !ok {
+		t.Fatalf("expected blob under a sharded key, have %v", reflect.ValueOf(objects).MapKeys())
+	}
+
+	content, err := storage.Retrieve(blobID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content != "sharded attachment" {
+		t.Errorf("expected content=%q, got %q", "sharded attachment", content)
+	}
+}
+
+func TestListSharded(t *testing.T) {
+	mock := &mockS3Client{listObjectsFunc: pagedListMock(t, [][]string{
+		{"blobs/aa/aaa1", "blobs/bb/bbb2"},
+		{"blobs/cc/ccc3"},
+	})}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.shardDepth = 1
+
+	ids, err := storage.List(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"aaa1", "bbb2", "ccc3"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected List to return %v, got %v", expected, ids)
+	}
+}
@@ -0,0 +1,228 @@
+package blobstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+func TestNewS3BlobStorageInvalidServerSideEncryption(t *testing.T) {
+	tests := []struct {
+		name          string
+		sse           string
+		kmsKeyID      string
+		errorContains string
+	}{
+		{name: "unknown algorithm", sse: "aws:kms:dsse", errorContains: "invalid server-side encryption"},
+		{name: "key ID without KMS", sse: ServerSideEncryptionS3, kmsKeyID: "key-id", errorContains: "KMS key ID requires"},
+		{name: "key ID without SSE", kmsKeyID: "key-id", errorContains: "KMS key ID requires"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewS3BlobStorage(Config{
+				Enabled:              true,
+				AccessKey:            "access",
+				SecretKey:            "secret",
+				ServerSideEncryption: tt.sse,
+				KMSKeyID:             tt.kmsKeyID,
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("expected error containing %q, got %v", tt.errorContains, err)
+			}
+		})
+	}
+}
+
+func TestStoreServerSideEncryption(t *testing.T) {
+	tests := []struct {
+		name             string
+		sse              string
+		kmsKeyID         string
+		expectedSSE      types.ServerSideEncryption
+		expectedKMSKeyID string
+	}{
+		{name: "not configured"},
+		{name: "SSE-S3", sse: ServerSideEncryptionS3, expectedSSE: types.ServerSideEncryptionAes256},
+		{name: "SSE-KMS with key", sse: ServerSideEncryptionKMS, kmsKeyID: "arn:aws:kms:us-east-1:123456789012:key/test", expectedSSE: types.ServerSideEncryptionAwsKms, expectedKMSKeyID: "arn:aws:kms:us-east-1:123456789012:key/test"},
+		{name: "SSE-KMS with AWS-managed key", sse: ServerSideEncryptionKMS, expectedSSE: types.ServerSideEncryptionAwsKms},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, _ := newBucketMock()
+			putObject := mock.putObjectFunc
+			var putParams *s3.PutObjectInput
+			mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				putParams = params
+				return putObject(ctx, params, optFns...)
+			}
+			createMultipart := mock.createMultipartUploadFunc
+			var multipartParams *s3.CreateMultipartUploadInput
+			mock.createMultipartUploadFunc = func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+				multipartParams = params
+				return createMultipart(ctx, params, optFns...)
+			}
+			storage := newMultipartTestStorage(mock)
+			storage.sse = tt.sse
+			storage.sseKMSKeyID = tt.kmsKeyID
+
+			if _, err := storage.Store("small"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if putParams.ServerSideEncryption != tt.expectedSSE {
+				t.Errorf("expected PutObject ServerSideEncryption=%q, got %q", tt.expectedSSE, putParams.ServerSideEncryption)
+			}
+			if got := aws.ToString(putParams.SSEKMSKeyId); got != tt.expectedKMSKeyID {
+				t.Errorf("expected PutObject SSEKMSKeyId=%q, got %q", tt.expectedKMSKeyID, got)
+			}
+
+			if _, err := storage.Store("content above the multipart threshold"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if multipartParams.ServerSideEncryption != tt.expectedSSE {
+				t.Errorf("expected CreateMultipartUpload ServerSideEncryption=%q, got %q", tt.expectedSSE, multipartParams.ServerSideEncryption)
+			}
+			if got := aws.ToString(multipartParams.SSEKMSKeyId); got != tt.expectedKMSKeyID {
+				t.Errorf("expected CreateMultipartUpload SSEKMSKeyId=%q, got %q", tt.expectedKMSKeyID, got)
+			}
+		})
+	}
+}
+
+func TestSSECustomerKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, sseCustomerKeySize)
+	sum := md5.Sum(key)
+	expectedKey := base64.StdEncoding.EncodeToString(key)
+	expectedMD5 := base64.StdEncoding.EncodeToString(sum[:])
+
+	mock, _ := newBucketMock()
+	checkHeaders := func(op string, algorithm, key, keyMD5 *string) {
+		t.Helper()
+		if aws.ToString(algorithm) != "AES256" || aws.ToString(key) != expectedKey || aws.ToString(keyMD5) != expectedMD5 {
+			t.Errorf("%s: expected SSE-C headers, got algorithm=%q key=%q keyMD5=%q", op, aws.ToString(algorithm), aws.ToString(key), aws.ToString(keyMD5))
+		}
+	}
+
+	headObject, putObject, getObject := mock.headObjectFunc, mock.putObjectFunc, mock.getObjectFunc
+	uploadPart := mock.uploadPartFunc
+	mock.headObjectFunc = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		checkHeaders("HeadObject", params.SSECustomerAlgorithm, params.SSECustomerKey, params.SSECustomerKeyMD5)
+		return headObject(ctx, params, optFns...)
+	}
+	mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		checkHeaders("PutObject", params.SSECustomerAlgorithm, params.SSECustomerKey, params.SSECustomerKeyMD5)
+		return putObject(ctx, params, optFns...)
+	}
+	mock.getObjectFunc = func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		checkHeaders("GetObject", params.SSECustomerAlgorithm, params.SSECustomerKey, params.SSECustomerKeyMD5)
+		return getObject(ctx, params, optFns...)
+	}
+	mock.uploadPartFunc = func(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+		checkHeaders("UploadPart", params.SSECustomerAlgorithm, params.SSECustomerKey, params.SSECustomerKeyMD5)
+		return uploadPart(ctx, params, optFns...)
+	}
+	var copyParams *s3.CopyObjectInput
+	mock.copyObjectFunc = func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+		copyParams = params
+		return &s3.CopyObjectOutput{}, nil
+	}
+
+	storage := newMultipartTestStorage(mock)
+	storage.sseC = newSSECustomerParams(key)
+
+	for _, content := range []string{"small", "content above the multipart threshold"} {
+		blobID, err := storage.Store(content)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		retrieved, err := storage.Retrieve(blobID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if retrieved != content {
+			t.Errorf("expected %q, got %q", content, retrieved)
+		}
+	}
+
+	if err := storage.Touch(context.Background(), testBlobID("small")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkHeaders("CopyObject", copyParams.SSECustomerAlgorithm, copyParams.SSECustomerKey, copyParams.SSECustomerKeyMD5)
+	checkHeaders("CopyObject source", copyParams.CopySourceSSECustomerAlgorithm, copyParams.CopySourceSSECustomerKey, copyParams.CopySourceSSECustomerKeyMD5)
+
+	if _, err := storage.PresignGetURL(testBlobID("small"), time.Minute); !errors.Is(err, errPresignSSECustomer) {
+		t.Errorf("expected errPresignSSECustomer, got %v", err)
+	}
+}
+
+func TestStoreSSECustomerKeyExistenceCheck(t *testing.T) {
+	content := "content written with SSE-C"
+	writtenWith := newSSECustomerParams(bytes.Repeat([]byte{0x42}, sseCustomerKeySize))
+	statusError := func(code int) error {
+		return &smithyhttp.ResponseError{Response: &smithyhttp.Response{Response: &http.Response{StatusCode: code}}}
+	}
+
+	tests := []struct {
+		name        string
+		key         []byte
+		expectError error
+	}{
+		{name: "matching key", key: bytes.Repeat([]byte{0x42}, sseCustomerKeySize)},
+		{name: "missing key", expectError: ErrSSECustomerKey},
+		{name: "wrong key", key: bytes.Repeat([]byte{0x24}, sseCustomerKeySize), expectError: ErrSSECustomerKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, objects := newBucketMock()
+			objects["blobs/"+testBlobID(content)] = &storedObject{body: []byte(content)}
+			headObject := mock.headObjectFunc
+			mock.headObjectFunc = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				if _, ok := objects[*params.Key]; ok {
+					switch {
+					case params.SSECustomerKey == nil:
+						return nil, statusError(http.StatusBadRequest)
+					case *params.SSECustomerKey != writtenWith.key:
+						return nil, statusError(http.StatusForbidden)
+					}
+				}
+				return headObject(ctx, params, optFns...)
+			}
+			mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				t.Error("PutObject should not be called")
+				return &s3.PutObjectOutput{}, nil
+			}
+
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			storage.sseC = newSSECustomerParams(tt.key)
+
+			blobID, err := storage.Store(content)
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("expected error %v, got %v", tt.expectError, err)
+			}
+			if err == nil && blobID != testBlobID(content) {
+				t.Errorf("expected blob ID %s, got %s", testBlobID(content), blobID)
+			}
+
+			exists, err := storage.Exists(testBlobID(content))
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("expected error %v, got %v", tt.expectError, err)
+			}
+			if err == nil && !exists {
+				t.Error("expected blob to exist")
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package blobstorage
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// StoreWithStorageClass stores content like Store but under the given S3
+// storage class (e.g. STANDARD_IA), overriding the configured StorageClass.
+// If identical content is already stored its storage class is left as is.
+func (s *S3BlobStorage) StoreWithStorageClass(content string, class string) (_ string, err error) {
+	var result StoreResult
+	defer s.wrapStoreError("StoreWithStorageClass", &result, &err)
+	if err := validateStorageClass(class); err != nil {
+		return "", err
+	}
+	result, err = s.storeResult(s.ctx, []byte(content), putOptions{storageClass: class})
+	return result.BlobID, err
+}
+
+// storageClassFor returns the storage class to upload with, preferring a
+// per-call override over the configured default
+func (s *S3BlobStorage) storageClassFor(opts putOptions) types.StorageClass {
+	if opts.storageClass != "" {
+		return types.StorageClass(opts.storageClass)
+	}
+	return types.StorageClass(s.storageClass)
+}
+
+// validateStorageClass checks class against the storage classes S3 knows
+// about. An empty class is valid and leaves the choice to the bucket.
+func validateStorageClass(class string) error {
+	if class == "" {
+		return nil
+	}
+	for _, known := range types.StorageClass("").Values() {
+		if class == string(known) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid storage class %q", class)
+}
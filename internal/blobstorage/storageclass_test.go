@@ -0,0 +1,77 @@
+package blobstorage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestNewS3BlobStorageInvalidStorageClass(t *testing.T) {
+	_, err := NewS3BlobStorage(Config{
+		Enabled:      true,
+		AccessKey:    "access",
+		SecretKey:    "secret",
+		StorageClass: "COLD_STORAGE",
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid storage class") {
+		t.Errorf("expected invalid storage class error, got %v", err)
+	}
+}
+
+func TestStoreStorageClass(t *testing.T) {
+	tests := []struct {
+		name          string
+		configured    string
+		override      string
+		expectError   bool
+		expectedClass types.StorageClass
+	}{
+		{name: "bucket default", expectedClass: ""},
+		{name: "configured class", configured: "STANDARD_IA", expectedClass: types.StorageClassStandardIa},
+		{name: "per-blob override", configured: "STANDARD_IA", override: "INTELLIGENT_TIERING", expectedClass: types.StorageClassIntelligentTiering},
+		{name: "unknown override", override: "COLD_STORAGE", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			putCalled := false
+			var class types.StorageClass
+			mock, _ := newBucketMock()
+			putObject := mock.putObjectFunc
+			mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				putCalled = true
+				class = params.StorageClass
+				return putObject(ctx, params, optFns...)
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			storage.storageClass = tt.configured
+
+			var err error
+			if tt.override != "" {
+				_, err = storage.StoreWithStorageClass("attachment", tt.override)
+			} else {
+				_, err = storage.Store("attachment")
+			}
+
+			if tt.expectError {
+				if err == nil || !strings.Contains(err.Error(), "invalid storage class") {
+					t.Errorf("expected invalid storage class error, got %v", err)
+				}
+				if putCalled {
+					t.Error("expected no upload for an unknown storage class")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if class != tt.expectedClass {
+				t.Errorf("expected StorageClass=%q, got %q", tt.expectedClass, class)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package blobstorage
+
+// StoreHits returns how many stores found their content already stored and
+// skipped the upload since creation or the last ResetStats
+func (s *S3BlobStorage) StoreHits() int64 {
+	return s.storeHits.Load()
+}
+
+// StoreMisses returns how many stores uploaded new content since creation or
+// the last ResetStats
+func (s *S3BlobStorage) StoreMisses() int64 {
+	return s.storeMisses.Load()
+}
+
+// ResetStats zeroes the StoreHits and StoreMisses counters
+func (s *S3BlobStorage) ResetStats() {
+	s.storeHits.Store(0)
+	s.storeMisses.Store(0)
+}
+
+// recordStore counts a store by whether its content already existed
+func (s *S3BlobStorage) recordStore(existed bool) {
+	if existed {
+		s.storeHits.Add(1)
+	} else {
+		s.storeMisses.Add(1)
+	}
+}
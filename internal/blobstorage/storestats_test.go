@@ -0,0 +1,32 @@
+package blobstorage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStoreHitsAndMisses(t *testing.T) {
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	for _, content := range []string{"first", "second", "first", "first"} {
+		if _, err := storage.Store(content); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, err := storage.StoreReader(strings.NewReader("second")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if hits := storage.StoreHits(); hits != 3 {
+		t.Errorf("expected 3 hits, got %d", hits)
+	}
+	if misses := storage.StoreMisses(); misses != 2 {
+		t.Errorf("expected 2 misses, got %d", misses)
+	}
+
+	storage.ResetStats()
+	if storage.StoreHits() != 0 || storage.StoreMisses() != 0 {
+		t.Errorf("expected counters to be reset, got hits=%d misses=%d", storage.StoreHits(), storage.StoreMisses())
+	}
+}
@@ -0,0 +1,414 @@
+package blobstorage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrHashMismatch is returned when streamed content does not hash to the
+// precomputed blob ID supplied by the caller
+var ErrHashMismatch = errors.New("content does not match precomputed hash")
+
+// StoreReader stores content read from r and returns its blob ID. Because the
+// blob ID must be known before uploading, the content is spooled to a
+// temporary file while it is hashed and then uploaded from there.
+func (s *S3BlobStorage) StoreReader(r io.Reader) (_ string, err error) {
+	var result StoreResult
+	defer s.wrapStoreError("StoreReader", &result, &err)
+	result, err = s.storeReader(r, -1, nil)
+	return result.BlobID, err
+}
+
+// StoreReaderProgress stores content read from r like StoreReader, calling
+// progress with the number of bytes uploaded so far, e.g. to drive a progress
+// bar. Single uploads report as the body is sent and multipart uploads after
+// each part completes. The count is of stored bytes, so with Compression or
+// client-side encryption it won't end at the content size. progress is not
+// called if the content is already stored. total is the expected content
+// size, or -1 if unknown; content of a different size is rejected.
+func (s *S3BlobStorage) StoreReaderProgress(r io.Reader, total int64, progress func(written int64)) (_ string, err error) {
+	var result StoreResult
+	defer s.wrapStoreError("StoreReaderProgress", &result, &err)
+	if total >= 0 {
+		if err := s.checkSize(total); err != nil {
+			return "", err
+		}
+	}
+	result, err = s.storeReader(r, total, progress)
+	return result.BlobID, err
+}
+
+// storeReader spools, hashes and stores content read from r, returning the
+// stored blob. With total of zero or more, content of any other size is
+// rejected before uploading.
+func (s *S3BlobStorage) storeReader(r io.Reader, total int64, progress func(written int64)) (StoreResult, error) {
+	if !s.enabled {
+		return StoreResult{}, ErrStorageDisabled
+	}
+
+	spool, err := os.CreateTemp("", "raven-blob-*")
+	if err != nil {
+		return StoreResult{}, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	defer func() {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+	}()
+
+	// Read at most one byte past the size limit so oversized content is
+	// rejected without spooling all of it
+	src := r
+	if s.maxBlobSize > 0 {
+		src = io.LimitReader(r, s.maxBlobSize+1)
+	}
+
+	hash := s.newHash()
+	size, err := s.copyBuffered(io.MultiWriter(spool, hash), src)
+	if err != nil {
+		return StoreResult{}, fmt.Errorf("failed to read content: %w", err)
+	}
+	if err := s.checkSize(size); err != nil {
+		return StoreResult{}, err
+	}
+	if total >= 0 && size != total {
+		return StoreResult{}, fmt.Errorf("content size %d does not match expected size %d", size, total)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return StoreResult{}, fmt.Errorf("failed to rewind spool file: %w", err)
+	}
+
+	blobID := hex.EncodeToString(hash.Sum(nil))
+	uploaded, err := s.storeStream(spool, size, blobID, progress)
+	if err != nil {
+		return StoreResult{}, err
+	}
+
+	return StoreResult{BlobID: blobID, Size: size, Deduplicated: !uploaded && !s.dryRun}, nil
+}
+
+// StoreReaderWithSizeAndHash stores content of a known size using a hash the
+// caller has already computed as the blob ID. The content is re-hashed and
+// the upload is aborted with ErrHashMismatch if it does not match
+// precomputedHash. It is streamed to S3 in a single pass, except over plain
+// HTTP, where a single PutObject is signed over its payload and so is
+// verified into a temporary file first. If the blob is already stored the
+// content is still read to the end to check the hash.
+func (s *S3BlobStorage) StoreReaderWithSizeAndHash(r io.Reader, size int64, precomputedHash string) (_ string, err error) {
+	var result StoreResult
+	defer s.wrapStoreError("StoreReaderWithSizeAndHash", &result, &err)
+	if !s.enabled {
+		return "", ErrStorageDisabled
+	}
+
+	if size < 0 {
+		return "", fmt.Errorf("invalid content size %d", size)
+	}
+	if err := s.checkSize(size); err != nil {
+		return "", err
+	}
+
+	blobID := strings.ToLower(precomputedHash)
+	if err := s.validateBlobID(blobID); err != nil {
+		return "", fmt.Errorf("invalid precomputed hash: %w", err)
+	}
+
+	body := newVerifyingReader(r, size, blobID, s.newHash())
+	if size == 0 {
+		// Nothing will be read, so verify the empty content up front
+		if err := body.check(); err != nil {
+			return "", err
+		}
+	}
+
+	var upload io.Reader = body
+	if s.signsPayload() && !s.encodesContent() && !s.usesMultipart(size) {
+		// The SDK reads the body to sign it before sending, and needs to
+		// seek back afterwards. Multipart parts and encoded content are
+		// buffered anyway.
+		spool, err := s.spoolVerified(body)
+		if err != nil {
+			return "", err
+		}
+		defer func() {
+			_ = spool.Close()
+			_ = os.Remove(spool.Name())
+		}()
+		upload = spool
+	}
+
+	uploaded, err := s.storeStream(upload, size, blobID, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if !body.checked {
+		if uploaded {
+			s.dedupCache.remove(blobID)
+			return "", fmt.Errorf("failed to upload blob: content was not fully consumed")
+		}
+		// Nothing was uploaded, e.g. as the blob already exists, so the
+		// content hasn't been read yet
+		if _, err := s.copyBuffered(io.Discard, body); err != nil {
+			return "", fmt.Errorf("failed to read content: %w", err)
+		}
+		if !body.checked {
+			if err := body.check(); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	result = StoreResult{BlobID: blobID, Size: size, Deduplicated: !uploaded && !s.dryRun}
+	return blobID, nil
+}
+
+// signsPayload reports whether a single PutObject needs a seekable body.
+// Over HTTPS the SDK sends the payload unsigned, but over plain HTTP it
+// hashes the body for the signature before sending it. With an
+// EndpointResolver the scheme isn't known, so it assumes plain HTTP.
+func (s *S3BlobStorage) signsPayload() bool {
+	return s.resolvesEndpoint || strings.HasPrefix(strings.ToLower(s.endpoint), "http://")
+}
+
+// spoolVerified copies all of body to a temporary file, failing if it does
+// not match its expected hash, and returns the file rewound to the start
+func (s *S3BlobStorage) spoolVerified(body *verifyingReader) (*os.File, error) {
+	spool, err := os.CreateTemp("", "raven-blob-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+
+	_, err = s.copyBuffered(spool, body)
+	if err == nil && !body.checked {
+		err = body.check()
+	}
+	if err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+		return nil, fmt.Errorf("failed to spool content: %w", err)
+	}
+	return spool, nil
+}
+
+// storeStream uploads size bytes read from r under blobID unless the blob
+// already exists, reporting whether an upload took place. progress, if not
+// nil, is passed the number of bytes uploaded as the upload proceeds.
+func (s *S3BlobStorage) storeStream(r io.Reader, size int64, blobID string, progress func(written int64)) (bool, error) {
+	if err := s.checkEmpty(size); err != nil {
+		return false, err
+	}
+
+	var head []byte
+	if s.autoDetectContentType || len(s.blockedContentTypes) > 0 {
+		var err error
+		if head, r, err = sniffHead(r); err != nil {
+			return false, err
+		}
+	}
+	if err := s.checkContentType(head); err != nil {
+		return false, err
+	}
+
+	key := s.blobKey(blobID)
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.opTimeout(s.uploadTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "Store"); err != nil {
+		return false, err
+	}
+	defer s.releaseOp()
+
+	if s.referenceCounting && !s.dryRun {
+		if _, _, err := s.updateReferenceCount(ctx, blobID, 1); err != nil {
+			return false, err
+		}
+	}
+
+	// Check if blob already exists
+	exists, err := s.dedupExists(ctx, blobID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check blob existence: %w", err)
+	}
+	s.recordStore(exists)
+	if exists {
+		return false, nil
+	}
+
+	if s.dryRun {
+		s.logger.Debugf("blobstorage: dry run, would store blob %s (%d bytes)", blobID, size)
+		return false, nil
+	}
+
+	opts := putOptions{contentType: s.contentTypeFor(putOptions{}, head), progress: progress}
+
+	if s.encodesContent() {
+		// The encoded size isn't known until the whole content has been
+		// compressed or encrypted, so it has to be buffered rather than streamed
+		plaintext, err := io.ReadAll(r)
+		if err != nil {
+			return false, fmt.Errorf("failed to read content: %w", err)
+		}
+		encoded, encodingMetadata, err := s.encodeContent(plaintext)
+		if err != nil {
+			return false, err
+		}
+		opts.checksum = s.uploadChecksumFor(encoded, "")
+		opts.contentMD5 = s.contentMD5For(encoded)
+		return s.finishStreamUpload(blobID, s.upload(ctx, key, bytes.NewReader(encoded), int64(len(encoded)), opts, encodingMetadata))
+	}
+
+	opts.checksum = s.uploadChecksumFor(nil, blobID)
+	return s.finishStreamUpload(blobID, s.upload(ctx, key, r, size, opts, nil))
+}
+
+// finishStreamUpload interprets the result of a streamed upload, reporting
+// whether this call's content was stored
+func (s *S3BlobStorage) finishStreamUpload(blobID string, err error) (bool, error) {
+	if errors.Is(err, errConcurrentlyStored) {
+		// Another writer stored the blob first; nothing of ours was written
+		s.dedupCache.add(blobID)
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+	s.dedupCache.add(blobID)
+	return true, nil
+}
+
+// verifyingReader hashes content as it is read and fails the read that would
+// complete the content if the digest does not match the expected blob ID.
+// Withholding the final bytes keeps a mismatched upload from completing.
+type verifyingReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	size     int64
+	read     int64
+	expected string
+	checked  bool
+	err      error
+}
+
+func newVerifyingReader(r io.Reader, size int64, expected string, h hash.Hash) *verifyingReader {
+	return &verifyingReader{
+		r:        io.LimitReader(r, size),
+		hash:     h,
+		size:     size,
+		expected: expected,
+	}
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+
+	n, err := v.r.Read(p)
+	v.hash.Write(p[:n])
+	v.read += int64(n)
+
+	if v.read == v.size && !v.checked {
+		if checkErr := v.check(); checkErr != nil {
+			return 0, checkErr
+		}
+	}
+
+	if err == io.EOF && v.read < v.size {
+		v.err = fmt.Errorf("content ended after %d of %d bytes: %w", v.read, v.size, io.ErrUnexpectedEOF)
+		return n, v.err
+	}
+
+	return n, err
+}
+
+// check compares the digest of everything read so far with the expected hash
+func (v *verifyingReader) check() error {
+	v.checked = true
+	if actual := hex.EncodeToString(v.hash.Sum(nil)); actual != v.expected {
+		v.err = fmt.Errorf("%w: expected %s, got %s", ErrHashMismatch, v.expected, actual)
+		return v.err
+	}
+	return nil
+}
+
+// sniffHead returns the start of r for content sniffing along with a reader
+// of all of r. Seekable readers such as spool files are read and rewound
+// rather than wrapped, so the upload body stays seekable: the SDK needs to
+// seek it to sign the payload over plain HTTP and to rewind it for retries.
+func sniffHead(r io.Reader) ([]byte, io.Reader, error) {
+	seeker, ok := r.(io.ReadSeeker)
+	if !ok {
+		// Peek without consuming the content
+		br := bufio.NewReaderSize(r, sniffLen)
+		head, _ := br.Peek(sniffLen)
+		return head, br, nil
+	}
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sniff content type: %w", err)
+	}
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(seeker, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, nil, fmt.Errorf("failed to sniff content type: %w", err)
+	}
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return nil, nil, fmt.Errorf("failed to sniff content type: %w", err)
+	}
+	return head[:n], seeker, nil
+}
+
+// progressReader reports the running total of bytes read through it
+type progressReader struct {
+	r        io.Reader
+	read     int64
+	progress func(written int64)
+}
+
+// newProgressReader wraps r to report progress, keeping it seekable if r is
+// so the SDK can still sign and rewind the body
+func newProgressReader(r io.Reader, progress func(written int64)) io.Reader {
+	p := &progressReader{r: r, progress: progress}
+	if seeker, ok := r.(io.Seeker); ok {
+		return &progressReadSeeker{progressReader: p, seeker: seeker}
+	}
+	return p
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.progress(p.read)
+	}
+	return n, err
+}
+
+// progressReadSeeker is a progressReader over a seekable body. Seeking
+// resets the running total to the new position, e.g. when the SDK rewinds
+// the body after hashing it or to retry.
+type progressReadSeeker struct {
+	*progressReader
+	seeker io.Seeker
+}
+
+func (p *progressReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos, err := p.seeker.Seek(offset, whence)
+	if err == nil {
+		p.read = pos
+	}
+	return pos, err
+}
@@ -0,0 +1,306 @@
+package blobstorage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// readingPutMock returns a PutObject func that drains the body like the HTTP
+// transport would, failing the request if the body returns an error
+func readingPutMock(uploaded *string) func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		data, err := io.ReadAll(params.Body)
+		if err != nil {
+			return nil, err
+		}
+		*uploaded = string(data)
+		return &s3.PutObjectOutput{}, nil
+	}
+}
+
+func TestStoreReaderWithSizeAndHash(t *testing.T) {
+	testContent := "streamed content for blob storage"
+	hash := sha256.Sum256([]byte(testContent))
+	expectedBlobID := hex.EncodeToString(hash[:])
+	otherHash := sha256.Sum256([]byte("other content"))
+	otherBlobID := hex.EncodeToString(otherHash[:])
+
+	tests := []struct {
+		name          string
+		content       string
+		size          int64
+		hash          string
+		enabled       bool
+		exists        bool
+		expectError   bool
+		errorIs       error
+		errorContains string
+		expectUpload  bool
+	}{
+		{
+			name:          "disabled storage",
+			content:       testContent,
+			size:          int64(len(testContent)),
+			hash:          expectedBlobID,
+			enabled:       false,
+			expectError:   true,
+			errorContains: "blob storage is not enabled",
+		},
+		{
+			name:         "matching precomputed hash",
+			content:      testContent,
+			size:         int64(len(testContent)),
+			hash:         expectedBlobID,
+			enabled:      true,
+			expectUpload: true,
+		},
+		{
+			name:         "uppercase precomputed hash",
+			content:      testContent,
+			size:         int64(len(testContent)),
+			hash:         strings.ToUpper(expectedBlobID),
+			enabled:      true,
+			expectUpload: true,
+		},
+		{
+			name:        "mismatching precomputed hash",
+			content:     testContent,
+			size:        int64(len(testContent)),
+			hash:        otherBlobID,
+			enabled:     true,
+			expectError: true,
+			errorIs:     ErrHashMismatch,
+		},
+		{
+			name:          "content shorter than size",
+			content:       testContent,
+			size:          int64(len(testContent)) + 10,
+			hash:          expectedBlobID,
+			enabled:       true,
+			expectError:   true,
+			errorContains: "unexpected EOF",
+		},
+		{
+			name:          "invalid hash",
+			content:       testContent,
+			size:          int64(len(testContent)),
+			hash:          "not-a-hash",
+			enabled:       true,
+			expectError:   true,
+			errorContains: "invalid precomputed hash",
+		},
+		{
+			name:    "deduplication - blob already exists",
+			content: testContent,
+			size:    int64(len(testContent)),
+			hash:    expectedBlobID,
+			enabled: true,
+			exists:  true,
+		},
+		{
+			name:        "mismatching hash for an existing blob",
+			content:     testContent,
+			size:        int64(len(testContent)),
+			hash:        otherBlobID,
+			enabled:     true,
+			exists:      true,
+			expectError: true,
+			errorIs:     ErrHashMismatch,
+		},
+	}
+
+	for _, endpoint := range []string{"", "http://minio:9000"} {
+		// Only plain HTTP needs a seekable body, so only there is the
+		// content spooled and verified before uploading
+		plainHTTP := endpoint != ""
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s plainHTTP=%v", tt.name, plainHTTP), func(t *testing.T) {
+				var uploaded string
+				putCalled := false
+				mock := &mockS3Client{
+					headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+						if tt.exists {
+							return &s3.HeadObjectOutput{}, nil
+						}
+						return nil, &smithy.GenericAPIError{Code: "NotFound"}
+					},
+				}
+				put := readingPutMock(&uploaded)
+				mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+					putCalled = true
+					if aws.ToInt64(params.ContentLength) != tt.size {
+						t.Errorf("expected ContentLength=%d, got %d", tt.size, aws.ToInt64(params.ContentLength))
+					}
+					if _, ok := params.Body.(io.Seeker); ok != plainHTTP {
+						t.Errorf("expected seekable=%v body, got %T", plainHTTP, params.Body)
+					}
+					return put(ctx, params, optFns...)
+				}
+				storage := newMockS3BlobStorage(mock, "test-bucket", tt.enabled)
+				storage.endpoint = endpoint
+
+				blobID, err := storage.StoreReaderWithSizeAndHash(strings.NewReader(tt.content), tt.size, tt.hash)
+
+				if tt.expectError {
+					if err == nil {
+						t.Errorf("expected error but got none")
+					} else if tt.errorIs != nil && !errors.Is(err, tt.errorIs) {
+						t.Errorf("expected error %v, got %v", tt.errorIs, err)
+					} else if tt.errorContains != "" && !strings.Contains(err.Error(), tt.errorContains) {
+						t.Errorf("expected error containing %q, got %q", tt.errorContains, err.Error())
+					}
+					if uploaded != "" || (plainHTTP && putCalled) {
+						t.Error("expected content that failed verification not to be uploaded")
+					}
+					return
+				}
+
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+
+				if blobID != expectedBlobID {
+					t.Errorf("expected blobID=%q, got %q", expectedBlobID, blobID)
+				}
+				if putCalled != tt.expectUpload {
+					t.Errorf("expected PutObject called=%v, got %v", tt.expectUpload, putCalled)
+				}
+				if tt.expectUpload && uploaded != tt.content {
+					t.Errorf("expected uploaded content=%q, got %q", tt.content, uploaded)
+				}
+			})
+		}
+	}
+}
+
+func TestVerifyingReaderWithholdsFinalBytesOnMismatch(t *testing.T) {
+	hash := sha256.Sum256([]byte("expected"))
+	reader := newVerifyingReader(strings.NewReader("mismatch"), int64(len("mismatch")), hex.EncodeToString(hash[:]), sha256.New())
+
+	data, err := io.ReadAll(reader)
+	if !errors.Is(err, ErrHashMismatch) {
+		t.Fatalf("expected ErrHashMismatch, got %v", err)
+	}
+	if len(data) == len("mismatch") {
+		t.Errorf("expected the final bytes to be withheld, got all %d bytes", len(data))
+	}
+}
+
+func TestStoreReaderProgress(t *testing.T) {
+	content := strings.Repeat("progress ", 5) // 45 bytes
+
+	tests := []struct {
+		name      string
+		multipart bool
+		expected  []int64
+	}{
+		{name: "single upload", expected: nil},
+		{name: "multipart reports each part", multipart: true, expected: []int64{16, 32, 45}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, objects := newBucketMock()
+			put := mock.putObjectFunc
+			mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				if _, ok := params.Body.(io.Seeker); !ok {
+					t.Errorf("expected a seekable body, got %T", params.Body)
+				}
+				return put(ctx, params, optFns...)
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", true)
+			if tt.multipart {
+				storage.multipartThreshold = 16
+				storage.multipartPartSize = 16
+			}
+
+			var reported []int64
+			blobID, err := storage.StoreReaderProgress(strings.NewReader(content), int64(len(content)), func(written int64) {
+				reported = append(reported, written)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(objects["blobs/"+blobID].body) != content {
+				t.Error("stored content does not match original")
+			}
+
+			if len(reported) == 0 || reported[len(reported)-1] != int64(len(content)) {
+				t.Fatalf("expected progress to end at %d, got %v", len(content), reported)
+			}
+			for i := 1; i < len(reported); i++ {
+				if reported[i] <= reported[i-1] {
+					t.Errorf("expected increasing progress, got %v", reported)
+				}
+			}
+			if tt.expected != nil && fmt.Sprint(reported) != fmt.Sprint(tt.expected) {
+				t.Errorf("expected progress %v, got %v", tt.expected, reported)
+			}
+
+			// Stored content is skipped without reporting progress
+			reported = nil
+			if _, err := storage.StoreReaderProgress(strings.NewReader(content), -1, func(written int64) {
+				reported = append(reported, written)
+			}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(reported) != 0 {
+				t.Errorf("expected no progress for deduplicated content, got %v", reported)
+			}
+		})
+	}
+}
+
+func TestProgressReaderSeek(t *testing.T) {
+	var reported []int64
+	r := newProgressReader(strings.NewReader("rewound content"), func(written int64) {
+		reported = append(reported, written)
+	})
+
+	seeker, ok := r.(io.ReadSeeker)
+	if !ok {
+		t.Fatalf("expected a seekable reader, got %T", r)
+	}
+	if _, err := io.ReadAll(seeker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The SDK rewinds the body after hashing it and before retries
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := io.ReadAll(seeker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if fmt.Sprint(reported) != "[15 15]" {
+		t.Errorf("expected progress to restart after seeking, got %v", reported)
+	}
+
+	if _, ok := newProgressReader(io.MultiReader(strings.NewReader("x")), func(int64) {}).(io.Seeker); ok {
+		t.Error("expected an unseekable reader to stay unseekable")
+	}
+}
+
+func TestStoreReaderProgressSizeMismatch(t *testing.T) {
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	_, err := storage.StoreReaderProgress(strings.NewReader("short"), 100, func(int64) {})
+	if err == nil || !strings.Contains(err.Error(), "does not match expected size 100") {
+		t.Errorf("expected a size mismatch error, got %v", err)
+	}
+	if len(objects) != 0 {
+		t.Error("expected nothing to be stored")
+	}
+}
@@ -0,0 +1,93 @@
+package blobstorage
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestKeySuffix(t *testing.T) {
+	content := "attachment with an extension"
+	expectedKey := "blobs/" + testBlobID(content) + ".bin"
+
+	mock, _ := newBucketMock()
+	var putKey, getKey string
+	putObject := mock.putObjectFunc
+	mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		putKey = *params.Key
+		return putObject(ctx, params, optFns...)
+	}
+	getObject := mock.getObjectFunc
+	mock.getObjectFunc = func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		getKey = *params.Key
+		return getObject(ctx, params, optFns...)
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.keySuffix = ".bin"
+
+	blobID, err := storage.Store(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if blobID != testBlobID(content) {
+		t.Errorf("expected bare blobID=%q, got %q", testBlobID(content), blobID)
+	}
+	if putKey != expectedKey {
+		t.Errorf("expected PutObject key=%q, got %q", expectedKey, putKey)
+	}
+
+	got, err := storage.Retrieve(blobID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != content {
+		t.Errorf("expected content=%q, got %q", content, got)
+	}
+	if getKey != expectedKey {
+		t.Errorf("expected GetObject key=%q, got %q", expectedKey, getKey)
+	}
+}
+
+func TestKeySuffixWithSharding(t *testing.T) {
+	storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+	storage.keySuffix = ".bin"
+	storage.shardDepth = 1
+
+	blobID := testBlobID("attachment")
+	key := storage.blobKey(blobID)
+	if expected := "blobs/" + blobID[:2] + "/" + blobID + ".bin"; key != expected {
+		t.Errorf("expected key=%q, got %q", expected, key)
+	}
+	if got := storage.blobIDFromKey(key); got != blobID {
+		t.Errorf("expected blobIDFromKey=%q, got %q", blobID, got)
+	}
+}
+
+func TestListKeySuffix(t *testing.T) {
+	mock := &mockS3Client{listObjectsFunc: pagedListMock(t, [][]string{{"blobs/aaa.bin", "blobs/bbb.bin"}})}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.keySuffix = ".bin"
+
+	ids, err := storage.List(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"aaa", "bbb"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected %v, got %v", expected, ids)
+	}
+}
+
+func TestNewS3BlobStorageInvalidKeySuffix(t *testing.T) {
+	_, err := NewS3BlobStorage(Config{
+		Enabled:   true,
+		AccessKey: "access",
+		SecretKey: "secret",
+		KeySuffix: "/data",
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid key suffix") {
+		t.Errorf("expected invalid key suffix error, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package blobstorage
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3 object tag limits
+const (
+	maxObjectTags     = 10
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+// tenantTagKey is the tag TenantID is recorded under
+const tenantTagKey = "tenant"
+
+// tagValuePattern matches the characters S3 allows in tag values
+var tagValuePattern = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// StoreWithTags stores content like Store and tags the object, e.g. so bucket
+// lifecycle rules can transition cold attachments. Tags do not affect the
+// blob ID; if identical content is already stored its tags are left as is.
+func (s *S3BlobStorage) StoreWithTags(content string, tags map[string]string) (_ string, err error) {
+	var result StoreResult
+	defer s.wrapStoreError("StoreWithTags", &result, &err)
+	if err := validateTags(s.objectTags(tags)); err != nil {
+		return "", err
+	}
+	result, err = s.storeResult(s.ctx, []byte(content), putOptions{tags: tags})
+	return result.BlobID, err
+}
+
+// GetTags returns the tags on a blob
+func (s *S3BlobStorage) GetTags(blobID string) (_ map[string]string, err error) {
+	defer s.wrapError("GetTags", blobID, &err)
+	if !s.enabled {
+		return nil, ErrStorageDisabled
+	}
+
+	if err := s.validateBlobID(blobID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "GetTags"); err != nil {
+		return nil, err
+	}
+	defer s.releaseOp()
+
+	result, err := s.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.blobKey(blobID)),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("failed to get blob tags: %w: %w", ErrBlobNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to get blob tags: %w", err)
+	}
+
+	tags := make(map[string]string, len(result.TagSet))
+	for _, tag := range result.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
+
+// SetTags replaces the tags on a blob, keeping the TenantID tag if set
+func (s *S3BlobStorage) SetTags(blobID string, tags map[string]string) (err error) {
+	defer s.wrapError("SetTags", blobID, &err)
+	if !s.enabled {
+		return ErrStorageDisabled
+	}
+
+	if err := s.validateBlobID(blobID); err != nil {
+		return err
+	}
+
+	tags = s.objectTags(tags)
+	if err := validateTags(tags); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tagSet := make([]types.Tag, 0, len(tags))
+	for _, k := range keys {
+		tagSet = append(tagSet, types.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "SetTags"); err != nil {
+		return err
+	}
+	defer s.releaseOp()
+
+	_, err = s.client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(s.bucket),
+		Key:     aws.String(s.blobKey(blobID)),
+		Tagging: &types.Tagging{TagSet: tagSet},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set blob tags: %w", err)
+	}
+	return nil
+}
+
+// objectTags returns tags with the TenantID tag added, which takes
+// precedence over an explicit tag of the same key
+func (s *S3BlobStorage) objectTags(tags map[string]string) map[string]string {
+	if s.tenantID == "" {
+		return tags
+	}
+
+	merged := maps.Clone(tags)
+	if merged == nil {
+		merged = make(map[string]string, 1)
+	}
+	merged[tenantTagKey] = s.tenantID
+	return merged
+}
+
+// encodeTags encodes tags as the URL query string PutObject expects in its
+// x-amz-tagging header, or nil when there are no tags
+func encodeTags(tags map[string]string) *string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	values := make(url.Values, len(tags))
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	return aws.String(values.Encode())
+}
+
+// validateTags checks tags against the S3 object tagging limits
+func validateTags(tags map[string]string) error {
+	if len(tags) > maxObjectTags {
+		return fmt.Errorf("too many tags: %d exceeds the limit of %d", len(tags), maxObjectTags)
+	}
+	for k, v := range tags {
+		if k == "" || len(k) > maxTagKeyLength {
+			return fmt.Errorf("invalid tag key %q: must be 1-%d characters", k, maxTagKeyLength)
+		}
+		if len(v) > maxTagValueLength {
+			return fmt.Errorf("invalid value for tag %q: must be at most %d characters", k, maxTagValueLength)
+		}
+	}
+	return nil
+}
+
+// validateTenantID checks that a TenantID can be used as a tag value
+func validateTenantID(tenantID string) error {
+	if len(tenantID) > maxTagValueLength || !tagValuePattern.MatchString(tenantID) {
+		return fmt.Errorf("invalid tenant ID %q: must be at most %d letters, numbers, spaces or + - = . _ : / @",
+			tenantID, maxTagValueLength)
+	}
+	return nil
+}
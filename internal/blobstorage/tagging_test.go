@@ -0,0 +1,255 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestEncodeTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     map[string]string
+		expected string
+	}{
+		{
+			name:     "simple tags are sorted",
+			tags:     map[string]string{"tier": "cold", "app": "raven"},
+			expected: "app=raven&tier=cold",
+		},
+		{
+			name:     "reserved characters are escaped",
+			tags:     map[string]string{"project name": "a&b=c", "path": "x/y+z"},
+			expected: "path=x%2Fy%2Bz&project+name=a%26b%3Dc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := aws.ToString(encodeTags(tt.tags))
+			if encoded != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, encoded)
+			}
+
+			decoded, err := url.ParseQuery(encoded)
+			if err != nil {
+				t.Fatalf("encoded tags are not a valid query string: %v", err)
+			}
+			for k, v := range tt.tags {
+				if decoded.Get(k) != v {
+					t.Errorf("expected tag %q to round-trip as %q, got %q", k, v, decoded.Get(k))
+				}
+			}
+		})
+	}
+
+	if encodeTags(nil) != nil {
+		t.Error("expected nil tagging for no tags")
+	}
+}
+
+func TestStoreWithTags(t *testing.T) {
+	t.Run("tags are sent on PutObject", func(t *testing.T) {
+		var tagging *string
+		mock, _ := newBucketMock()
+		put := mock.putObjectFunc
+		mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			tagging = params.Tagging
+			return put(ctx, params, optFns...)
+		}
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+		content := "cold attachment"
+		blobID, err := storage.StoreWithTags(content, map[string]string{"lifecycle": "glacier after 90d"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if blobID != testBlobID(content) {
+			t.Errorf("expected tags not to affect blob ID")
+		}
+		if got := aws.ToString(tagging); got != "lifecycle=glacier+after+90d" {
+			t.Errorf("expected encoded tagging, got %q", got)
+		}
+	})
+
+	t.Run("tags are sent on multipart uploads", func(t *testing.T) {
+		var tagging *string
+		mock, _ := newBucketMock()
+		create := mock.createMultipartUploadFunc
+		mock.createMultipartUploadFunc = func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+			tagging = params.Tagging
+			return create(ctx, params, optFns...)
+		}
+		storage := newMultipartTestStorage(mock)
+
+		if _, err := storage.StoreWithTags("content large enough for three parts", map[string]string{"tier": "cold"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := aws.ToString(tagging); got != "tier=cold" {
+			t.Errorf("expected encoded tagging, got %q", got)
+		}
+	})
+
+	t.Run("too many tags are rejected", func(t *testing.T) {
+		tags := make(map[string]string)
+		for i := 0; i <= maxObjectTags; i++ {
+			tags[fmt.Sprintf("key%d", i)] = "v"
+		}
+		storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+
+		if _, err := storage.StoreWithTags("content", tags); err == nil || !strings.Contains(err.Error(), "too many tags") {
+			t.Errorf("expected too many tags error, got %v", err)
+		}
+	})
+}
+
+func TestGetTags(t *testing.T) {
+	blobID := testBlobID("tagged content")
+
+	mock := &mockS3Client{
+		getObjectTaggingFunc: func(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+			if *params.Key != "blobs/"+blobID {
+				t.Errorf("expected key=%q, got %q", "blobs/"+blobID, *params.Key)
+			}
+			return &s3.GetObjectTaggingOutput{TagSet: []types.Tag{
+				{Key: aws.String("tier"), Value: aws.String("cold")},
+				{Key: aws.String("owner"), Value: aws.String("mail team")},
+			}}, nil
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	tags, err := storage.GetTags(blobID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{"tier": "cold", "owner": "mail team"}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("expected tags=%v, got %v", expected, tags)
+	}
+
+	mock.getObjectTaggingFunc = func(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+		return nil, errors.New("access denied")
+	}
+	if _, err := storage.GetTags(blobID); err == nil || !strings.Contains(err.Error(), "failed to get blob tags") {
+		t.Errorf("expected get tags error, got %v", err)
+	}
+
+	mock.getObjectTaggingFunc = func(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchKey"}
+	}
+	if _, err := storage.GetTags(blobID); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+}
+
+func TestSetTags(t *testing.T) {
+	blobID := testBlobID("tagged content")
+
+	var tagSet []types.Tag
+	mock := &mockS3Client{
+		putObjectTaggingFunc: func(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+			tagSet = params.Tagging.TagSet
+			return &s3.PutObjectTaggingOutput{}, nil
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	if err := storage.SetTags(blobID, map[string]string{"tier": "archive", "app": "raven"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []types.Tag{
+		{Key: aws.String("app"), Value: aws.String("raven")},
+		{Key: aws.String("tier"), Value: aws.String("archive")},
+	}
+	if !reflect.DeepEqual(tagSet, expected) {
+		t.Errorf("expected tag set %v, got %v", expected, tagSet)
+	}
+
+	disabled := newMockS3BlobStorage(mock, "test-bucket", false)
+	if err := disabled.SetTags(blobID, nil); !errors.Is(err, ErrStorageDisabled) {
+		t.Errorf("expected ErrStorageDisabled, got %v", err)
+	}
+}
+
+func TestTenantTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     map[string]string
+		content  string
+		expected string
+	}{
+		{name: "no explicit tags", content: "untagged", expected: "tenant=acme"},
+		{name: "merged with explicit tags", tags: map[string]string{"tier": "cold"}, content: "tagged", expected: "tenant=acme&tier=cold"},
+		{name: "overrides an explicit tenant tag", tags: map[string]string{"tenant": "other"}, content: "spoofed", expected: "tenant=acme"},
+		{name: "multipart upload", content: "content above the multipart threshold", expected: "tenant=acme"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, _ := newBucketMock()
+			var tagging string
+			putObject := mock.putObjectFunc
+			mock.putObjectFunc = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				tagging = aws.ToString(params.Tagging)
+				return putObject(ctx, params, optFns...)
+			}
+			createMultipart := mock.createMultipartUploadFunc
+			mock.createMultipartUploadFunc = func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+				tagging = aws.ToString(params.Tagging)
+				return createMultipart(ctx, params, optFns...)
+			}
+			storage := newMultipartTestStorage(mock)
+			storage.tenantID = "acme"
+
+			if _, err := storage.StoreWithTags(tt.content, tt.tags); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tagging != tt.expected {
+				t.Errorf("expected tagging %q, got %q", tt.expected, tagging)
+			}
+		})
+	}
+
+	t.Run("counts toward the tag limit", func(t *testing.T) {
+		storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+		storage.tenantID = "acme"
+		tags := make(map[string]string, maxObjectTags)
+		for i := range maxObjectTags {
+			tags[fmt.Sprintf("key%d", i)] = "value"
+		}
+		if _, err := storage.StoreWithTags("content", tags); err == nil || !strings.Contains(err.Error(), "too many tags") {
+			t.Errorf("expected a too many tags error, got %v", err)
+		}
+	})
+
+	t.Run("kept by SetTags", func(t *testing.T) {
+		var tagSet []types.Tag
+		mock := &mockS3Client{
+			putObjectTaggingFunc: func(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+				tagSet = params.Tagging.TagSet
+				return &s3.PutObjectTaggingOutput{}, nil
+			},
+		}
+		storage := newMockS3BlobStorage(mock, "test-bucket", true)
+		storage.tenantID = "acme"
+
+		if err := storage.SetTags(testBlobID("content"), map[string]string{"tier": "cold"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := []types.Tag{{Key: aws.String("tenant"), Value: aws.String("acme")}, {Key: aws.String("tier"), Value: aws.String("cold")}}
+		if !reflect.DeepEqual(tagSet, expected) {
+			t.Errorf("expected %v, got %v", expected, tagSet)
+		}
+	})
+}
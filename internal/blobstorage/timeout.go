@@ -0,0 +1,12 @@
+package blobstorage
+
+import "time"
+
+// opTimeout returns override, one of the per-kind timeouts, falling back to
+// the general timeout when it isn't set
+func (s *S3BlobStorage) opTimeout(override time.Duration) time.Duration {
+	if override > 0 {
+		return override
+	}
+	return s.timeout
+}
@@ -0,0 +1,49 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+func TestOperationTimeouts(t *testing.T) {
+	// HeadObject takes 100ms, which is within the upload timeout used by
+	// Store's dedup check but beyond the metadata timeout used by Exists
+	mock := &mockS3Client{
+		headObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			select {
+			case <-time.After(100 * time.Millisecond):
+				return nil, &smithy.GenericAPIError{Code: "NotFound"}
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.metadataTimeout = 20 * time.Millisecond
+	storage.uploadTimeout = 5 * time.Second
+
+	if _, err := storage.Exists(testBlobID("attachment")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected Exists to hit the metadata timeout, got %v", err)
+	}
+
+	if _, err := storage.Store("slow head"); err != nil {
+		t.Errorf("expected Store to complete within the upload timeout, got %v", err)
+	}
+}
+
+func TestOpTimeoutFallsBackToTimeout(t *testing.T) {
+	storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", true)
+	storage.downloadTimeout = time.Minute
+
+	if got := storage.opTimeout(storage.downloadTimeout); got != time.Minute {
+		t.Errorf("expected override of 1m, got %v", got)
+	}
+	if got := storage.opTimeout(storage.uploadTimeout); got != storage.timeout {
+		t.Errorf("expected fallback to %v, got %v", storage.timeout, got)
+	}
+}
@@ -0,0 +1,69 @@
+package blobstorage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Touch resets a blob's last modified time, e.g. when a new message
+// references it, so that lifecycle expiration rules measure age from its
+// last use rather than its first store. The object is copied onto itself
+// with MetadataDirective REPLACE, which S3 requires for an in-place copy;
+// the blob's metadata, content type and storage class are carried over.
+func (s *S3BlobStorage) Touch(ctx context.Context, blobID string) (err error) {
+	defer s.wrapError("Touch", blobID, &err)
+	if !s.enabled {
+		return ErrStorageDisabled
+	}
+
+	if err := s.validateBlobID(blobID); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "Touch"); err != nil {
+		return err
+	}
+	defer s.releaseOp()
+
+	key := s.blobKey(blobID)
+	head, err := s.client.HeadObject(ctx, s.headObjectInput(key))
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("failed to touch blob: %w: %w", ErrBlobNotFound, err)
+		}
+		return fmt.Errorf("failed to touch blob: %w", err)
+	}
+
+	if s.dryRun {
+		s.logger.Debugf("blobstorage: dry run, would touch blob %s", blobID)
+		return nil
+	}
+
+	sse, kmsKeyID := s.serverSideEncryption()
+	input := &s3.CopyObjectInput{
+		Bucket:               aws.String(s.bucket),
+		Key:                  aws.String(key),
+		CopySource:           copySource(s.bucket, key),
+		MetadataDirective:    types.MetadataDirectiveReplace,
+		Metadata:             head.Metadata,
+		ContentType:          head.ContentType,
+		StorageClass:         types.StorageClass(head.StorageClass),
+		ServerSideEncryption: sse,
+		SSEKMSKeyId:          kmsKeyID,
+		ACL:                  types.ObjectCannedACL(s.acl),
+	}
+	s.setCopySSECustomer(input, s)
+	_, err = s.client.CopyObject(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to touch blob: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestTouch(t *testing.T) {
+	mock, objects := newBucketMock()
+	var copies []*s3.CopyObjectInput
+	mock.copyObjectFunc = func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+		copies = append(copies, params)
+		objects[aws.ToString(params.Key)].lastModified = time.Now()
+		return &s3.CopyObjectOutput{}, nil
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	blobID, err := storage.StoreWithMetadata("referenced again", map[string]string{"source": "imap"})
+	if err != nil {
+		t.Fatalf("failed to store blob: %v", err)
+	}
+	key := "blobs/" + blobID
+	objects[key].lastModified = time.Now().Add(-48 * time.Hour)
+
+	if err := storage.Touch(context.Background(), blobID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(copies) != 1 {
+		t.Fatalf("expected 1 CopyObject call, got %d", len(copies))
+	}
+	input := copies[0]
+	if aws.ToString(input.CopySource) != "test-bucket/"+url.PathEscape(key) || aws.ToString(input.Key) != key {
+		t.Errorf("expected an in-place copy of %s, got %s -> %s", key, aws.ToString(input.CopySource), aws.ToString(input.Key))
+	}
+	if input.MetadataDirective != types.MetadataDirectiveReplace {
+		t.Errorf("expected MetadataDirective=REPLACE, got %q", input.MetadataDirective)
+	}
+	if input.Metadata["source"] != "imap" {
+		t.Errorf("expected metadata to be carried over, got %v", input.Metadata)
+	}
+
+	lastModified, err := storage.GetLastModified(blobID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if time.Since(lastModified) > time.Minute {
+		t.Errorf("expected last modified to be reset, got %v", lastModified)
+	}
+
+	if err := storage.Touch(context.Background(), testBlobID("missing")); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package blobstorage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Validate checks cfg without connecting to anything, e.g. to fail fast on a
+// bad config file at startup. It returns every problem found, joined, rather
+// than stopping at the first. Disabled configs are always valid.
+// NewS3BlobStorage calls it too.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	if c.Profile != "" {
+		check(c.AccessKey == "" && c.SecretKey == "", "profile %q conflicts with static access and secret keys", c.Profile)
+	} else {
+		check(c.UseDefaultCredentials || (c.AccessKey != "" && c.SecretKey != ""),
+			"S3 access key and secret key are required when blob storage is enabled")
+	}
+
+	check(c.Timeout >= 0, "invalid timeout %d: must not be negative", c.Timeout)
+	check(c.UploadTimeout >= 0 && c.DownloadTimeout >= 0 && c.MetadataTimeout >= 0,
+		"operation timeouts must not be negative")
+	check(c.MaxAttempts >= 0 && c.RetryMaxBackoff >= 0, "retry settings must not be negative")
+	check(c.MultipartThreshold >= 0, "invalid multipart threshold %d", c.MultipartThreshold)
+	check(c.MultipartPartSize == 0 || (c.MultipartPartSize >= minMultipartPartSize && c.MultipartPartSize <= maxMultipartPartSize),
+		"invalid multipart part size %d: must be between %d and %d bytes", c.MultipartPartSize, minMultipartPartSize, maxMultipartPartSize)
+	check(c.CircuitBreakerThreshold >= 0 && c.CircuitBreakerCooldown >= 0,
+		"circuit breaker settings must not be negative")
+	check(c.Failover.Timeout >= 0, "invalid failover timeout %d", c.Failover.Timeout)
+	check(c.BucketCheckTTL >= 0, "invalid bucket check TTL %s", c.BucketCheckTTL)
+	check(c.MaxConcurrentOps >= 0, "invalid max concurrent ops %d", c.MaxConcurrentOps)
+	check(c.DedupCacheSize >= 0, "invalid dedup cache size %d", c.DedupCacheSize)
+	check(c.CopyBufferSize >= 0, "invalid copy buffer size %d", c.CopyBufferSize)
+	check(c.MaxBlobSize >= 0, "invalid max blob size %d", c.MaxBlobSize)
+	check(c.ShardDepth >= 0 && c.ShardDepth <= maxShardDepth,
+		"invalid shard depth %d: must be between 0 and %d", c.ShardDepth, maxShardDepth)
+
+	switch c.DedupThrottlePolicy {
+	case "", ThrottlePolicyFail, ThrottlePolicyUpload:
+	default:
+		errs = append(errs, fmt.Errorf("invalid dedup throttle policy %q", c.DedupThrottlePolicy))
+	}
+
+	switch c.Compression {
+	case "", CompressionNone, CompressionGzip:
+	default:
+		errs = append(errs, fmt.Errorf("invalid compression %q", c.Compression))
+	}
+
+	switch c.ServerSideEncryption {
+	case "", ServerSideEncryptionS3, ServerSideEncryptionKMS:
+	default:
+		errs = append(errs, fmt.Errorf("invalid server-side encryption %q", c.ServerSideEncryption))
+	}
+	check(c.KMSKeyID == "" || c.ServerSideEncryption == ServerSideEncryptionKMS,
+		"KMS key ID requires server-side encryption %q", ServerSideEncryptionKMS)
+	if c.SSECustomerKey != nil {
+		check(len(c.SSECustomerKey) == sseCustomerKeySize,
+			"invalid SSE-C key: must be %d bytes, got %d", sseCustomerKeySize, len(c.SSECustomerKey))
+		check(c.ServerSideEncryption == "", "SSE-C key conflicts with server-side encryption %q", c.ServerSideEncryption)
+	}
+
+	switch c.VerifyMultipart {
+	case "", VerifyMultipartSize, VerifyMultipartContent:
+	default:
+		errs = append(errs, fmt.Errorf("invalid multipart verification %q", c.VerifyMultipart))
+	}
+
+	switch c.HashAlgorithm {
+	case "", HashSHA256, HashSHA512:
+	default:
+		errs = append(errs, fmt.Errorf("invalid hash algorithm %q", c.HashAlgorithm))
+	}
+
+	check(!strings.Contains(c.KeySuffix, "/"), "invalid key suffix %q: must not contain '/'", c.KeySuffix)
+	check(!strings.HasPrefix(c.KeyPrefix, "/"), "invalid key prefix %q: must not start with '/'", c.KeyPrefix)
+
+	if c.EncryptionKey != nil {
+		check(len(c.EncryptionKey) == encryptionKeySize,
+			"invalid encryption key: must be %d bytes, got %d", encryptionKeySize, len(c.EncryptionKey))
+	}
+
+	for _, err := range []error{
+		validateObjectLock(c),
+		validateStorageClass(c.StorageClass),
+		validateUploadChecksum(c.UploadChecksum),
+		validateACL(c.ACL),
+		validateTenantID(c.TenantID),
+		validateProvider(c.Provider, c.Endpoint),
+		validateExpressOneZone(c),
+	} {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	check(!c.BucketOwnerFullControl || c.ACL == "" || c.ACL == string(types.ObjectCannedACLBucketOwnerFullControl),
+		"ACL %q conflicts with bucket owner full control", c.ACL)
+
+	return errors.Join(errs...)
+}
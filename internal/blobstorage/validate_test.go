@@ -0,0 +1,86 @@
+package blobstorage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{Enabled: true, AccessKey: "access", SecretKey: "secret"}
+
+	tests := []struct {
+		name     string
+		modify   func(c *Config)
+		expected []string
+	}{
+		{name: "valid", modify: func(c *Config) {}},
+		{name: "disabled configs are not checked", modify: func(c *Config) {
+			c.Enabled = false
+			c.Timeout = -1
+		}},
+		{name: "negative timeout", modify: func(c *Config) { c.Timeout = -1 }, expected: []string{"invalid timeout -1"}},
+		{name: "short encryption key", modify: func(c *Config) { c.EncryptionKey = []byte("short") }, expected: []string{"invalid encryption key: must be 32 bytes, got 5"}},
+		{name: "absolute key prefix", modify: func(c *Config) { c.KeyPrefix = "/tenant" }, expected: []string{`invalid key prefix "/tenant"`}},
+		{name: "minimum part size", modify: func(c *Config) { c.MultipartPartSize = 5 * 1024 * 1024 }},
+		{name: "part size too small", modify: func(c *Config) { c.MultipartPartSize = 5*1024*1024 - 1 }, expected: []string{"invalid multipart part size 5242879"}},
+		{name: "part size too large", modify: func(c *Config) { c.MultipartPartSize = 5*1024*1024*1024 + 1 }, expected: []string{"invalid multipart part size 5368709121"}},
+		{name: "R2 provider", modify: func(c *Config) {
+			c.Provider = ProviderR2
+			c.Endpoint = "https://account.r2.cloudflarestorage.com"
+		}},
+		{name: "unknown provider", modify: func(c *Config) { c.Provider = "wasabi" }, expected: []string{`invalid provider "wasabi"`}},
+		{name: "provider without endpoint", modify: func(c *Config) { c.Provider = ProviderSpaces }, expected: []string{`provider "spaces" requires an endpoint`}},
+		{name: "multipart content verification", modify: func(c *Config) { c.VerifyMultipart = VerifyMultipartContent }},
+		{name: "unknown multipart verification", modify: func(c *Config) { c.VerifyMultipart = "etag" }, expected: []string{`invalid multipart verification "etag"`}},
+		{name: "negative bucket check TTL", modify: func(c *Config) { c.BucketCheckTTL = -time.Second }, expected: []string{"invalid bucket check TTL -1s"}},
+		{name: "tenant ID", modify: func(c *Config) { c.TenantID = "acme-corp:eu/1" }},
+		{name: "invalid tenant ID", modify: func(c *Config) { c.TenantID = "acme&co" }, expected: []string{`invalid tenant ID "acme&co"`}},
+		{name: "long tenant ID", modify: func(c *Config) { c.TenantID = strings.Repeat("a", 257) }, expected: []string{"invalid tenant ID"}},
+		{name: "short SSE-C key", modify: func(c *Config) { c.SSECustomerKey = []byte("short") }, expected: []string{"invalid SSE-C key: must be 32 bytes, got 5"}},
+		{name: "SSE-C with SSE-KMS", modify: func(c *Config) {
+			c.SSECustomerKey = make([]byte, 32)
+			c.ServerSideEncryption = ServerSideEncryptionKMS
+		}, expected: []string{`SSE-C key conflicts with server-side encryption "aws:kms"`}},
+		{name: "ACL conflict", modify: func(c *Config) {
+			c.ACL = "public-read"
+			c.BucketOwnerFullControl = true
+		}, expected: []string{`ACL "public-read" conflicts with bucket owner full control`}},
+		{name: "every problem is reported", modify: func(c *Config) {
+			c.SecretKey = ""
+			c.MaxAttempts = -1
+			c.ACL = "world-writable"
+			c.Compression = "zstd"
+			c.ShardDepth = 9
+		}, expected: []string{
+			"S3 access key and secret key are required",
+			"retry settings must not be negative",
+			`invalid ACL "world-writable"`,
+			`invalid compression "zstd"`,
+			"invalid shard depth 9",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if len(tt.expected) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, msg := range tt.expected {
+				if !strings.Contains(err.Error(), msg) {
+					t.Errorf("expected error to contain %q, got %q", msg, err.Error())
+				}
+			}
+		})
+	}
+}
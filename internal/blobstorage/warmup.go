@@ -0,0 +1,59 @@
+package blobstorage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// WarmUp checks that the bucket is reachable with a HeadBucket request. It
+// is meant to be called at startup so connection setup and TLS handshakes
+// happen before the first real request, and so missing access is reported
+// immediately rather than on first use.
+func (s *S3BlobStorage) WarmUp(ctx context.Context) (err error) {
+	defer s.wrapError("WarmUp", "", &err)
+	return s.checkBucket(ctx)
+}
+
+// HealthCheck reports whether the bucket is reachable, e.g. for a readiness
+// probe. With BucketCheckTTL set, a recent successful check is reused
+// rather than sending HeadBucket again.
+func (s *S3BlobStorage) HealthCheck(ctx context.Context) (err error) {
+	defer s.wrapError("HealthCheck", "", &err)
+	return s.checkBucket(ctx)
+}
+
+// checkBucket sends HeadBucket unless a check succeeded within
+// BucketCheckTTL
+func (s *S3BlobStorage) checkBucket(ctx context.Context) error {
+	if !s.enabled {
+		return ErrStorageDisabled
+	}
+
+	if s.bucketCheckTTL > 0 {
+		if verifiedAt := s.bucketVerifiedAt.Load(); verifiedAt != 0 && s.clock().UnixNano()-verifiedAt < int64(s.bucketCheckTTL) {
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opTimeout(s.metadataTimeout))
+	defer cancel()
+
+	if err := s.acquireOp(ctx, "CheckBucket"); err != nil {
+		return err
+	}
+	defer s.releaseOp()
+
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucket),
+	})
+	if err != nil {
+		s.bucketVerifiedAt.Store(0)
+		return fmt.Errorf("failed to reach bucket %s: %w", s.bucket, err)
+	}
+
+	s.bucketVerifiedAt.Store(s.clock().UnixNano())
+	return nil
+}
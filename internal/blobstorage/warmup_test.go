@@ -0,0 +1,149 @@
+package blobstorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+func TestWarmUp(t *testing.T) {
+	tests := []struct {
+		name          string
+		enabled       bool
+		headBucketErr error
+		errorContains string
+	}{
+		{name: "bucket reachable", enabled: true},
+		{name: "access denied", enabled: true, headBucketErr: &smithy.GenericAPIError{Code: "Forbidden"}, errorContains: "failed to reach bucket test-bucket"},
+		{name: "disabled storage", enabled: false, errorContains: "blob storage is not enabled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bucket string
+			mock := &mockS3Client{
+				headBucketFunc: func(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+					bucket = *params.Bucket
+					return &s3.HeadBucketOutput{}, tt.headBucketErr
+				},
+			}
+			storage := newMockS3BlobStorage(mock, "test-bucket", tt.enabled)
+
+			err := storage.WarmUp(context.Background())
+
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("expected error containing %q, got %v", tt.errorContains, err)
+				}
+				if tt.headBucketErr != nil && !errors.Is(err, tt.headBucketErr) {
+					t.Errorf("expected HeadBucket error to be wrapped, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if bucket != "test-bucket" {
+				t.Errorf("expected HeadBucket on test-bucket, got %q", bucket)
+			}
+		})
+	}
+}
+
+func TestNewS3BlobStorageSkipBucketCreation(t *testing.T) {
+	var requests []string
+	httpClient := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			requests = append(requests, req.Method+" "+req.URL.Path)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}, nil
+		}),
+	}
+
+	storage, err := NewS3BlobStorage(Config{
+		Enabled:            true,
+		Endpoint:           "http://localhost:9000",
+		AccessKey:          "test-key",
+		SecretKey:          "test-secret",
+		Bucket:             "test-bucket",
+		HTTPClient:         httpClient,
+		SkipBucketCreation: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("expected no requests at construction, got %v", requests)
+	}
+
+	if err := storage.WarmUp(context.Background()); err != nil {
+		t.Fatalf("unexpected error from WarmUp: %v", err)
+	}
+	if len(requests) != 1 || requests[0] != "HEAD /test-bucket" {
+		t.Errorf("expected WarmUp to send HEAD /test-bucket, got %v", requests)
+	}
+}
+
+func TestHealthCheckCache(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var headBucketErr error
+	calls := 0
+	mock := &mockS3Client{
+		headBucketFunc: func(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+			calls++
+			return &s3.HeadBucketOutput{}, headBucketErr
+		},
+	}
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.clock = func() time.Time { return now }
+	storage.bucketCheckTTL = time.Minute
+
+	steps := []struct {
+		name          string
+		advance       time.Duration
+		headBucketErr error
+		expectCalls   int
+		expectError   bool
+	}{
+		{name: "first check hits the network", expectCalls: 1},
+		{name: "within the TTL is cached", advance: 30 * time.Second, expectCalls: 1},
+		{name: "stale cache checks again", advance: time.Minute, expectCalls: 2},
+		{name: "outage detected once stale", advance: time.Minute, headBucketErr: errors.New("connection refused"), expectCalls: 3, expectError: true},
+		{name: "failure is not cached", advance: time.Second, expectCalls: 4},
+		{name: "recovery is cached", advance: time.Second, expectCalls: 4},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		headBucketErr = step.headBucketErr
+		err := storage.HealthCheck(context.Background())
+		if (err != nil) != step.expectError {
+			t.Errorf("%s: expected error=%v, got %v", step.name, step.expectError, err)
+		}
+		if calls != step.expectCalls {
+			t.Errorf("%s: expected %d HeadBucket calls, got %d", step.name, step.expectCalls, calls)
+		}
+	}
+
+	// Without a TTL every check hits the network
+	storage.bucketCheckTTL = 0
+	for range 2 {
+		if err := storage.WarmUp(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if calls != 6 {
+		t.Errorf("expected every check to send HeadBucket without a TTL, got %d calls", calls)
+	}
+}
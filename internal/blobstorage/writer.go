@@ -0,0 +1,141 @@
+package blobstorage
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// errBlobWriterClosed is returned by writes to a BlobWriter after Close or Abort
+var errBlobWriterClosed = errors.New("blob writer is closed")
+
+// BlobWriter builds a blob from a sequence of writes, e.g. MIME parts as they
+// are assembled. Like StoreReader it spools content to a temporary file while
+// hashing it, so memory use is bounded regardless of the blob's size, and
+// uploads it on Close. A BlobWriter is not safe for concurrent use.
+type BlobWriter struct {
+	s      *S3BlobStorage
+	spool  *os.File
+	hash   hash.Hash
+	size   int64
+	blobID string
+	err    error
+	closed bool
+}
+
+var _ io.WriteCloser = (*BlobWriter)(nil)
+
+// NewBlobWriter returns a writer that stores everything written to it as a
+// single blob when closed. Errors creating the spool file are reported by
+// the first Write or Close.
+func (s *S3BlobStorage) NewBlobWriter() *BlobWriter {
+	return &BlobWriter{s: s, hash: s.newHash()}
+}
+
+// Write appends p to the blob, failing with ErrBlobTooLarge as soon as the
+// content exceeds MaxBlobSize. After a failed write the writer is unusable.
+func (w *BlobWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errBlobWriterClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	if err := w.start(); err != nil {
+		return 0, w.fail(err)
+	}
+	if err := w.s.checkSize(w.size + int64(len(p))); err != nil {
+		return 0, w.fail(err)
+	}
+
+	n, err := w.spool.Write(p)
+	w.hash.Write(p[:n])
+	w.size += int64(n)
+	if err != nil {
+		return n, w.fail(fmt.Errorf("failed to write to spool file: %w", err))
+	}
+	return n, nil
+}
+
+// Close uploads the blob unless identical content is already stored, after
+// which BlobID returns its ID. Closing again returns the first result.
+func (w *BlobWriter) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+	defer w.removeSpool()
+
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.start(); err != nil {
+		w.err = err
+		return err
+	}
+
+	if _, err := w.spool.Seek(0, io.SeekStart); err != nil {
+		w.err = fmt.Errorf("failed to rewind spool file: %w", err)
+		return w.err
+	}
+
+	blobID := hex.EncodeToString(w.hash.Sum(nil))
+	if _, err := w.s.storeStream(w.spool, w.size, blobID, nil); err != nil {
+		w.err = err
+		return err
+	}
+
+	w.blobID = blobID
+	return nil
+}
+
+// Abort discards everything written without uploading it
+func (w *BlobWriter) Abort() {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.err = errBlobWriterClosed
+	w.removeSpool()
+}
+
+// BlobID returns the ID of the stored blob, or "" until Close succeeds
+func (w *BlobWriter) BlobID() string {
+	return w.blobID
+}
+
+// start creates the spool file on first use
+func (w *BlobWriter) start() error {
+	if !w.s.enabled {
+		return ErrStorageDisabled
+	}
+	if w.spool != nil {
+		return nil
+	}
+
+	spool, err := os.CreateTemp("", "raven-blob-*")
+	if err != nil {
+		return fmt.Errorf("failed to create spool file: %w", err)
+	}
+	w.spool = spool
+	return nil
+}
+
+// fail records err so later writes and Close return it, and drops the spool
+func (w *BlobWriter) fail(err error) error {
+	w.err = err
+	w.removeSpool()
+	return err
+}
+
+func (w *BlobWriter) removeSpool() {
+	if w.spool == nil {
+		return
+	}
+	_ = w.spool.Close()
+	_ = os.Remove(w.spool.Name())
+	w.spool = nil
+}
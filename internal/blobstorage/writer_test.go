@@ -0,0 +1,103 @@
+package blobstorage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestBlobWriter(t *testing.T) {
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	w := storage.NewBlobWriter()
+	parts := []string{"Content-Type: multipart/mixed\r\n\r\n", "--boundary\r\n", "part body\r\n", "--boundary--\r\n"}
+	content := ""
+	for _, part := range parts {
+		if _, err := fmt.Fprint(w, part); err != nil {
+			t.Fatalf("unexpected write error: %v", err)
+		}
+		content += part
+	}
+
+	if w.BlobID() != "" {
+		t.Error("expected no blob ID before Close")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error from Close: %v", err)
+	}
+	if w.BlobID() != testBlobID(content) {
+		t.Errorf("expected blobID=%q, got %q", testBlobID(content), w.BlobID())
+	}
+	if got := string(objects["blobs/"+w.BlobID()].body); got != content {
+		t.Errorf("expected stored content=%q, got %q", content, got)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Errorf("expected second Close to return the first result, got %v", err)
+	}
+	if _, err := w.Write([]byte("more")); !errors.Is(err, errBlobWriterClosed) {
+		t.Errorf("expected write after Close to fail, got %v", err)
+	}
+}
+
+func TestBlobWriterEmpty(t *testing.T) {
+	mock, _ := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	w := storage.NewBlobWriter()
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.BlobID() != testBlobID("") {
+		t.Errorf("expected blobID=%q, got %q", testBlobID(""), w.BlobID())
+	}
+}
+
+func TestBlobWriterSizeLimit(t *testing.T) {
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+	storage.maxBlobSize = 10
+
+	w := storage.NewBlobWriter()
+	if _, err := w.Write([]byte("12345")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var tooLarge *ErrBlobTooLarge
+	if _, err := w.Write([]byte("678901")); !errors.As(err, &tooLarge) {
+		t.Fatalf("expected ErrBlobTooLarge, got %v", err)
+	}
+	if err := w.Close(); !errors.As(err, &tooLarge) {
+		t.Errorf("expected Close to return ErrBlobTooLarge, got %v", err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("expected nothing uploaded, bucket has %d objects", len(objects))
+	}
+}
+
+func TestBlobWriterAbort(t *testing.T) {
+	mock, objects := newBucketMock()
+	storage := newMockS3BlobStorage(mock, "test-bucket", true)
+
+	w := storage.NewBlobWriter()
+	if _, err := w.Write([]byte("partial")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Abort()
+
+	if err := w.Close(); !errors.Is(err, errBlobWriterClosed) {
+		t.Errorf("expected Close after Abort to fail, got %v", err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("expected nothing uploaded, bucket has %d objects", len(objects))
+	}
+}
+
+func TestBlobWriterDisabled(t *testing.T) {
+	storage := newMockS3BlobStorage(&mockS3Client{}, "test-bucket", false)
+
+	w := storage.NewBlobWriter()
+	if _, err := w.Write([]byte("content")); !errors.Is(err, ErrStorageDisabled) {
+		t.Errorf("expected ErrStorageDisabled, got %v", err)
+	}
+}